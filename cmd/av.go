@@ -0,0 +1,140 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/urfave/cli"
+
+	"github.com/free5gc/openapi/models"
+	"github.com/free5gc/udm/internal/sbi/processor"
+	"github.com/free5gc/udm/pkg/factory"
+)
+
+func avCommand() cli.Command {
+	return cli.Command{
+		Name:   "av",
+		Usage:  "Compute authentication vectors offline",
+		Before: quietLog,
+		Subcommands: []cli.Command{
+			{
+				Name:   "compute",
+				Usage:  "Print the 5G-AKA or EAP-AKA' vector of a Milenage or TUAK subscriber",
+				Action: avComputeAction,
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "k",
+						Usage: "Permanent key K, 32 hexadecimal digits; 32 or 64 for TUAK",
+					},
+					cli.StringFlag{
+						Name:  "opc",
+						Usage: "OPc, 32 hexadecimal digits",
+					},
+					cli.StringFlag{
+						Name:  "algorithm, a",
+						Value: "milenage",
+						Usage: "Authentication algorithm: milenage or tuak",
+					},
+					cli.StringFlag{
+						Name:  "topc",
+						Usage: "TUAK TOPc, 64 hexadecimal digits",
+					},
+					cli.IntFlag{
+						Name:  "mac-length",
+						Usage: "TUAK MAC length in bits: 64, 128 or 256; 64 when omitted",
+					},
+					cli.IntFlag{
+						Name:  "res-length",
+						Usage: "TUAK RES length in bits: 32, 64, 128 or 256; 64 when omitted",
+					},
+					cli.IntFlag{
+						Name:  "keccak-iterations",
+						Usage: "TUAK Keccak iterations, 1-255; 1 when omitted",
+					},
+					cli.StringFlag{
+						Name:  "sqn",
+						Usage: "Sequence number, 12 hexadecimal digits",
+					},
+					cli.StringFlag{
+						Name:  "rand",
+						Usage: "RAND, 32 hexadecimal digits; random when omitted",
+					},
+					cli.StringFlag{
+						Name:  "amf",
+						Value: "8000",
+						Usage: "Authentication management field, 4 hexadecimal digits",
+					},
+					cli.StringFlag{
+						Name:  "method, m",
+						Value: string(models.AuthMethod__5_G_AKA),
+						Usage: "Authentication method: 5G_AKA or EAP_AKA_PRIME",
+					},
+					cli.StringFlag{
+						Name:  "serving-network-name, n",
+						Usage: "Serving network name, e.g. 5G:mnc093.mcc208.3gppnetwork.org",
+					},
+				},
+			},
+		},
+	}
+}
+
+func avComputeAction(cliCtx *cli.Context) error {
+	if cliCtx.String("serving-network-name") == "" {
+		return fmt.Errorf("serving-network-name is required")
+	}
+
+	authSubs := &models.AuthenticationSubscription{EncPermanentKey: cliCtx.String("k")}
+	var tuakProfiles []factory.TuakProfile
+	switch algorithm := strings.ToLower(cliCtx.String("algorithm")); algorithm {
+	case "milenage":
+		authSubs.EncOpcKey = cliCtx.String("opc")
+	case "tuak":
+		// TUAK subscriptions carry TOPc in encTopcKey
+		authSubs.AlgorithmId = algorithm
+		authSubs.EncTopcKey = cliCtx.String("topc")
+		tuakProfiles = []factory.TuakProfile{
+			{
+				AlgorithmId:      algorithm,
+				MacLength:        cliCtx.Int("mac-length"),
+				ResLength:        cliCtx.Int("res-length"),
+				KeccakIterations: cliCtx.Int("keccak-iterations"),
+			},
+		}
+	default:
+		return fmt.Errorf("algorithm [%s] should be milenage or tuak", algorithm)
+	}
+
+	params := make(map[string][]byte)
+	for _, name := range []string{"sqn", "amf"} {
+		value, err := hex.DecodeString(cliCtx.String(name))
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", name, err)
+		}
+		params[name] = value
+	}
+	randValue := make([]byte, 16)
+	if cliCtx.String("rand") != "" {
+		var err error
+		if randValue, err = hex.DecodeString(cliCtx.String("rand")); err != nil {
+			return fmt.Errorf("invalid rand: %w", err)
+		}
+	} else if _, err := rand.Read(randValue); err != nil {
+		return err
+	}
+
+	av, err := processor.ComputeAuthenticationVector(authSubs, tuakProfiles, randValue, params["sqn"], params["amf"],
+		models.AuthMethod(cliCtx.String("method")), cliCtx.String("serving-network-name"))
+	if err != nil {
+		return err
+	}
+	out, err := json.MarshalIndent(av, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(cliCtx.App.Writer, string(out))
+	return nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/free5gc/openapi/models"
+)
+
+func TestAvCompute(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   []string
+		wantAv models.AuthenticationVector
+		// AUTN = SQN xor AK || AMF || MAC-A, checked by AMF || MAC-A when AK is not in the test set
+		wantAutnSuffix string
+		wantErr        string
+	}{
+		{
+			// TS 35.208 Test Set 19, the vector of RFC 5448 Appendix C Test Case 1
+			name: "milenage",
+			args: []string{
+				"--k", "5122250214c33e723a5dd523fc145fc0", "--opc", "981d464c7c52eb6e5036234984ad0bcf",
+				"--rand", "81e92b6c0ee0e12ebceba8d92a99dfa5", "--sqn", "16f3b3f70fc2", "--amf", "c3ab",
+			},
+			wantAv: models.AuthenticationVector{
+				AvType:  models.AvType_EAP_AKA_PRIME,
+				Rand:    "81e92b6c0ee0e12ebceba8d92a99dfa5",
+				Autn:    "bb52e91c747ac3ab2a5c23d15ee351d5",
+				Xres:    "28d7b0f2a2ec3de5",
+				CkPrime: "0093962d0dd84aa5684b045c9edffa04",
+				IkPrime: "ccfc230ca74fcc96c0a5d61164f5a76c",
+			},
+		},
+		{
+			// TS 35.232 Test Set 1
+			name: "tuak",
+			args: []string{
+				"--algorithm", "tuak", "--k", "abababababababababababababababab",
+				"--topc", "bd04d9530e87513c5d837ac2ad954623a8e2330c115305a73eb45d1f40cccbff", "--res-length", "32",
+				"--rand", "42424242424242424242424242424242", "--sqn", "111111111111", "--amf", "ffff",
+			},
+			wantAv: models.AuthenticationVector{
+				AvType: models.AvType_EAP_AKA_PRIME,
+				Rand:   "42424242424242424242424242424242",
+				Xres:   "657acd64",
+			},
+			wantAutnSuffix: "fffff9a54e6aeaa8618d",
+		},
+		{
+			name: "tuak without TOPc",
+			args: []string{
+				"--algorithm", "tuak", "--k", "abababababababababababababababab",
+				"--opc", "981d464c7c52eb6e5036234984ad0bcf", "--sqn", "111111111111",
+			},
+			wantErr: "EncTopcKey",
+		},
+		{
+			name: "tuak with an invalid RES length",
+			args: []string{
+				"--algorithm", "tuak", "--k", "abababababababababababababababab",
+				"--topc", "bd04d9530e87513c5d837ac2ad954623a8e2330c115305a73eb45d1f40cccbff", "--res-length", "48",
+				"--sqn", "111111111111",
+			},
+			wantErr: "RES",
+		},
+		{
+			name:    "unknown algorithm",
+			args:    []string{"--algorithm", "xor", "--k", "5122250214c33e723a5dd523fc145fc0", "--sqn", "16f3b3f70fc2"},
+			wantErr: "algorithm [xor]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := append([]string{"av", "compute", "--method", string(models.AuthMethod_EAP_AKA_PRIME),
+				"--serving-network-name", "WLAN"}, tt.args...)
+			out, err := runCommand(t, args...)
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			var av models.AuthenticationVector
+			require.NoError(t, json.Unmarshal([]byte(out), &av))
+			if tt.wantAutnSuffix != "" {
+				require.True(t, strings.HasSuffix(av.Autn, tt.wantAutnSuffix), av.Autn)
+				require.Len(t, av.CkPrime, 32)
+				require.Len(t, av.IkPrime, 32)
+				av.Autn, av.CkPrime, av.IkPrime = "", "", ""
+			}
+			require.Equal(t, tt.wantAv, av)
+		})
+	}
+}
+
+func TestAvComputeServingNetworkName(t *testing.T) {
+	_, err := runCommand(t, "av", "compute", "--k", "5122250214c33e723a5dd523fc145fc0",
+		"--opc", "981d464c7c52eb6e5036234984ad0bcf", "--sqn", "16f3b3f70fc2")
+	require.ErrorContains(t, err, "serving-network-name is required")
+}
@@ -39,6 +39,10 @@ func main() {
 			Usage: "Output NF log to `FILE`",
 		},
 	}
+	app.Commands = []cli.Command{
+		suciCommand(),
+		avCommand(),
+	}
 
 	if err := app.Run(os.Args); err != nil {
 		logger.MainLog.Errorf("UDM Run error: %v\n", err)
@@ -73,6 +77,15 @@ func action(cliCtx *cli.Context) error {
 		return err
 	}
 
+	// SIGHUP reloads the configuration; a rejected file is logged and the running one kept
+	hupCh := make(chan os.Signal, 1)
+	signal.Notify(hupCh, syscall.SIGHUP)
+	go func() {
+		for range hupCh {
+			_ = udm.ReloadConfig(cliCtx.String("config"))
+		}
+	}()
+
 	udm.Start()
 
 	return nil
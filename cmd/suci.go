@@ -0,0 +1,236 @@
+package main
+
+import (
+	"crypto/ecdh"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+	"gopkg.in/yaml.v2"
+
+	"github.com/free5gc/udm/internal/logger"
+	"github.com/free5gc/udm/pkg/factory"
+	"github.com/free5gc/udm/pkg/keyprovider"
+	"github.com/free5gc/udm/pkg/suci"
+)
+
+func suciCommand() cli.Command {
+	return cli.Command{
+		Name:   "suci",
+		Usage:  "Generate home network keys and conceal or de-conceal SUCIs offline",
+		Before: quietLog,
+		Subcommands: []cli.Command{
+			{
+				Name:   "keygen",
+				Usage:  "Generate a home network key pair and print its SuciProfile and SIM data",
+				Action: suciKeygenAction,
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "scheme, s",
+						Value: suci.ProfileAScheme,
+						Usage: "Protection scheme: 1 (profile A), 2 (profile B) or C (ML-KEM)",
+					},
+					cli.IntFlag{
+						Name:  "key-id, i",
+						Value: 1,
+						Usage: "Home network public key ID, 1-255",
+					},
+				},
+			},
+			{
+				Name:      "decode",
+				Usage:     "De-conceal a SUCI with the SUCI profiles of a configuration",
+				ArgsUsage: "SUCI",
+				Action:    suciDecodeAction,
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "config, c",
+						Usage: "Load configuration from `FILE`",
+					},
+				},
+			},
+			{
+				Name:      "encode",
+				Usage:     "Conceal a SUPI into a SUCI as a UE does",
+				ArgsUsage: "SUPI",
+				Action:    suciEncodeAction,
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "scheme, s",
+						Value: suci.NullScheme,
+						Usage: "Protection scheme: 0 (null), 1 (profile A), 2 (profile B) or C (ML-KEM)",
+					},
+					cli.StringFlag{
+						Name:  "public-key, k",
+						Usage: "Home network public key, hexadecimal",
+					},
+					cli.IntFlag{
+						Name:  "key-id, i",
+						Usage: "Home network public key ID, 1-255; 0 for the null scheme",
+					},
+					cli.StringFlag{
+						Name:  "routing-indicator, r",
+						Value: "0",
+						Usage: "Routing indicator, 1-4 digits",
+					},
+					cli.IntFlag{
+						Name:  "mnc-length",
+						Value: 2,
+						Usage: "Number of digits of the MNC of an IMSI",
+					},
+					cli.BoolFlag{
+						Name:  "uncompressed",
+						Usage: "Send the profile B ephemeral public key uncompressed",
+					},
+				},
+			},
+		},
+	}
+}
+
+// quietLog keeps the offline commands' output free of the UDM info logs
+func quietLog(_ *cli.Context) error {
+	logger.Log.SetLevel(logrus.WarnLevel)
+	return nil
+}
+
+func suciKeygenAction(cliCtx *cli.Context) error {
+	scheme := strings.ToUpper(cliCtx.String("scheme"))
+	keyID := cliCtx.Int("key-id")
+
+	var privateKey, publicKey []byte
+	switch scheme {
+	case suci.ProfileAScheme:
+		key, err := ecdh.X25519().GenerateKey(rand.Reader)
+		if err != nil {
+			return err
+		}
+		privateKey, publicKey = key.Bytes(), key.PublicKey().Bytes()
+	case suci.ProfileBScheme:
+		key, err := ecdh.P256().GenerateKey(rand.Reader)
+		if err != nil {
+			return err
+		}
+		x, y := elliptic.Unmarshal(elliptic.P256(), key.PublicKey().Bytes())
+		privateKey, publicKey = key.Bytes(), elliptic.MarshalCompressed(elliptic.P256(), x, y)
+	case suci.ProfileMlKemScheme:
+		var err error
+		if privateKey, publicKey, err = suci.GenerateMlKemKey(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("protection scheme [%s] should be %s, %s or %s", scheme,
+			suci.ProfileAScheme, suci.ProfileBScheme, suci.ProfileMlKemScheme)
+	}
+
+	suciProfiles := []suci.SuciProfile{
+		{
+			ProtectionScheme: scheme,
+			PublicKeyId:      keyID,
+			PrivateKey:       hex.EncodeToString(privateKey),
+			PublicKey:        hex.EncodeToString(publicKey),
+		},
+	}
+	if err := factory.ValidateSuciProfiles(suciProfiles); err != nil {
+		return err
+	}
+	out, err := yaml.Marshal(map[string][]suci.SuciProfile{"SuciProfile": suciProfiles})
+	if err != nil {
+		return err
+	}
+
+	schemeID, err := strconv.ParseUint(scheme, 16, 8)
+	if err != nil {
+		return err
+	}
+	w := cliCtx.App.Writer
+	fmt.Fprintf(w, "# UDM configuration\n%s\n", out)
+	fmt.Fprintln(w, "# SIM")
+	fmt.Fprintf(w, "# Home network public key identifier: %d\n", keyID)
+	fmt.Fprintf(w, "# Home network public key: %x\n", publicKey)
+	fmt.Fprintf(w, "# EF SUCI_Calc_Info: %x\n", suciCalcInfo(byte(schemeID), byte(keyID), publicKey))
+	return nil
+}
+
+// suciCalcInfo encodes the EF SUCI_Calc_Info of a USIM (TS 31.102 4.4.11.8) with the home
+// network public key as the only protection scheme
+func suciCalcInfo(schemeID, keyID byte, publicKey []byte) []byte {
+	// Key index 1, the first key of the home network public key list
+	schemeList := berTlv(0xa0, []byte{schemeID, 1})
+	keyList := berTlv(0xa1, append(berTlv(0x80, []byte{keyID}), berTlv(0x81, publicKey)...))
+	return append(schemeList, keyList...)
+}
+
+// berTlv encodes value with tag and a BER-TLV length
+func berTlv(tag byte, value []byte) []byte {
+	tlv := []byte{tag}
+	switch n := len(value); {
+	case n < 0x80:
+		tlv = append(tlv, byte(n))
+	case n <= 0xff:
+		tlv = append(tlv, 0x81, byte(n))
+	default:
+		tlv = append(tlv, 0x82, byte(n>>8), byte(n))
+	}
+	return append(tlv, value...)
+}
+
+func suciDecodeAction(cliCtx *cli.Context) error {
+	if cliCtx.NArg() != 1 {
+		return fmt.Errorf("usage: %s suci decode --config FILE SUCI", cliCtx.App.Name)
+	}
+
+	cfg, err := factory.ReadConfig(cliCtx.String("config"))
+	if err != nil {
+		return err
+	}
+	configuration := cfg.Configuration
+	suciProfiles := configuration.SuciProfiles
+	if configuration.SuciProfileFile != "" {
+		if suciProfiles, err = factory.ReadSuciProfileFile(configuration.SuciProfileFile); err != nil {
+			return err
+		}
+	}
+	provider, err := keyprovider.New(configuration.KeyProvider)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if errClose := provider.Close(); errClose != nil {
+			logger.MainLog.Warnf("Close key provider failed: %+v", errClose)
+		}
+	}()
+	for i := range suciProfiles {
+		suciProfiles[i].KeyProvider = provider
+	}
+
+	supi, err := suci.ToSupi(cliCtx.Args().First(), suciProfiles)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(cliCtx.App.Writer, supi)
+	return nil
+}
+
+func suciEncodeAction(cliCtx *cli.Context) error {
+	if cliCtx.NArg() != 1 {
+		return fmt.Errorf("usage: %s suci encode [--scheme S --public-key HEX --key-id ID] SUPI", cliCtx.App.Name)
+	}
+
+	opts := []suci.ConcealOption{suci.WithMncLength(cliCtx.Int("mnc-length"))}
+	if cliCtx.Bool("uncompressed") {
+		opts = append(opts, suci.WithUncompressedPublicKey())
+	}
+	suciString, err := suci.FromSupi(cliCtx.Args().First(), cliCtx.String("scheme"), cliCtx.String("public-key"),
+		cliCtx.Int("key-id"), cliCtx.String("routing-indicator"), opts...)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(cliCtx.App.Writer, suciString)
+	return nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli"
+	"gopkg.in/yaml.v2"
+
+	"github.com/free5gc/udm/pkg/suci"
+)
+
+// runCommand runs the udm command line of args and returns its output
+func runCommand(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	var out bytes.Buffer
+	app := cli.NewApp()
+	app.Name = "udm"
+	app.Writer = &out
+	app.Commands = []cli.Command{
+		suciCommand(),
+		avCommand(),
+	}
+	err := app.Run(append([]string{"udm"}, args...))
+	return out.String(), err
+}
+
+// writeTestConfig writes a configuration loading the SUCI profiles of suciProfileFile
+func writeTestConfig(t *testing.T, suciProfileFile string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "udmcfg.yaml")
+	content := `info:
+  version: 1.0.3
+configuration:
+  sbi:
+    scheme: http
+    registerIPv4: 127.0.0.3
+    bindingIPv4: 127.0.0.3
+    port: 8000
+  serviceNameList:
+    - nudm-ueau
+  nrfUri: http://127.0.0.10:8000
+  suciProfileFile: ` + suciProfileFile + `
+logger:
+  enable: true
+  level: info
+  reportCaller: false
+`
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestSuciRoundTrip(t *testing.T) {
+	const supi = "imsi-208930000000001"
+	for _, scheme := range []string{suci.ProfileAScheme, suci.ProfileBScheme, suci.ProfileMlKemScheme} {
+		t.Run("scheme "+scheme, func(t *testing.T) {
+			keyID := "7"
+			out, err := runCommand(t, "suci", "keygen", "--scheme", scheme, "--key-id", keyID)
+			if errors.Is(err, suci.ErrUnsupportedProtectionScheme) {
+				t.Skipf("protection scheme [%s] is not supported by this build", scheme)
+			}
+			require.NoError(t, err)
+
+			// The keygen output is a suciProfileFile
+			suciProfileFile := filepath.Join(t.TempDir(), "suci.yaml")
+			require.NoError(t, os.WriteFile(suciProfileFile, []byte(out), 0o600))
+			var keygen struct {
+				SuciProfiles []suci.SuciProfile `yaml:"SuciProfile"`
+			}
+			require.NoError(t, yaml.Unmarshal([]byte(out), &keygen))
+			require.Len(t, keygen.SuciProfiles, 1)
+			require.Equal(t, scheme, keygen.SuciProfiles[0].ProtectionScheme)
+			require.Contains(t, out, "# Home network public key: "+keygen.SuciProfiles[0].PublicKey)
+
+			out, err = runCommand(t, "suci", "encode", "--scheme", scheme,
+				"--public-key", keygen.SuciProfiles[0].PublicKey, "--key-id", keyID, supi)
+			require.NoError(t, err)
+			suciString := strings.TrimSpace(out)
+			require.True(t, strings.HasPrefix(suciString, "suci-0-208-93-0-"+scheme+"-"+keyID+"-"), suciString)
+
+			out, err = runCommand(t, "suci", "decode", "--config", writeTestConfig(t, suciProfileFile), suciString)
+			require.NoError(t, err)
+			require.Equal(t, supi, strings.TrimSpace(out))
+		})
+	}
+}
+
+func TestSuciNullScheme(t *testing.T) {
+	out, err := runCommand(t, "suci", "encode", "imsi-2089300007487")
+	require.NoError(t, err)
+	require.Equal(t, "suci-0-208-93-0-0-0-00007487", strings.TrimSpace(out))
+
+	suciProfileFile := filepath.Join(t.TempDir(), "suci.yaml")
+	require.NoError(t, os.WriteFile(suciProfileFile, []byte("SuciProfile: []\n"), 0o600))
+	out, err = runCommand(t, "suci", "decode", "--config", writeTestConfig(t, suciProfileFile),
+		strings.TrimSpace(out))
+	require.NoError(t, err)
+	require.Equal(t, "imsi-2089300007487", strings.TrimSpace(out))
+}
+
+func TestSuciKeygenInvalid(t *testing.T) {
+	_, err := runCommand(t, "suci", "keygen", "--scheme", "3")
+	require.ErrorContains(t, err, "protection scheme [3]")
+
+	_, err = runCommand(t, "suci", "keygen", "--key-id", strconv.Itoa(256))
+	require.Error(t, err)
+}
+
+func TestSuciCalcInfo(t *testing.T) {
+	// TS 31.102 4.4.11.8: protection scheme list of scheme 1 at key index 1, then the key of ID 7
+	require.Equal(t, []byte{0xa0, 0x02, 0x01, 0x01, 0xa1, 0x07, 0x80, 0x01, 0x07, 0x81, 0x02, 0xab, 0xcd},
+		suciCalcInfo(1, 7, []byte{0xab, 0xcd}))
+	// Public keys of 128 octets and more have a multi-octet BER-TLV length
+	require.Equal(t, []byte{0x81, 0x81, 0x80}, berTlv(0x81, make([]byte, 0x80))[:3])
+	require.Equal(t, []byte{0x81, 0x82, 0x04, 0x20}, berTlv(0x81, make([]byte, 0x420))[:4])
+}
@@ -0,0 +1,94 @@
+package context
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/free5gc/openapi"
+	"github.com/free5gc/openapi/models"
+	"github.com/free5gc/openapi/nrf/AccessToken"
+)
+
+// SetTokenHTTPClient makes the access tokens be requested with httpClient, the client of the NF
+// services consumed, so that the token requests to the NRF also use mutual TLS. Without it they
+// are sent with the default clients of openapi.
+func (c *UDMContext) SetTokenHTTPClient(httpClient *http.Client) {
+	c.nrfMu.Lock()
+	defer c.nrfMu.Unlock()
+	c.tokenHTTPClient = httpClient
+}
+
+// invalidateAccessTokens drops the access tokens reused, issued by an NRF the UDM no longer uses
+// or verified with a certificate it no longer trusts
+func (c *UDMContext) invalidateAccessTokens() {
+	c.accessTokens.Range(func(request, _ any) bool {
+		c.accessTokens.Delete(request)
+		return true
+	})
+}
+
+// accessTokenRequest is the access token request of the UDM (TS 29.510 6.3.5.2.3) apart from its
+// NF instance ID and NF type, which identifies the access tokens reused
+type accessTokenRequest struct {
+	targetNfType       models.NrfNfManagementNfType
+	targetNfInstanceId string // empty for a token of any NF instance of targetNfType
+	scope              string
+}
+
+// accessTokenCtx returns the context of the requests authorized by the access token of request
+func (c *UDMContext) accessTokenCtx(request accessTokenRequest) (context.Context, error) {
+	token, err := c.accessToken(request)
+	if err != nil {
+		return nil, err
+	}
+	return context.WithValue(context.Background(), openapi.ContextOAuth2, oauth2.StaticTokenSource(token)), nil
+}
+
+// accessToken returns the access token of request, requested to the NRF (TS 29.510 5.4.2.2) and
+// reused for the same request until it expires
+func (c *UDMContext) accessToken(request accessTokenRequest) (*oauth2.Token, error) {
+	if value, ok := c.accessTokens.Load(request); ok {
+		if token := value.(*oauth2.Token); token.Valid() {
+			return token, nil
+		}
+	}
+
+	c.nrfMu.RLock()
+	configuration := AccessToken.NewConfiguration()
+	configuration.SetBasePath(c.NrfUri)
+	if c.tokenHTTPClient != nil {
+		configuration.SetHTTPClient(c.tokenHTTPClient)
+	}
+	c.nrfMu.RUnlock()
+	client := AccessToken.NewAPIClient(configuration)
+
+	req := &AccessToken.AccessTokenRequestRequest{}
+	req.SetGrantType("client_credentials")
+	req.SetNfInstanceId(c.NfId)
+	req.SetNfType(models.NrfNfManagementNfType_UDM)
+	req.SetTargetNfType(request.targetNfType)
+	if request.targetNfInstanceId != "" {
+		req.SetTargetNfInstanceId(request.targetNfInstanceId)
+	}
+	req.SetScope(request.scope)
+	rsp, err := client.AccessTokenRequestApi.AccessTokenRequest(context.Background(), req)
+	if err != nil {
+		return nil, fmt.Errorf("access token request: %w", err)
+	}
+
+	accessTokenRsp := rsp.NrfAccessTokenAccessTokenRsp
+	token := &oauth2.Token{
+		AccessToken: accessTokenRsp.AccessToken,
+		TokenType:   accessTokenRsp.TokenType,
+	}
+	// expires_in is the lifetime of the token in seconds, a token without one is not reused
+	if accessTokenRsp.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(accessTokenRsp.ExpiresIn) * time.Second)
+		c.accessTokens.Store(request, token)
+	}
+	return token, nil
+}
@@ -0,0 +1,143 @@
+package context
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"golang.org/x/oauth2"
+
+	"github.com/free5gc/openapi"
+	"github.com/free5gc/openapi/models"
+)
+
+// countingTransport counts the requests sent with the client of the NF services consumed
+type countingTransport struct {
+	transport http.RoundTripper
+	requests  atomic.Int32
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.requests.Add(1)
+	return t.transport.RoundTrip(req)
+}
+
+func TestGetTokenCtxHTTPClient(t *testing.T) {
+	tests := []struct {
+		name      string
+		expiresIn int32
+		// token requests of two calls
+		wantRequests int32
+	}{
+		{
+			name:         "token reused",
+			expiresIn:    60,
+			wantRequests: 1,
+		},
+		{
+			name:         "token without lifetime",
+			wantRequests: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nrf := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				require.NoError(t, r.ParseForm())
+				require.Equal(t, "/oauth2/token", r.URL.Path)
+				require.Equal(t, "nudr-dr", r.PostForm.Get("scope"))
+				w.Header().Set("Content-Type", "application/json")
+				_ = json.NewEncoder(w).Encode(models.NrfAccessTokenAccessTokenRsp{
+					AccessToken: "token-" + r.PostForm.Get("nfInstanceId"),
+					TokenType:   "Bearer",
+					ExpiresIn:   tt.expiresIn,
+				})
+			})
+			server := httptest.NewServer(h2c.NewHandler(nrf, &http2.Server{}))
+			defer server.Close()
+
+			transport := &countingTransport{transport: &http2.Transport{
+				AllowHTTP: true,
+				DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+					var dialer net.Dialer
+					return dialer.DialContext(ctx, network, addr)
+				},
+			}}
+			udmContext := &UDMContext{NfId: "udm-1"}
+			udmContext.SetNrf(server.URL, "")
+			udmContext.SetOAuth2Required(true)
+			udmContext.SetTokenHTTPClient(&http.Client{Transport: transport})
+
+			for i := 0; i < 2; i++ {
+				ctx, _, err := udmContext.GetTokenCtx(models.ServiceName_NUDR_DR, models.NrfNfManagementNfType_UDR)
+				require.NoError(t, err)
+				token, err := ctx.Value(openapi.ContextOAuth2).(oauth2.TokenSource).Token()
+				require.NoError(t, err)
+				require.Equal(t, "token-udm-1", token.AccessToken)
+			}
+			require.Equal(t, tt.wantRequests, transport.requests.Load())
+		})
+	}
+}
+
+func TestAccessTokenRequestKey(t *testing.T) {
+	var requests atomic.Int32
+	nrf := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		require.NoError(t, r.ParseForm())
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(models.NrfAccessTokenAccessTokenRsp{
+			AccessToken: "token-" + r.PostForm.Get("targetNfType") + "-" + r.PostForm.Get("targetNfInstanceId"),
+			TokenType:   "Bearer",
+			ExpiresIn:   60,
+		})
+	})
+	server := httptest.NewServer(h2c.NewHandler(nrf, &http2.Server{}))
+	defer server.Close()
+
+	// Without a client of the NF services consumed, the default clients of openapi are used
+	udmContext := &UDMContext{NfId: "udm-1"}
+	udmContext.SetNrf(server.URL, "")
+	tests := []struct {
+		request     accessTokenRequest
+		wantToken   string
+		wantRequest int32
+	}{
+		{
+			request:     accessTokenRequest{targetNfType: models.NrfNfManagementNfType_UDR, scope: "nudr-dr"},
+			wantToken:   "token-UDR-",
+			wantRequest: 1,
+		},
+		{
+			request: accessTokenRequest{
+				targetNfType: models.NrfNfManagementNfType_UDR, targetNfInstanceId: "udr-2", scope: "nudr-dr",
+			},
+			wantToken:   "token-UDR-udr-2",
+			wantRequest: 2,
+		},
+		{
+			request:     accessTokenRequest{targetNfType: models.NrfNfManagementNfType_UDR, scope: "nudr-dr"},
+			wantToken:   "token-UDR-",
+			wantRequest: 2,
+		},
+		{
+			// The same scope for another target NF type is another token
+			request:     accessTokenRequest{targetNfType: models.NrfNfManagementNfType_UDM, scope: "nudr-dr"},
+			wantToken:   "token-UDM-",
+			wantRequest: 3,
+		},
+	}
+	for _, tt := range tests {
+		token, err := udmContext.accessToken(tt.request)
+		require.NoError(t, err)
+		require.Equal(t, tt.wantToken, token.AccessToken)
+		require.Equal(t, tt.wantRequest, requests.Load())
+	}
+}
@@ -0,0 +1,45 @@
+package context
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/free5gc/openapi/models"
+	"github.com/free5gc/udm/pkg/factory"
+)
+
+func TestStoreAuthEvent(t *testing.T) {
+	const (
+		supi      = "imsi-208930000000001"
+		otherSupi = "imsi-208930000000002"
+	)
+
+	udmContext := &UDMContext{}
+	udmContext.StoreAuthEvent(supi, &models.AuthEvent{Success: true})
+	_, ok := udmContext.LoadAuthEvent(supi)
+	require.False(t, ok, "stored with the check disabled")
+
+	udmContext.AuthLink = &factory.AuthLink{Mode: factory.AuthLinkModeReject, Window: 1}
+	udmContext.StoreAuthEvent(supi, &models.AuthEvent{Success: true})
+	udmContext.StoreAuthEvent(otherSupi, &models.AuthEvent{Success: true})
+	_, ok = udmContext.LoadAuthEvent(supi)
+	require.True(t, ok)
+
+	// dropped on lookup once outside the window
+	time.Sleep(1100 * time.Millisecond)
+	_, ok = udmContext.LoadAuthEvent(supi)
+	require.False(t, ok)
+	_, ok = udmContext.authEvents.Load(supi)
+	require.False(t, ok)
+
+	// swept on the next store once outside the window, without lookup
+	_, ok = udmContext.authEvents.Load(otherSupi)
+	require.True(t, ok)
+	udmContext.StoreAuthEvent(supi, &models.AuthEvent{Success: true})
+	_, ok = udmContext.authEvents.Load(otherSupi)
+	require.False(t, ok)
+	_, ok = udmContext.LoadAuthEvent(supi)
+	require.True(t, ok)
+}
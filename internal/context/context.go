@@ -2,12 +2,17 @@ package context
 
 import (
 	"context"
+	"crypto/x509"
 	"fmt"
+	"hash/fnv"
 	"math"
+	"net/http"
 	"os"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/google/uuid"
 
@@ -17,6 +22,7 @@ import (
 	"github.com/free5gc/openapi/oauth"
 	"github.com/free5gc/udm/internal/logger"
 	"github.com/free5gc/udm/pkg/factory"
+	"github.com/free5gc/udm/pkg/keyprovider"
 	"github.com/free5gc/udm/pkg/suci"
 	"github.com/free5gc/util/idgenerator"
 )
@@ -31,14 +37,24 @@ const (
 	LocationUriSharedDataSubscription
 )
 
-func Init() {
+// UeidAllowedNfTypes are the NF types allowed to de-conceal SUCIs with the Nudm_UEID service,
+// the NFs authenticating UEs identified by a SUCI. They are advertised as the allowedNfTypes
+// of the service, which the NRF enforces when granting access tokens (TS 29.510 6.3.5.2.4), and
+// checked by the UDM on every request, with OAuth2 or not
+var UeidAllowedNfTypes = []models.NrfNfManagementNfType{
+	models.NrfNfManagementNfType_AUSF,
+	models.NrfNfManagementNfType_NSSAAF,
+}
+
+func Init() error {
 	GetSelf().NfService = make(map[models.ServiceName]models.NrfNfManagementNfService)
 	GetSelf().EeSubscriptionIDGenerator = idgenerator.NewGenerator(1, math.MaxInt32)
-	InitUdmContext(GetSelf())
+	return InitUdmContext(GetSelf())
 }
 
 type NFContext interface {
 	AuthorizationCheck(token string, serviceName models.ServiceName) error
+	CheckNfInstanceId(token string, peerCertificates []*x509.Certificate) error
 }
 
 var _ NFContext = &UDMContext{}
@@ -48,19 +64,42 @@ type UDMContext struct {
 	GroupId                        string
 	SBIPort                        int
 	RegisterIPv4                   string // IP register to NRF
+	RegisterIPv6                   string // IPv6 register to NRF
 	BindingIPv4                    string
+	BindingIPv6                    string
 	UriScheme                      models.UriScheme
 	NfService                      map[models.ServiceName]models.NrfNfManagementNfService
 	NFDiscoveryClient              *Nnrf_NFDiscovery.APIClient
-	UdmUePool                      sync.Map // map[supi]*UdmUeContext
+	UdmUePool                      sync.Map     // map[supi]*UdmUeContext
+	nrfMu                          sync.RWMutex // of NrfUri, NrfCertPem, OAuth2Required, HeartBeatTimer, tokenHTTPClient
 	NrfUri                         string
 	NrfCertPem                     string
+	HeartBeatTimer                 int32 // seconds between the heartbeats the NRF expects
 	GpsiSupiList                   models.IdentityData
 	SharedSubsDataMap              map[string]models.UdmSdmSharedData // sharedDataIds as key
 	SubscriptionOfSharedDataChange sync.Map                           // subscriptionID as key
-	SuciProfiles                   []suci.SuciProfile
+	suciProfiles                   atomic.Pointer[[]suci.SuciProfile]
+	suciProfileFileMu              sync.Mutex
+	suciProfileFileInfo            os.FileInfo     // of the suciProfileFile when last read
+	PlmnSupportList                []models.PlmnId // home networks of the SUCIs served
+	udmInfoMu                      sync.RWMutex    // of GroupId, the ranges and RoutingIndicators
+	RoutingIndicators              []string        // of the SUCIs served
+	SupiRanges                     []models.SupiRange
+	GpsiRanges                     []models.IdentityRange
+	ExternalGroupIdentifiersRanges []models.IdentityRange
+	TuakProfiles                   []factory.TuakProfile
+	KeyEncryptionKeys              []factory.KeyEncryptionKey
+	KeyProvider                    keyprovider.KeyProvider
+	Sqn                            *factory.Sqn
+	sqnLocks                       [sqnLockStripes]sync.Mutex // by hash of the SUPI
+	AuthLink                       *factory.AuthLink
+	authEvents                     sync.Map     // map[supi]*storedAuthEvent
+	authEventsSweep                atomic.Int64 // time of the next sweep of authEvents, in ns
 	EeSubscriptionIDGenerator      *idgenerator.IDGenerator
 	OAuth2Required                 bool
+	tokenHTTPClient                *http.Client // of the access token requests, nil for the default clients of openapi
+	accessTokens                   sync.Map     // map[accessTokenRequest]*oauth2.Token
+	NfInstanceIdCheck              bool         // of the client certificates against the access tokens, under nrfMu
 }
 
 type UdmUeContext struct {
@@ -100,7 +139,7 @@ type UdmNFContext struct {
 	SubscribeToNotifSharedDataChange *models.SdmSubscription // SubscriptionID as key
 }
 
-func InitUdmContext(context *UDMContext) {
+func InitUdmContext(context *UDMContext) error {
 	config := factory.UdmConfig
 	logger.UtilLog.Info("udmconfig Info: Version[", config.Info.Version, "] Description[", config.Info.Description, "]")
 	configuration := config.Configuration
@@ -113,17 +152,23 @@ func InitUdmContext(context *UDMContext) {
 		if sbi.Scheme != "" {
 			udmContext.UriScheme = models.UriScheme(sbi.Scheme)
 		}
-		if sbi.RegisterIPv4 != "" {
-			udmContext.RegisterIPv4 = sbi.RegisterIPv4
-		}
+		// Both addresses are registered for dual-stack, Sbi.validate requires at least one of them
+		udmContext.RegisterIPv4 = sbi.RegisterIPv4
+		udmContext.RegisterIPv6 = sbi.RegisterIPv6
 		if sbi.Port != 0 {
 			udmContext.SBIPort = sbi.Port
 		}
 
+		udmContext.BindingIPv6 = os.Getenv(sbi.BindingIPv6)
+		if udmContext.BindingIPv6 != "" {
+			logger.UtilLog.Info("Parsing ServerIPv6 address from ENV Variable.")
+		} else {
+			udmContext.BindingIPv6 = sbi.BindingIPv6
+		}
 		udmContext.BindingIPv4 = os.Getenv(sbi.BindingIPv4)
 		if udmContext.BindingIPv4 != "" {
 			logger.UtilLog.Info("Parsing ServerIPv4 address from ENV Variable.")
-		} else {
+		} else if udmContext.BindingIPv6 == "" || sbi.BindingIPv4 != "" {
 			udmContext.BindingIPv4 = sbi.BindingIPv4
 			if udmContext.BindingIPv4 == "" {
 				logger.UtilLog.Warn("Error parsing ServerIPv4 address as string. Using the 0.0.0.0 address as default.")
@@ -135,9 +180,84 @@ func InitUdmContext(context *UDMContext) {
 	context.NrfCertPem = configuration.NrfCertPem
 	servingNameList := configuration.ServiceNameList
 
-	udmContext.SuciProfiles = configuration.SuciProfiles
+	if err := udmContext.InitKeys(configuration); err != nil {
+		return err
+	}
+	udmContext.PlmnSupportList = configuration.PlmnSupportList
+	udmContext.SetUdmInfo(configuration.UdmInfo, configuration.RoutingIndicators)
+	udmContext.TuakProfiles = configuration.TuakProfiles
+	udmContext.Sqn = configuration.Sqn
+	udmContext.AuthLink = configuration.AuthLink
+	udmContext.NfInstanceIdCheck = config.GetNfInstanceIdCheck()
 
 	udmContext.InitNFService(servingNameList, config.Info.Version)
+	return nil
+}
+
+// InitKeys opens the key provider and sets up the SUCI profiles and key-encryption keys using it.
+// The UDM cannot serve without them, so an error stops its start.
+func (context *UDMContext) InitKeys(configuration *factory.Configuration) error {
+	provider, err := keyprovider.New(configuration.KeyProvider)
+	if err != nil {
+		return fmt.Errorf("init key provider: %w", err)
+	}
+	context.KeyProvider = provider
+
+	if configuration.SuciProfileFile != "" {
+		if err = context.ReloadSuciProfileFile(configuration.SuciProfileFile); err != nil {
+			return fmt.Errorf("init SUCI profiles: %w", err)
+		}
+	} else {
+		context.SetSuciProfiles(configuration.SuciProfiles, "configuration")
+	}
+	context.KeyEncryptionKeys = configuration.KeyEncryptionKeys
+	return nil
+}
+
+// ServedPlmnIds returns the PLMNs of the SUCIs served, as MCC followed by MNC
+func (context *UDMContext) ServedPlmnIds() []string {
+	plmnIds := make([]string, 0, len(context.PlmnSupportList))
+	for _, plmnId := range context.PlmnSupportList {
+		plmnIds = append(plmnIds, plmnId.Mcc+plmnId.Mnc)
+	}
+	return plmnIds
+}
+
+// SetUdmInfo sets the UDM group, identity ranges and routing indicators the UDM serves. The
+// GroupId is left as is when udmInfo configures none.
+func (context *UDMContext) SetUdmInfo(udmInfo *factory.UdmInfo, routingIndicators []string) {
+	context.udmInfoMu.Lock()
+	defer context.udmInfoMu.Unlock()
+	context.RoutingIndicators = routingIndicators
+	if udmInfo == nil {
+		udmInfo = &factory.UdmInfo{}
+	}
+	if udmInfo.GroupId != "" {
+		context.GroupId = udmInfo.GroupId
+	}
+	context.SupiRanges = udmInfo.SupiRanges
+	context.GpsiRanges = udmInfo.GpsiRanges
+	context.ExternalGroupIdentifiersRanges = udmInfo.ExternalGroupIdentifiersRanges
+}
+
+// GetRoutingIndicators returns the routing indicators of the SUCIs served, any when empty
+func (context *UDMContext) GetRoutingIndicators() []string {
+	context.udmInfoMu.RLock()
+	defer context.udmInfoMu.RUnlock()
+	return context.RoutingIndicators
+}
+
+// GetUdmInfo returns the UdmInfo registered in the NF profile (TS 29.510 6.1.6.2.7)
+func (context *UDMContext) GetUdmInfo() *models.UdmInfo {
+	context.udmInfoMu.RLock()
+	defer context.udmInfoMu.RUnlock()
+	return &models.UdmInfo{
+		GroupId:                        context.GroupId,
+		SupiRanges:                     context.SupiRanges,
+		GpsiRanges:                     context.GpsiRanges,
+		ExternalGroupIdentifiersRanges: context.ExternalGroupIdentifiersRanges,
+		RoutingIndicators:              context.RoutingIndicators,
+	}
 }
 
 func (context *UDMContext) ManageSmData(smDatafromUDR []models.SessionManagementSubscriptionData, snssaiFromReq string,
@@ -290,6 +410,66 @@ func (context *UDMContext) NewUdmUe(supi string) *UdmUeContext {
 	return ue
 }
 
+// number of locks the SQN updates of the subscribers are spread over
+const sqnLockStripes = 256
+
+// LockSqn serialises the SQN updates of supi within this UDM, it returns the unlock function. The
+// subscribers share a fixed set of locks, so that the locks do not grow with the subscribers served.
+func (context *UDMContext) LockSqn(supi string) func() {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(supi))
+	mu := &context.sqnLocks[h.Sum32()%sqnLockStripes]
+	mu.Lock()
+	return mu.Unlock
+}
+
+// storedAuthEvent is an authentication result linking the registrations until expiry
+type storedAuthEvent struct {
+	authEvent *models.AuthEvent
+	expiry    time.Time
+}
+
+// StoreAuthEvent remembers the last authentication result of supi confirmed by the AUSF for the
+// window of the authentication link check, after which it no longer links a registration. The
+// expired results are dropped on lookup, and swept at most once per window on store.
+func (context *UDMContext) StoreAuthEvent(supi string, authEvent *models.AuthEvent) {
+	if context.AuthLink.GetMode() == factory.AuthLinkModeOff {
+		return
+	}
+	now := time.Now()
+	window := context.AuthLink.GetWindow()
+	context.authEvents.Store(supi, &storedAuthEvent{authEvent: authEvent, expiry: now.Add(window)})
+
+	// Only the store winning the swap sweeps
+	nextSweep := context.authEventsSweep.Load()
+	if now.UnixNano() < nextSweep {
+		return
+	}
+	if !context.authEventsSweep.CompareAndSwap(nextSweep, now.Add(window).UnixNano()) {
+		return
+	}
+	context.authEvents.Range(func(supi, value any) bool {
+		if now.After(value.(*storedAuthEvent).expiry) {
+			context.authEvents.CompareAndDelete(supi, value)
+		}
+		return true
+	})
+}
+
+// LoadAuthEvent returns the last authentication result of supi confirmed to this UDM
+func (context *UDMContext) LoadAuthEvent(supi string) (*models.AuthEvent, bool) {
+	value, ok := context.authEvents.Load(supi)
+	if !ok {
+		return nil, false
+	}
+	stored := value.(*storedAuthEvent)
+	if time.Now().After(stored.expiry) {
+		context.authEvents.CompareAndDelete(supi, value)
+		return nil, false
+	}
+	return stored.authEvent, true
+}
+
 func (context *UDMContext) UdmUeFindBySupi(supi string) (*UdmUeContext, bool) {
 	if value, ok := context.UdmUePool.Load(supi); ok {
 		return value.(*UdmUeContext), ok
@@ -400,12 +580,12 @@ func (context *UDMContext) GetAmfNon3gppRegContext(supi string) *models.AmfNon3G
 func (ue *UdmUeContext) GetLocationURI(types int) string {
 	switch types {
 	case LocationUriAmf3GppAccessRegistration:
-		return GetSelf().GetIPv4Uri() + factory.UdmUecmResUriPrefix + "/" + ue.Supi + "/registrations/amf-3gpp-access"
+		return GetSelf().GetIPUri() + factory.UdmUecmResUriPrefix + "/" + ue.Supi + "/registrations/amf-3gpp-access"
 	case LocationUriAmfNon3GppAccessRegistration:
-		return GetSelf().GetIPv4Uri() + factory.UdmUecmResUriPrefix + "/" + ue.Supi + "/registrations/amf-non-3gpp-access"
+		return GetSelf().GetIPUri() + factory.UdmUecmResUriPrefix + "/" + ue.Supi + "/registrations/amf-non-3gpp-access"
 	case LocationUriSmfRegistration:
 
-		return GetSelf().GetIPv4Uri() +
+		return GetSelf().GetIPUri() +
 			factory.UdmUecmResUriPrefix + "/" + ue.Supi + "/registrations/smf-registrations/" + ue.PduSessionID
 	}
 	return ""
@@ -414,9 +594,9 @@ func (ue *UdmUeContext) GetLocationURI(types int) string {
 func (ue *UdmUeContext) GetLocationURI2(types int, supi string) string {
 	switch types {
 	case LocationUriSharedDataSubscription:
-		// return GetSelf().GetIPv4Uri() + UdmSdmResUriPrefix +"/shared-data-subscriptions/" + nf.SubscriptionID
+		// return GetSelf().GetIPUri() + UdmSdmResUriPrefix +"/shared-data-subscriptions/" + nf.SubscriptionID
 	case LocationUriSdmSubscription:
-		return GetSelf().GetIPv4Uri() + factory.UdmSdmResUriPrefix + "/" + supi + "/sdm-subscriptions/"
+		return GetSelf().GetIPUri() + factory.UdmSdmResUriPrefix + "/" + supi + "/sdm-subscriptions/"
 	}
 	return ""
 }
@@ -459,9 +639,46 @@ func (context *UDMContext) GetIPv4Uri() string {
 	return fmt.Sprintf("%s://%s:%d", context.UriScheme, context.RegisterIPv4, context.SBIPort)
 }
 
+func (context *UDMContext) GetIPv6Uri() string {
+	return fmt.Sprintf("%s://[%s]:%d", context.UriScheme, context.RegisterIPv6, context.SBIPort)
+}
+
+// GetIPUri returns the URI of the UDM on its IPv4 address, or on its IPv6 one when IPv6-only
+func (context *UDMContext) GetIPUri() string {
+	if context.RegisterIPv4 == "" && context.RegisterIPv6 != "" {
+		return context.GetIPv6Uri()
+	}
+	return context.GetIPv4Uri()
+}
+
+// ipEndPoints returns the IP endpoints of the NF services of the UDM, one per address family
+func (context *UDMContext) ipEndPoints() []models.IpEndPoint {
+	var ipEndPoints []models.IpEndPoint
+	if context.RegisterIPv4 != "" {
+		ipEndPoints = append(ipEndPoints, models.IpEndPoint{
+			Ipv4Address: context.RegisterIPv4,
+			Transport:   models.NrfNfManagementTransportProtocol_TCP,
+			Port:        int32(context.SBIPort),
+		})
+	}
+	if context.RegisterIPv6 != "" {
+		ipEndPoints = append(ipEndPoints, models.IpEndPoint{
+			Ipv6Address: context.RegisterIPv6,
+			Transport:   models.NrfNfManagementTransportProtocol_TCP,
+			Port:        int32(context.SBIPort),
+		})
+	}
+	return ipEndPoints
+}
+
 // GetSDMUri ... get subscriber data management service uri
 func (context *UDMContext) GetSDMUri() string {
-	return context.GetIPv4Uri() + factory.UdmSdmResUriPrefix
+	return context.GetIPUri() + factory.UdmSdmResUriPrefix
+}
+
+// GetUEAUUri ... get UE authentication service uri
+func (context *UDMContext) GetUEAUUri() string {
+	return context.GetIPUri() + factory.UdmUeauResUriPrefix
 }
 
 func (context *UDMContext) InitNFService(serviceName []string, version string) {
@@ -469,7 +686,7 @@ func (context *UDMContext) InitNFService(serviceName []string, version string) {
 	versionUri := "v" + tmpVersion[0]
 	for index, nameString := range serviceName {
 		name := models.ServiceName(nameString)
-		context.NfService[name] = models.NrfNfManagementNfService{
+		nfService := models.NrfNfManagementNfService{
 			ServiceInstanceId: strconv.Itoa(index),
 			ServiceName:       name,
 			Versions: []models.NfServiceVersion{
@@ -480,26 +697,81 @@ func (context *UDMContext) InitNFService(serviceName []string, version string) {
 			},
 			Scheme:          context.UriScheme,
 			NfServiceStatus: models.NfServiceStatus_REGISTERED,
-			ApiPrefix:       context.GetIPv4Uri(),
-			IpEndPoints: []models.IpEndPoint{
-				{
-					Ipv4Address: context.RegisterIPv4,
-					Transport:   models.NrfNfManagementTransportProtocol_TCP,
-					Port:        int32(context.SBIPort),
-				},
-			},
+			ApiPrefix:       context.GetIPUri(),
+			IpEndPoints:     context.ipEndPoints(),
+		}
+		if name == models.ServiceName_NUDM_UEID {
+			nfService.AllowedNfTypes = UeidAllowedNfTypes
 		}
+		context.NfService[name] = nfService
 	}
 }
 
 func (c *UDMContext) GetTokenCtx(serviceName models.ServiceName, targetNF models.NrfNfManagementNfType) (
 	context.Context, *models.ProblemDetails, error,
 ) {
-	if !c.OAuth2Required {
+	if !c.IsOAuth2Required() {
 		return context.TODO(), nil, nil
 	}
-	return oauth.GetTokenCtx(models.NrfNfManagementNfType_UDM, targetNF,
-		c.NfId, c.NrfUri, string(serviceName))
+	ctx, err := c.accessTokenCtx(accessTokenRequest{targetNfType: targetNF, scope: string(serviceName)})
+	if err != nil {
+		logger.ConsumerLog.Errorf("Access token request for [%s] error: %+v", serviceName, err)
+		return nil, nil, err
+	}
+	return ctx, nil, nil
+}
+
+// GetNrfUri, GetNrfCertPem and IsOAuth2Required return the NRF settings, which a configuration
+// reload or a registration to the NRF may change at any time
+func (context *UDMContext) GetNrfUri() string {
+	context.nrfMu.RLock()
+	defer context.nrfMu.RUnlock()
+	return context.NrfUri
+}
+
+func (context *UDMContext) GetNrfCertPem() string {
+	context.nrfMu.RLock()
+	defer context.nrfMu.RUnlock()
+	return context.NrfCertPem
+}
+
+func (context *UDMContext) IsOAuth2Required() bool {
+	context.nrfMu.RLock()
+	defer context.nrfMu.RUnlock()
+	return context.OAuth2Required
+}
+
+// SetNrf sets the NRF of the UDM and the certificate verifying the access tokens it issues. The
+// access tokens of another NRF or certificate are requested again.
+func (context *UDMContext) SetNrf(nrfUri, nrfCertPem string) {
+	context.nrfMu.Lock()
+	defer context.nrfMu.Unlock()
+	if nrfUri != context.NrfUri || nrfCertPem != context.NrfCertPem {
+		context.invalidateAccessTokens()
+	}
+	context.NrfUri = nrfUri
+	context.NrfCertPem = nrfCertPem
+}
+
+// GetHeartBeatTimer returns the seconds between the heartbeats to the NRF, 0 when unknown
+func (context *UDMContext) GetHeartBeatTimer() int32 {
+	context.nrfMu.RLock()
+	defer context.nrfMu.RUnlock()
+	return context.HeartBeatTimer
+}
+
+// SetHeartBeatTimer sets the heartBeatTimer returned by the NRF
+func (context *UDMContext) SetHeartBeatTimer(heartBeatTimer int32) {
+	context.nrfMu.Lock()
+	defer context.nrfMu.Unlock()
+	context.HeartBeatTimer = heartBeatTimer
+}
+
+// SetOAuth2Required sets whether the NRF requires OAuth2 access tokens
+func (context *UDMContext) SetOAuth2Required(oauth2 bool) {
+	context.nrfMu.Lock()
+	defer context.nrfMu.Unlock()
+	context.OAuth2Required = oauth2
 }
 
 func GetSelf() *UDMContext {
@@ -507,12 +779,12 @@ func GetSelf() *UDMContext {
 }
 
 func (context *UDMContext) AuthorizationCheck(token string, serviceName models.ServiceName) error {
-	if !context.OAuth2Required {
+	if !context.IsOAuth2Required() {
 		logger.UtilLog.Debugf("UDMContext::AuthorizationCheck: OAuth2 not required\n")
 		return nil
 	}
 	logger.UtilLog.Debugf("UDMContext::AuthorizationCheck: token[%s] serviceName[%s]\n", token, serviceName)
-	err := oauth.VerifyOAuth(token, string(serviceName), context.NrfCertPem)
+	err := oauth.VerifyOAuth(token, string(serviceName), context.GetNrfCertPem())
 	if err != nil {
 		return err
 	}
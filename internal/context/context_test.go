@@ -0,0 +1,93 @@
+package context
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/free5gc/openapi/models"
+	"github.com/free5gc/udm/pkg/factory"
+)
+
+func TestInitUdmContextRegisterIP(t *testing.T) {
+	tests := []struct {
+		name             string
+		sbi              string
+		wantRegisterIPv4 string
+		wantRegisterIPv6 string
+		wantIPUri        string
+	}{
+		{
+			name:             "IPv4-only sbi",
+			sbi:              "registerIPv4: 127.0.0.3\n    bindingIPv4: 127.0.0.3",
+			wantRegisterIPv4: "127.0.0.3",
+			wantIPUri:        "http://127.0.0.3:8000",
+		},
+		{
+			name:             "IPv6-only sbi",
+			sbi:              "registerIPv6: 2001:db8::3\n    bindingIPv6: \"::\"",
+			wantRegisterIPv6: "2001:db8::3",
+			wantIPUri:        "http://[2001:db8::3]:8000",
+		},
+		{
+			name: "dual-stack sbi",
+			sbi: "registerIPv4: 127.0.0.3\n    registerIPv6: 2001:db8::3\n" +
+				"    bindingIPv4: 0.0.0.0\n    bindingIPv6: \"::\"",
+			wantRegisterIPv4: "127.0.0.3",
+			wantRegisterIPv6: "2001:db8::3",
+			wantIPUri:        "http://127.0.0.3:8000",
+		},
+	}
+	defaultUdmConfig := factory.UdmConfig
+	defer func() { factory.UdmConfig = defaultUdmConfig }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "udmcfg.yaml")
+			content := fmt.Sprintf(`info:
+  version: 1.0.3
+configuration:
+  sbi:
+    scheme: http
+    %s
+    port: 8000
+  serviceNameList:
+    - nudm-ueau
+  nrfUri: http://127.0.0.10:8000
+logger:
+  enable: true
+  level: info
+  reportCaller: false
+`, tt.sbi)
+			require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+			cfg, err := factory.ReadConfig(path)
+			require.NoError(t, err)
+			factory.UdmConfig = cfg
+
+			require.NoError(t, Init())
+			udmContext := GetSelf()
+			require.Equal(t, tt.wantRegisterIPv4, udmContext.RegisterIPv4)
+			require.Equal(t, tt.wantRegisterIPv6, udmContext.RegisterIPv6)
+			require.Equal(t, tt.wantIPUri, udmContext.GetIPUri())
+
+			var ipEndPoints []models.IpEndPoint
+			if tt.wantRegisterIPv4 != "" {
+				ipEndPoints = append(ipEndPoints, models.IpEndPoint{
+					Ipv4Address: tt.wantRegisterIPv4,
+					Transport:   models.NrfNfManagementTransportProtocol_TCP,
+					Port:        8000,
+				})
+			}
+			if tt.wantRegisterIPv6 != "" {
+				ipEndPoints = append(ipEndPoints, models.IpEndPoint{
+					Ipv6Address: tt.wantRegisterIPv6,
+					Transport:   models.NrfNfManagementTransportProtocol_TCP,
+					Port:        8000,
+				})
+			}
+			require.Equal(t, ipEndPoints, udmContext.NfService[models.ServiceName_NUDM_UEAU].IpEndPoints)
+		})
+	}
+}
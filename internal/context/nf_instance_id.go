@@ -0,0 +1,88 @@
+package context
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/free5gc/openapi/models"
+)
+
+// NfInstanceIdFromCertificate returns the NF instance ID of an NF certificate, the URI
+// urn:uuid:<NF instance ID> of its SubjectAltName (TS 33.310 6.1.3c.3)
+func NfInstanceIdFromCertificate(cert *x509.Certificate) (string, error) {
+	for _, uri := range cert.URIs {
+		if !strings.EqualFold(uri.Scheme, "urn") {
+			continue
+		}
+		if nid, nfInstanceId, ok := strings.Cut(uri.Opaque, ":"); ok && strings.EqualFold(nid, "uuid") {
+			return nfInstanceId, nil
+		}
+	}
+	return "", fmt.Errorf("no NF instance ID (urn:uuid URI SAN) in certificate of [%s]", cert.Subject)
+}
+
+// CheckNfInstanceId checks that the NF instance ID of the client certificate is the
+// nfInstanceId (sub claim) of the access token, verified beforehand by AuthorizationCheck, so
+// that a token cannot be used by another NF (TS 33.501 13.4.1.2)
+func (context *UDMContext) CheckNfInstanceId(token string, peerCertificates []*x509.Certificate) error {
+	if !context.isNfInstanceIdCheck() || !context.IsOAuth2Required() || len(peerCertificates) == 0 {
+		return nil
+	}
+
+	certNfInstanceId, err := NfInstanceIdFromCertificate(peerCertificates[0])
+	if err != nil {
+		return err
+	}
+
+	tokenNfInstanceId, err := accessTokenNfInstanceId(token)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(tokenNfInstanceId, certNfInstanceId) {
+		return fmt.Errorf("access token of NF instance [%s] presented with the certificate of NF instance [%s]",
+			tokenNfInstanceId, certNfInstanceId)
+	}
+	return nil
+}
+
+// SetNfInstanceIdCheck sets whether CheckNfInstanceId checks the NF instance IDs, which a
+// configuration reload may change at any time
+func (context *UDMContext) SetNfInstanceIdCheck(nfInstanceIdCheck bool) {
+	context.nrfMu.Lock()
+	defer context.nrfMu.Unlock()
+	context.NfInstanceIdCheck = nfInstanceIdCheck
+}
+
+func (context *UDMContext) isNfInstanceIdCheck() bool {
+	context.nrfMu.RLock()
+	defer context.nrfMu.RUnlock()
+	return context.NfInstanceIdCheck
+}
+
+// ConsumerNfInstanceId returns the NF instance ID of the NF service consumer of a request, the one
+// of its verified client certificate or else the sub claim of its access token, verified
+// beforehand by AuthorizationCheck
+func (context *UDMContext) ConsumerNfInstanceId(token string, peerCertificates []*x509.Certificate) (string, error) {
+	if len(peerCertificates) > 0 {
+		return NfInstanceIdFromCertificate(peerCertificates[0])
+	}
+	if !context.IsOAuth2Required() {
+		return "", errors.New("NF service consumer authenticated neither by a client certificate nor by an access token")
+	}
+	return accessTokenNfInstanceId(token)
+}
+
+// accessTokenNfInstanceId returns the nfInstanceId (sub claim) of the access token of the
+// Authorization header token
+func accessTokenNfInstanceId(token string) (string, error) {
+	_, accessToken, _ := strings.Cut(token, " ")
+	var claims models.NrfAccessTokenAccessTokenClaims
+	if _, _, err := jwt.NewParser().ParseUnverified(strings.TrimSpace(accessToken), &claims); err != nil {
+		return "", fmt.Errorf("parse access token: %w", err)
+	}
+	return claims.Sub, nil
+}
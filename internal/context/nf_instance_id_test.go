@@ -0,0 +1,101 @@
+package context
+
+import (
+	"crypto/x509"
+	"net/url"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+
+	"github.com/free5gc/openapi/models"
+)
+
+func TestCheckNfInstanceId(t *testing.T) {
+	const ausfNfInstanceId = "6f2c3e1a-5b7d-4c8e-9a0b-1c2d3e4f5a6b"
+	ausfCert := &x509.Certificate{
+		URIs: []*url.URL{{Scheme: "https", Host: "ausf.example.org"}, {Scheme: "urn", Opaque: "uuid:" + ausfNfInstanceId}},
+	}
+	nfInstanceIdToken := func(nfInstanceId string) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, models.NrfAccessTokenAccessTokenClaims{
+			Sub:   nfInstanceId,
+			Scope: "nudm-ueau",
+		}).SignedString([]byte("key"))
+		require.NoError(t, err)
+		return "Bearer " + token
+	}
+
+	tests := []struct {
+		name              string
+		nfInstanceIdCheck bool
+		token             string
+		peerCertificates  []*x509.Certificate
+		wantErr           bool
+	}{
+		{
+			name:              "NF instance IDs match",
+			nfInstanceIdCheck: true,
+			token:             nfInstanceIdToken(ausfNfInstanceId),
+			peerCertificates:  []*x509.Certificate{ausfCert},
+		},
+		{
+			name:              "token of another NF instance",
+			nfInstanceIdCheck: true,
+			token:             nfInstanceIdToken("0a1b2c3d-4e5f-4a6b-8c7d-9e0f1a2b3c4d"),
+			peerCertificates:  []*x509.Certificate{ausfCert},
+			wantErr:           true,
+		},
+		{
+			name:              "certificate without NF instance ID",
+			nfInstanceIdCheck: true,
+			token:             nfInstanceIdToken(ausfNfInstanceId),
+			peerCertificates:  []*x509.Certificate{{}},
+			wantErr:           true,
+		},
+		{
+			name:              "check disabled",
+			nfInstanceIdCheck: false,
+			token:             nfInstanceIdToken("0a1b2c3d-4e5f-4a6b-8c7d-9e0f1a2b3c4d"),
+			peerCertificates:  []*x509.Certificate{ausfCert},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			udmContext := &UDMContext{NfInstanceIdCheck: tt.nfInstanceIdCheck}
+			udmContext.SetOAuth2Required(true)
+			err := udmContext.CheckNfInstanceId(tt.token, tt.peerCertificates)
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestConsumerNfInstanceId(t *testing.T) {
+	const ausfNfInstanceId = "6f2c3e1a-5b7d-4c8e-9a0b-1c2d3e4f5a6b"
+	ausfCert := &x509.Certificate{URIs: []*url.URL{{Scheme: "urn", Opaque: "uuid:" + ausfNfInstanceId}}}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, models.NrfAccessTokenAccessTokenClaims{
+		Sub:   "0a1b2c3d-4e5f-4a6b-8c7d-9e0f1a2b3c4d",
+		Scope: "nudm-ueid",
+	}).SignedString([]byte("key"))
+	require.NoError(t, err)
+
+	udmContext := &UDMContext{}
+	nfInstanceId, err := udmContext.ConsumerNfInstanceId("", []*x509.Certificate{ausfCert})
+	require.NoError(t, err)
+	require.Equal(t, ausfNfInstanceId, nfInstanceId)
+	// Without OAuth2 the access token, not verified, does not authenticate the consumer
+	_, err = udmContext.ConsumerNfInstanceId("Bearer "+token, nil)
+	require.Error(t, err)
+
+	udmContext.SetOAuth2Required(true)
+	nfInstanceId, err = udmContext.ConsumerNfInstanceId("Bearer "+token, nil)
+	require.NoError(t, err)
+	require.Equal(t, "0a1b2c3d-4e5f-4a6b-8c7d-9e0f1a2b3c4d", nfInstanceId)
+	// The client certificate takes precedence
+	nfInstanceId, err = udmContext.ConsumerNfInstanceId("Bearer "+token, []*x509.Certificate{ausfCert})
+	require.NoError(t, err)
+	require.Equal(t, ausfNfInstanceId, nfInstanceId)
+}
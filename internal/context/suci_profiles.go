@@ -0,0 +1,139 @@
+package context
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/free5gc/udm/internal/logger"
+	"github.com/free5gc/udm/pkg/factory"
+	"github.com/free5gc/udm/pkg/suci"
+)
+
+// GetSuciProfiles returns the SUCI profiles in use. A reload swaps in a new slice, so the one
+// returned is never modified and may be used while the keys are rotated.
+func (context *UDMContext) GetSuciProfiles() []suci.SuciProfile {
+	if suciProfiles := context.suciProfiles.Load(); suciProfiles != nil {
+		return *suciProfiles
+	}
+	return nil
+}
+
+// SetSuciProfiles swaps in the SUCI profiles loaded from source and audit-logs the home network
+// keys added, replaced and removed
+func (context *UDMContext) SetSuciProfiles(suciProfiles []suci.SuciProfile, source string) {
+	newProfiles := make([]suci.SuciProfile, len(suciProfiles))
+	for i, profile := range suciProfiles {
+		profile.KeyProvider = context.KeyProvider
+		newProfiles[i] = profile
+	}
+
+	var oldProfiles []suci.SuciProfile
+	if old := context.suciProfiles.Swap(&newProfiles); old != nil {
+		oldProfiles = *old
+	}
+	added, replaced, removed := diffSuciKeys(oldProfiles, newProfiles)
+	logger.AuditLog.Infof("SUCI keys loaded from [%s]: %d key(s), added %v, replaced %v, removed %v",
+		source, len(newProfiles), added, replaced, removed)
+}
+
+// ReloadSuciProfileFile swaps in the SUCI profiles of a suciProfileFile. A file failing
+// validation is rejected and the running keys are kept.
+func (context *UDMContext) ReloadSuciProfileFile(path string) error {
+	context.suciProfileFileMu.Lock()
+	defer context.suciProfileFileMu.Unlock()
+
+	// The version read is recorded before reading, so that a write racing the read is reloaded
+	info, err := os.Stat(path)
+	if err != nil {
+		logger.AuditLog.Errorf("SUCI keys from [%s] rejected, keeping the running keys: %+v", path, err)
+		return err
+	}
+	context.suciProfileFileInfo = info
+
+	suciProfiles, err := factory.ReadSuciProfileFile(path)
+	if err != nil {
+		logger.AuditLog.Errorf("SUCI keys from [%s] rejected, keeping the running keys: %+v", path, err)
+		return err
+	}
+	context.SetSuciProfiles(suciProfiles, path)
+	return nil
+}
+
+// suciProfileFileChanged reports whether the suciProfileFile changed since it was last read
+func (context *UDMContext) suciProfileFileChanged(path string) (bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+
+	context.suciProfileFileMu.Lock()
+	defer context.suciProfileFileMu.Unlock()
+	last := context.suciProfileFileInfo
+	return last == nil || !info.ModTime().Equal(last.ModTime()) || info.Size() != last.Size(), nil
+}
+
+// WatchSuciProfileFile reloads the SUCI profiles from path every time the file changes, checking
+// it every interval until ctx is done
+func (context *UDMContext) WatchSuciProfileFile(ctx context.Context, path string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var unavailable bool
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		changed, err := context.suciProfileFileChanged(path)
+		if err != nil {
+			if !unavailable {
+				logger.CtxLog.Warnf("SUCI profile file unavailable, keeping the running keys: %+v", err)
+			}
+			unavailable = true
+			continue
+		}
+		unavailable = false
+		if !changed {
+			continue
+		}
+		// A rejected file is audit-logged and retried once it changes again
+		_ = context.ReloadSuciProfileFile(path)
+	}
+}
+
+// diffSuciKeys lists the home network keys, named <protection scheme>/<public key ID>, only in
+// newProfiles, in both with another key pair, and only in oldProfiles
+func diffSuciKeys(oldProfiles, newProfiles []suci.SuciProfile) (added, replaced, removed []string) {
+	keys := func(profiles []suci.SuciProfile) map[string]suci.SuciProfile {
+		m := make(map[string]suci.SuciProfile, len(profiles))
+		for i, profile := range profiles {
+			m[fmt.Sprintf("%s/%d", profile.ProtectionScheme, profile.GetPublicKeyId(i))] = profile
+		}
+		return m
+	}
+	oldKeys, newKeys := keys(oldProfiles), keys(newProfiles)
+
+	for name, newProfile := range newKeys {
+		oldProfile, ok := oldKeys[name]
+		switch {
+		case !ok:
+			added = append(added, name)
+		case oldProfile.PublicKey != newProfile.PublicKey || oldProfile.PrivateKey != newProfile.PrivateKey ||
+			oldProfile.PrivateKeyRef != newProfile.PrivateKeyRef:
+			replaced = append(replaced, name)
+		}
+	}
+	for name := range oldKeys {
+		if _, ok := newKeys[name]; !ok {
+			removed = append(removed, name)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(replaced)
+	sort.Strings(removed)
+	return added, replaced, removed
+}
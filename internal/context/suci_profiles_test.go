@@ -0,0 +1,176 @@
+package context
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/free5gc/udm/pkg/factory"
+	"github.com/free5gc/udm/pkg/keyprovider"
+	"github.com/free5gc/udm/pkg/suci"
+)
+
+const (
+	profileAPrivateKey = "c53c22208b61860b06c62e5406a7b330c2b577aa5558981510d128247d38bd1d"
+	profileAPublicKey  = "5a8d38864820197c3394b92613b20b91633cbd897119273bf8e4a6f4eec0a650"
+	profileBPrivateKey = "F1AB1074477EBCC7F554EA1C5FC368B1616730155E0041AC447D6301975FECDA"
+	profileBPublicKey  = "0272DA71976234CE833A6907425867B82E074D44EF907DFB4B3E21C1C2256EBCD1"
+	// imsi-20893001002086 concealed with the profile B key
+	profileBSuci = "suci-0-208-93-0-2-%d-039aab8376597021e855679a9778ea0b67396e68c66d" +
+		"f32c0f41e9acca2da9b9d146a33fc2716ac7dae96aa30a4d"
+)
+
+func TestDiffSuciKeys(t *testing.T) {
+	oldProfiles := []suci.SuciProfile{
+		{ProtectionScheme: "1", PrivateKey: profileAPrivateKey, PublicKey: profileAPublicKey},
+		{ProtectionScheme: "2", PublicKeyId: 5, PrivateKey: profileBPrivateKey, PublicKey: profileBPublicKey},
+		{ProtectionScheme: "2", PublicKeyId: 6, PrivateKeyRef: "hn-6.pem", PublicKey: profileBPublicKey},
+	}
+	newProfiles := []suci.SuciProfile{
+		{ProtectionScheme: "1", PrivateKey: profileAPrivateKey, PublicKey: profileAPublicKey},
+		{ProtectionScheme: "2", PublicKeyId: 6, PrivateKeyRef: "hn-6-new.pem", PublicKey: profileBPublicKey},
+		{ProtectionScheme: "2", PublicKeyId: 7, PrivateKeyRef: "hn-7.pem", PublicKey: profileBPublicKey},
+	}
+
+	added, replaced, removed := diffSuciKeys(oldProfiles, newProfiles)
+	require.Equal(t, []string{"2/7"}, added)
+	require.Equal(t, []string{"2/6"}, replaced)
+	require.Equal(t, []string{"2/5"}, removed)
+}
+
+// writeSuciProfileFile replaces the file by a rename, as the watcher may read it at any time
+func writeSuciProfileFile(t *testing.T, path, content string, modTime time.Time) {
+	t.Helper()
+	tmpPath := path + ".tmp"
+	require.NoError(t, os.WriteFile(tmpPath, []byte(content), 0o600))
+	require.NoError(t, os.Chtimes(tmpPath, modTime, modTime))
+	require.NoError(t, os.Rename(tmpPath, path))
+}
+
+func TestWatchSuciProfileFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "suci.yaml")
+	modTime := time.Now().Add(-time.Hour)
+	writeSuciProfileFile(t, path, `SuciProfile:
+  - ProtectionScheme: 2
+    PublicKeyId: 5
+    PrivateKey: `+profileBPrivateKey+`
+    PublicKey: `+profileBPublicKey+`
+`, modTime)
+
+	udmContext := &UDMContext{}
+	require.NoError(t, udmContext.ReloadSuciProfileFile(path))
+	require.Len(t, udmContext.GetSuciProfiles(), 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		udmContext.WatchSuciProfileFile(ctx, path, 10*time.Millisecond)
+	}()
+	// UEs keep registering with the key of ID 5 while the keys are rotated
+	go func() {
+		defer wg.Done()
+		for ctx.Err() == nil {
+			supi, err := suci.ToSupi(fmt.Sprintf(profileBSuci, 5), udmContext.GetSuciProfiles())
+			if err != nil || supi != "imsi-20893001002086" {
+				t.Errorf("ToSupi during reload: supi[%s], err[%v]", supi, err)
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	// a new generation of keys is added
+	modTime = modTime.Add(time.Minute)
+	writeSuciProfileFile(t, path, `SuciProfile:
+  - ProtectionScheme: 2
+    PublicKeyId: 5
+    PrivateKey: `+profileBPrivateKey+`
+    PublicKey: `+profileBPublicKey+`
+  - ProtectionScheme: 2
+    PublicKeyId: 6
+    PrivateKey: `+profileBPrivateKey+`
+    PublicKey: `+profileBPublicKey+`
+`, modTime)
+	require.Eventually(t, func() bool {
+		return len(udmContext.GetSuciProfiles()) == 2
+	}, time.Second, 10*time.Millisecond)
+	supi, err := suci.ToSupi(fmt.Sprintf(profileBSuci, 6), udmContext.GetSuciProfiles())
+	require.NoError(t, err)
+	require.Equal(t, "imsi-20893001002086", supi)
+
+	// a file with duplicated IDs is rejected
+	modTime = modTime.Add(time.Minute)
+	writeSuciProfileFile(t, path, `SuciProfile:
+  - ProtectionScheme: 2
+    PublicKeyId: 5
+    PrivateKey: `+profileBPrivateKey+`
+    PublicKey: `+profileBPublicKey+`
+  - ProtectionScheme: 2
+    PublicKeyId: 5
+    PrivateKey: `+profileBPrivateKey+`
+    PublicKey: `+profileBPublicKey+`
+`, modTime)
+	time.Sleep(100 * time.Millisecond)
+	require.Len(t, udmContext.GetSuciProfiles(), 2)
+}
+
+func TestInitKeys(t *testing.T) {
+	tests := []struct {
+		name          string
+		configuration *factory.Configuration
+		wantErr       bool
+	}{
+		{
+			name: "SUCI profiles of the configuration",
+			configuration: &factory.Configuration{
+				SuciProfiles: []suci.SuciProfile{
+					{ProtectionScheme: "1", PrivateKey: profileAPrivateKey, PublicKey: profileAPublicKey},
+				},
+			},
+		},
+		{
+			name: "key provider failure",
+			configuration: &factory.Configuration{
+				KeyProvider: &keyprovider.Config{
+					Type: keyprovider.TypePkcs11,
+					Pkcs11: &keyprovider.Pkcs11Config{
+						Library:    filepath.Join(t.TempDir(), "missing.so"),
+						TokenLabel: "udm",
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing suciProfileFile",
+			configuration: &factory.Configuration{
+				SuciProfileFile: filepath.Join(t.TempDir(), "missing.yaml"),
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			udmContext := &UDMContext{}
+			err := udmContext.InitKeys(tt.configuration)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, udmContext.GetSuciProfiles(), 1)
+		})
+	}
+}
@@ -22,8 +22,10 @@ var (
 	SdmLog      *logrus.Entry
 	PpLog       *logrus.Entry
 	EeLog       *logrus.Entry
+	UeidLog     *logrus.Entry
 	UtilLog     *logrus.Entry
 	SuciLog     *logrus.Entry
+	AuditLog    *logrus.Entry
 	CallbackLog *logrus.Entry
 	ProcLog     *logrus.Entry
 )
@@ -50,7 +52,9 @@ func init() {
 	SdmLog = NfLog.WithField(logger_util.FieldCategory, "SDM")
 	PpLog = NfLog.WithField(logger_util.FieldCategory, "PP")
 	EeLog = NfLog.WithField(logger_util.FieldCategory, "EE")
+	UeidLog = NfLog.WithField(logger_util.FieldCategory, "UEID")
 	UtilLog = NfLog.WithField(logger_util.FieldCategory, "Util")
 	SuciLog = NfLog.WithField(logger_util.FieldCategory, "Suci")
+	AuditLog = NfLog.WithField(logger_util.FieldCategory, "Audit")
 	CallbackLog = NfLog.WithField(logger_util.FieldCategory, "Callback")
 }
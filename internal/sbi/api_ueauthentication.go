@@ -2,6 +2,7 @@ package sbi
 
 import (
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 
@@ -95,23 +96,170 @@ func (s *Server) HandleGenerateAuthData(c *gin.Context) {
 }
 
 func (s *Server) HandleDeleteAuth(c *gin.Context) {
-	c.JSON(http.StatusNotImplemented, gin.H{})
+	var authEvent models.AuthEvent
+	requestBody, err := c.GetRawData()
+	if err != nil {
+		problemDetail := models.ProblemDetails{
+			Title:  "System failure",
+			Status: http.StatusInternalServerError,
+			Detail: err.Error(),
+			Cause:  "SYSTEM_FAILURE",
+		}
+		logger.UeauLog.Errorf("Get Request Body error: %+v", err)
+		c.JSON(http.StatusInternalServerError, problemDetail)
+		return
+	}
+
+	err = openapi.Deserialize(&authEvent, requestBody, "application/json")
+	if err != nil {
+		problemDetail := "[Request Body] " + err.Error()
+		rsp := models.ProblemDetails{
+			Title:  "Malformed request syntax",
+			Status: http.StatusBadRequest,
+			Detail: problemDetail,
+		}
+		logger.UeauLog.Errorln(problemDetail)
+		c.JSON(http.StatusBadRequest, rsp)
+		return
+	}
+
+	supi := c.Params.ByName("supi")
+	authEventId := c.Params.ByName("thirdLayer")
+
+	logger.UeauLog.Infoln("Handle DeleteAuthDataRequest")
+
+	s.Processor().DeleteAuthDataProcedure(c, authEvent, supi, authEventId)
 }
 
 func (s *Server) HandleGenerateAv(c *gin.Context) {
-	c.JSON(http.StatusNotImplemented, gin.H{})
+	var hssAuthInfoReq models.HssAuthenticationInfoRequest
+
+	requestBody, err := c.GetRawData()
+	if err != nil {
+		problemDetail := models.ProblemDetails{
+			Title:  "System failure",
+			Status: http.StatusInternalServerError,
+			Detail: err.Error(),
+			Cause:  "SYSTEM_FAILURE",
+		}
+		logger.UeauLog.Errorf("Get Request Body error: %+v", err)
+		c.JSON(http.StatusInternalServerError, problemDetail)
+		return
+	}
+
+	err = openapi.Deserialize(&hssAuthInfoReq, requestBody, "application/json")
+	if err != nil {
+		problemDetail := "[Request Body] " + err.Error()
+		rsp := models.ProblemDetails{
+			Title:  "Malformed request syntax",
+			Status: http.StatusBadRequest,
+			Detail: problemDetail,
+		}
+		logger.UeauLog.Errorln(problemDetail)
+		c.JSON(http.StatusBadRequest, rsp)
+		return
+	}
+
+	logger.UeauLog.Infoln("Handle GenerateAvRequest")
+
+	supi := c.Param("supi")
+	hssAuthType := models.HssAuthTypeInUri(c.Param("hssAuthType"))
+
+	s.Processor().GenerateAvProcedure(c, hssAuthInfoReq, supi, hssAuthType)
 }
 
 func (s *Server) HandleGenerateGbaAv(c *gin.Context) {
-	c.JSON(http.StatusNotImplemented, gin.H{})
+	var gbaAuthInfoReq models.GbaAuthenticationInfoRequest
+
+	requestBody, err := c.GetRawData()
+	if err != nil {
+		problemDetail := models.ProblemDetails{
+			Title:  "System failure",
+			Status: http.StatusInternalServerError,
+			Detail: err.Error(),
+			Cause:  "SYSTEM_FAILURE",
+		}
+		logger.UeauLog.Errorf("Get Request Body error: %+v", err)
+		c.JSON(http.StatusInternalServerError, problemDetail)
+		return
+	}
+
+	err = openapi.Deserialize(&gbaAuthInfoReq, requestBody, "application/json")
+	if err != nil {
+		problemDetail := "[Request Body] " + err.Error()
+		rsp := models.ProblemDetails{
+			Title:  "Malformed request syntax",
+			Status: http.StatusBadRequest,
+			Detail: problemDetail,
+		}
+		logger.UeauLog.Errorln(problemDetail)
+		c.JSON(http.StatusBadRequest, rsp)
+		return
+	}
+
+	logger.UeauLog.Infoln("Handle GenerateGbaAvRequest")
+
+	supi := c.Param("supi")
+
+	s.Processor().GenerateGbaAvProcedure(c, gbaAuthInfoReq, supi)
 }
 
 func (s *Server) HandleGenerateProseAV(c *gin.Context) {
-	c.JSON(http.StatusNotImplemented, gin.H{})
+	var proseAuthInfoReq models.ProSeAuthenticationInfoRequest
+
+	requestBody, err := c.GetRawData()
+	if err != nil {
+		problemDetail := models.ProblemDetails{
+			Title:  "System failure",
+			Status: http.StatusInternalServerError,
+			Detail: err.Error(),
+			Cause:  "SYSTEM_FAILURE",
+		}
+		logger.UeauLog.Errorf("Get Request Body error: %+v", err)
+		c.JSON(http.StatusInternalServerError, problemDetail)
+		return
+	}
+
+	err = openapi.Deserialize(&proseAuthInfoReq, requestBody, "application/json")
+	if err != nil {
+		problemDetail := "[Request Body] " + err.Error()
+		rsp := models.ProblemDetails{
+			Title:  "Malformed request syntax",
+			Status: http.StatusBadRequest,
+			Detail: problemDetail,
+		}
+		logger.UeauLog.Errorln(problemDetail)
+		c.JSON(http.StatusBadRequest, rsp)
+		return
+	}
+
+	logger.UeauLog.Infoln("Handle GenerateProseAvRequest")
+
+	supiOrSuci := c.Param("supiOrSuci")
+
+	s.Processor().GenerateProseAvProcedure(c, proseAuthInfoReq, supiOrSuci)
 }
 
 func (s *Server) HandleGetRgAuthData(c *gin.Context) {
-	c.JSON(http.StatusNotImplemented, gin.H{})
+	logger.UeauLog.Infoln("Handle GetRgAuthDataRequest")
+
+	authenticatedInd, err := strconv.ParseBool(c.Query("authenticated-ind"))
+	if err != nil {
+		problemDetail := models.ProblemDetails{
+			Title:  "Malformed request syntax",
+			Status: http.StatusBadRequest,
+			Detail: "[Query Parameter] authenticated-ind: " + err.Error(),
+			Cause:  "MANDATORY_IE_INCORRECT",
+		}
+		logger.UeauLog.Errorln(problemDetail.Detail)
+		c.JSON(http.StatusBadRequest, problemDetail)
+		return
+	}
+
+	supiOrSuci := c.Param("supiOrSuci")
+	supportedFeatures := c.Query("supported-features")
+
+	s.Processor().GetRgAuthDataProcedure(c, supiOrSuci, authenticatedInd, supportedFeatures)
 }
 
 func (s *Server) UEAUTwoLayerPathHandlerFunc(c *gin.Context) {
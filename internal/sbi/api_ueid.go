@@ -4,6 +4,10 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+
+	"github.com/free5gc/openapi"
+	"github.com/free5gc/openapi/models"
+	"github.com/free5gc/udm/internal/logger"
 )
 
 func (s *Server) getUEIDRoutes() []Route {
@@ -25,5 +29,35 @@ func (s *Server) getUEIDRoutes() []Route {
 }
 
 func (s *Server) HandleDeconceal(c *gin.Context) {
-	c.JSON(http.StatusNotImplemented, gin.H{})
+	var deconcealReqData models.DeconcealReqData
+
+	requestBody, err := c.GetRawData()
+	if err != nil {
+		problemDetail := models.ProblemDetails{
+			Title:  "System failure",
+			Status: http.StatusInternalServerError,
+			Detail: err.Error(),
+			Cause:  "SYSTEM_FAILURE",
+		}
+		logger.UeidLog.Errorf("Get Request Body error: %+v", err)
+		c.JSON(http.StatusInternalServerError, problemDetail)
+		return
+	}
+
+	err = openapi.Deserialize(&deconcealReqData, requestBody, "application/json")
+	if err != nil {
+		problemDetail := "[Request Body] " + err.Error()
+		rsp := models.ProblemDetails{
+			Title:  "Malformed request syntax",
+			Status: http.StatusBadRequest,
+			Detail: problemDetail,
+		}
+		logger.UeidLog.Errorln(problemDetail)
+		c.JSON(http.StatusBadRequest, rsp)
+		return
+	}
+
+	logger.UeidLog.Infoln("Handle DeconcealRequest")
+
+	s.Processor().DeconcealProcedure(c, deconcealReqData)
 }
@@ -0,0 +1,98 @@
+package sbi
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+
+	"github.com/free5gc/openapi/models"
+	"github.com/free5gc/openapi/udm/UEID"
+	udm_context "github.com/free5gc/udm/internal/context"
+	"github.com/free5gc/udm/internal/logger"
+	"github.com/free5gc/udm/internal/sbi/consumer"
+	"github.com/free5gc/udm/internal/sbi/processor"
+	mockapp "github.com/free5gc/udm/pkg/mockapp"
+	logger_util "github.com/free5gc/util/logger"
+)
+
+type testUdm struct {
+	*mockapp.MockApp
+
+	processor *processor.Processor
+}
+
+func (u *testUdm) Processor() *processor.Processor {
+	return u.processor
+}
+
+func (u *testUdm) CancelContext() context.Context {
+	return context.Background()
+}
+
+// TestDeconcealOpenapiClient checks that the Nudm_UEID service serves the NF service consumers
+// using the openapi client as they are, which send the default User-Agent of the client. The AUSF
+// is authenticated by its client certificate, its NF type is found at the NRF.
+func TestDeconcealOpenapiClient(t *testing.T) {
+	const ausfNfInstanceId = "6f2c3e1a-5b7d-4c8e-9a0b-1c2d3e4f5a6b"
+	nrf := httptest.NewServer(h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var result models.SearchResult
+		if r.URL.Query().Get("target-nf-type") == string(models.NrfNfManagementNfType_AUSF) {
+			result.NfInstances = []models.NrfNfDiscoveryNfProfile{{
+				NfInstanceId: ausfNfInstanceId,
+				NfType:       models.NrfNfManagementNfType_AUSF,
+			}}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		require.NoError(t, json.NewEncoder(w).Encode(result))
+	}), &http2.Server{}))
+	defer nrf.Close()
+
+	ctrl := gomock.NewController(t)
+	mockApp := mockapp.NewMockApp(ctrl)
+	mockApp.EXPECT().Context().Return(&udm_context.UDMContext{
+		NrfUri:            nrf.URL,
+		PlmnSupportList:   []models.PlmnId{{Mcc: "208", Mnc: "93"}},
+		RoutingIndicators: []string{"0"},
+	}).AnyTimes()
+	testConsumer, err := consumer.NewConsumer(mockApp)
+	require.NoError(t, err)
+	mockApp.EXPECT().Consumer().Return(testConsumer).AnyTimes()
+	testProcessor, err := processor.NewProcessor(mockApp)
+	require.NoError(t, err)
+
+	s := &Server{
+		ServerUdm: &testUdm{MockApp: mockApp, processor: testProcessor},
+		router:    logger_util.NewGinWithLogrus(logger.GinLog),
+	}
+	newRouter(s)
+	// The connection state of the mutual TLS connection of the AUSF
+	ausfCert := &x509.Certificate{URIs: []*url.URL{{Scheme: "urn", Opaque: "uuid:" + ausfNfInstanceId}}}
+	mtls := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.TLS = &tls.ConnectionState{
+			PeerCertificates: []*x509.Certificate{ausfCert},
+			VerifiedChains:   [][]*x509.Certificate{{ausfCert}},
+		}
+		s.router.ServeHTTP(w, r)
+	})
+	server := httptest.NewServer(h2c.NewHandler(mtls, &http2.Server{}))
+	defer server.Close()
+
+	configuration := UEID.NewConfiguration()
+	configuration.SetBasePath(server.URL)
+	client := UEID.NewAPIClient(configuration)
+	rsp, err := client.DeconcealApi.Deconceal(context.Background(), &UEID.DeconcealRequest{
+		DeconcealReqData: &models.DeconcealReqData{Suci: "suci-0-208-93-0-0-0-00007487"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "imsi-2089300007487", rsp.DeconcealRspData.Supi)
+}
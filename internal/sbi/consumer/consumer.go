@@ -1,12 +1,17 @@
 package consumer
 
 import (
+	"net/http"
+
+	"github.com/free5gc/openapi"
 	Nnrf_NFDiscovery "github.com/free5gc/openapi/nrf/NFDiscovery"
 	Nnrf_NFManagement "github.com/free5gc/openapi/nrf/NFManagement"
 	Nudm_SubscriberDataManagement "github.com/free5gc/openapi/udm/SubscriberDataManagement"
 	Nudm_UEContextManagement "github.com/free5gc/openapi/udm/UEContextManagement"
 	Nudr_DataRepository "github.com/free5gc/openapi/udr/DataRepository"
+	"github.com/free5gc/udm/internal/util"
 	"github.com/free5gc/udm/pkg/app"
+	"github.com/free5gc/udm/pkg/factory"
 )
 
 type ConsumerUdm interface {
@@ -16,6 +21,9 @@ type ConsumerUdm interface {
 type Consumer struct {
 	ConsumerUdm
 
+	// HTTP client of the NF services consumed, nil for the default clients of openapi
+	httpClient *http.Client
+
 	// consumer services
 	*nnrfService
 	*nudrService
@@ -45,3 +53,27 @@ func NewConsumer(udm ConsumerUdm) (*Consumer, error) {
 	}
 	return c, nil
 }
+
+// InitMutualTLS makes the consumer services, and the access token requests to the NRF, use mutual
+// TLS when the configuration enables it
+func (c *Consumer) InitMutualTLS(cfg *factory.Config) error {
+	httpClient, err := util.NewMutualTLSClient(cfg)
+	if err != nil {
+		return err
+	}
+	c.httpClient = httpClient
+	c.Context().SetTokenHTTPClient(httpClient)
+	return nil
+}
+
+// defaultConfiguration is a configuration without HTTP client, with which openapi sends the
+// requests with its default clients
+var defaultConfiguration = Nudr_DataRepository.NewConfiguration()
+
+// send sends req with the client of the NF services consumed
+func (c *Consumer) send(req *http.Request) (*http.Response, error) {
+	if c.httpClient != nil {
+		return c.httpClient.Do(req)
+	}
+	return openapi.CallAPI(defaultConfiguration, req)
+}
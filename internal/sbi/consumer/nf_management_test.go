@@ -40,3 +40,35 @@ func TestSendRegisterNFInstance(t *testing.T) {
 	_, _, err = consumer.RegisterNFInstance(context.TODO())
 	require.NoError(t, err)
 }
+
+func TestBuildNfProfileRoutingIndicators(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockApp := app.NewMockApp(ctrl)
+	consumer, err := NewConsumer(mockApp)
+	require.NoError(t, err)
+
+	profile, err := consumer.buildNfProfile(&udm_context.UDMContext{
+		NfId:              "1",
+		RoutingIndicators: []string{"0", "12"},
+	})
+	require.NoError(t, err)
+	require.NotNil(t, profile.UdmInfo)
+	require.Equal(t, []string{"0", "12"}, profile.UdmInfo.RoutingIndicators)
+}
+
+func TestBuildNfProfileIPv6(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockApp := app.NewMockApp(ctrl)
+	consumer, err := NewConsumer(mockApp)
+	require.NoError(t, err)
+
+	profile, err := consumer.buildNfProfile(&udm_context.UDMContext{
+		NfId:         "1",
+		RegisterIPv6: "2001:db8::3",
+	})
+	require.NoError(t, err)
+	require.Empty(t, profile.Ipv4Addresses)
+	require.Equal(t, []string{"2001:db8::3"}, profile.Ipv6Addresses)
+}
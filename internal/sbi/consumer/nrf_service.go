@@ -2,12 +2,14 @@ package consumer
 
 import (
 	"context"
+	"net/http"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/pkg/errors"
 
+	"github.com/free5gc/openapi"
 	"github.com/free5gc/openapi/models"
 	Nnrf_NFDiscovery "github.com/free5gc/openapi/nrf/NFDiscovery"
 	Nnrf_NFManagement "github.com/free5gc/openapi/nrf/NFManagement"
@@ -16,6 +18,10 @@ import (
 	"github.com/free5gc/udm/internal/util"
 )
 
+// ErrNfInstanceNotFound is returned by the NFUpdates when the NRF does not know the UDM, e.g.
+// after a restart of the NRF
+var ErrNfInstanceNotFound = errors.New("NF instance not registered at NRF")
+
 type nnrfService struct {
 	consumer *Consumer
 
@@ -24,6 +30,9 @@ type nnrfService struct {
 
 	nfMngmntClients map[string]*Nnrf_NFManagement.APIClient
 	nfDiscClients   map[string]*Nnrf_NFDiscovery.APIClient
+
+	// NF type of the NF instances found by SearchNFInstanceNfType
+	nfInstanceNfTypes sync.Map
 }
 
 func (s *nnrfService) getNFManagementClient(uri string) *Nnrf_NFManagement.APIClient {
@@ -39,6 +48,7 @@ func (s *nnrfService) getNFManagementClient(uri string) *Nnrf_NFManagement.APICl
 
 	configuration := Nnrf_NFManagement.NewConfiguration()
 	configuration.SetBasePath(uri)
+	configuration.SetHTTPClient(s.consumer.httpClient)
 	client = Nnrf_NFManagement.NewAPIClient(configuration)
 
 	s.nfMngmntMu.RUnlock()
@@ -61,6 +71,7 @@ func (s *nnrfService) getNFDiscClient(uri string) *Nnrf_NFDiscovery.APIClient {
 
 	configuration := Nnrf_NFDiscovery.NewConfiguration()
 	configuration.SetBasePath(uri)
+	configuration.SetHTTPClient(s.consumer.httpClient)
 	client = Nnrf_NFDiscovery.NewAPIClient(configuration)
 
 	s.nfDiscMu.RUnlock()
@@ -77,7 +88,7 @@ func (s *nnrfService) SendSearchNFInstances(
 	// Set client and set url
 	udmContext := s.consumer.Context()
 
-	client := s.getNFDiscClient(udmContext.NrfUri)
+	client := s.getNFDiscClient(udmContext.GetNrfUri())
 
 	ctx, _, err := s.consumer.Context().GetTokenCtx(models.ServiceName_NNRF_DISC, models.NrfNfManagementNfType_NRF)
 	if err != nil {
@@ -93,6 +104,43 @@ func (s *nnrfService) SendSearchNFInstances(
 	return &result, nil
 }
 
+// SearchNFInstanceNfType returns the NF type of the NF instance nfInstanceId if it is one of
+// nfTypes, searching the NRF for an NF instance of each type with the ID, or "" if it is none
+func (s *nnrfService) SearchNFInstanceNfType(nfInstanceId string,
+	nfTypes []models.NrfNfManagementNfType,
+) (models.NrfNfManagementNfType, error) {
+	if nfType, ok := s.nfInstanceNfTypes.Load(nfInstanceId); ok {
+		return nfType.(models.NrfNfManagementNfType), nil
+	}
+
+	udmContext := s.consumer.Context()
+	client := s.getNFDiscClient(udmContext.GetNrfUri())
+	ctx, _, err := udmContext.GetTokenCtx(models.ServiceName_NNRF_DISC, models.NrfNfManagementNfType_NRF)
+	if err != nil {
+		return "", err
+	}
+	requesterNfType := models.NrfNfManagementNfType_UDM
+	for _, nfType := range nfTypes {
+		targetNfType := nfType
+		searchRequest := Nnrf_NFDiscovery.SearchNFInstancesRequest{
+			TargetNfType:       &targetNfType,
+			RequesterNfType:    &requesterNfType,
+			TargetNfInstanceId: &nfInstanceId,
+		}
+		rsp, errSearch := client.NFInstancesStoreApi.SearchNFInstances(ctx, &searchRequest)
+		if errSearch != nil {
+			return "", errSearch
+		}
+		for _, profile := range rsp.SearchResult.NfInstances {
+			if strings.EqualFold(profile.NfInstanceId, nfInstanceId) && profile.NfType == nfType {
+				s.nfInstanceNfTypes.Store(nfInstanceId, nfType)
+				return nfType, nil
+			}
+		}
+	}
+	return "", nil
+}
+
 func (s *nnrfService) SendNFInstancesUDR(id string, types int) string {
 	self := udm_context.GetSelf()
 	targetNfType := models.NrfNfManagementNfType_UDR
@@ -103,13 +151,14 @@ func (s *nnrfService) SendNFInstancesUDR(id string, types int) string {
 	searchNFinstanceRequest.RequesterNfType = &requestNfType
 	searchNFinstanceRequest.TargetNfType = &targetNfType
 
-	result, err := s.SendSearchNFInstances(self.NrfUri, searchNFinstanceRequest)
+	result, err := s.SendSearchNFInstances(self.GetNrfUri(), searchNFinstanceRequest)
 	if err != nil {
 		logger.ConsumerLog.Error(err.Error())
 		return ""
 	}
 	for _, profile := range result.NfInstances {
-		return util.SearchNFServiceUri(profile, models.ServiceName_NUDR_DR, models.NfServiceStatus_REGISTERED)
+		return util.SearchNFServiceUri(profile, models.ServiceName_NUDR_DR, models.NfServiceStatus_REGISTERED,
+			self.RegisterIPv4 == "")
 	}
 	return ""
 }
@@ -123,7 +172,7 @@ func (s *nnrfService) SendDeregisterNFInstance() (err error) {
 	}
 
 	udmContext := s.consumer.Context()
-	client := s.getNFManagementClient(udmContext.NrfUri)
+	client := s.getNFManagementClient(udmContext.GetNrfUri())
 
 	var derigisterNfInstanceRequest Nnrf_NFManagement.DeregisterNFInstanceRequest
 	derigisterNfInstanceRequest.NfInstanceID = &udmContext.NfId
@@ -132,11 +181,72 @@ func (s *nnrfService) SendDeregisterNFInstance() (err error) {
 	return err
 }
 
+// SendNFHeartbeat sends the heartbeat of the UDM, an NFUpdate of its status (TS 29.510 5.2.2.3.2).
+// It returns ErrNfInstanceNotFound when the NRF does not know the UDM, which has to register again.
+func (s *nnrfService) SendNFHeartbeat() error {
+	res, err := s.sendUpdateNFInstance([]models.PatchItem{
+		{
+			Op:    models.PatchOperation_REPLACE,
+			Path:  "/nfStatus",
+			Value: models.NrfNfManagementNfStatus_REGISTERED,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	// The NRF may answer with the whole profile and a new heartBeatTimer
+	if heartBeatTimer := res.NrfNfManagementNfProfile.HeartBeatTimer; heartBeatTimer > 0 {
+		s.consumer.Context().SetHeartBeatTimer(heartBeatTimer)
+	}
+	return nil
+}
+
+// SendUpdateUdmInfo updates the UdmInfo of the NF profile of the UDM at the NRF with an NFUpdate
+// (TS 29.510 5.2.2.3.2), leaving the rest of the profile as registered. It returns
+// ErrNfInstanceNotFound when the NRF does not know the UDM, which has to register again.
+func (s *nnrfService) SendUpdateUdmInfo() error {
+	_, err := s.sendUpdateNFInstance([]models.PatchItem{
+		{
+			Op:    models.PatchOperation_REPLACE,
+			Path:  "/udmInfo",
+			Value: s.consumer.Context().GetUdmInfo(),
+		},
+	})
+	return err
+}
+
+// sendUpdateNFInstance sends an NFUpdate of the NF profile of the UDM with a JSON patch
+func (s *nnrfService) sendUpdateNFInstance(patchItem []models.PatchItem) (
+	*Nnrf_NFManagement.UpdateNFInstanceResponse, error,
+) {
+	ctx, _, err := s.consumer.Context().GetTokenCtx(models.ServiceName_NNRF_NFM, models.NrfNfManagementNfType_NRF)
+	if err != nil {
+		return nil, err
+	}
+
+	udmContext := s.consumer.Context()
+	client := s.getNFManagementClient(udmContext.GetNrfUri())
+
+	var updateNfInstanceRequest Nnrf_NFManagement.UpdateNFInstanceRequest
+	updateNfInstanceRequest.NfInstanceID = &udmContext.NfId
+	updateNfInstanceRequest.PatchItem = patchItem
+	res, err := client.NFInstanceIDDocumentApi.UpdateNFInstance(ctx, &updateNfInstanceRequest)
+	if err != nil {
+		var apiErr openapi.GenericOpenAPIError
+		if errors.As(err, &apiErr) && apiErr.ErrorStatus == http.StatusNotFound {
+			return nil, ErrNfInstanceNotFound
+		}
+		return nil, err
+	}
+	return res, nil
+}
+
 func (s *nnrfService) RegisterNFInstance(ctx context.Context) (
 	resouceNrfUri string, retrieveNfInstanceID string, err error,
 ) {
 	udmContext := s.consumer.Context()
-	client := s.getNFManagementClient(udmContext.NrfUri)
+	client := s.getNFManagementClient(udmContext.GetNrfUri())
 	nfProfile, err := s.buildNfProfile(udmContext)
 	if err != nil {
 		return "", "", errors.Wrap(err, "RegisterNFInstance buildNfProfile()")
@@ -160,6 +270,10 @@ func (s *nnrfService) RegisterNFInstance(ctx context.Context) (
 			continue
 		}
 
+		if heartBeatTimer := res.NrfNfManagementNfProfile.HeartBeatTimer; heartBeatTimer > 0 {
+			udmContext.SetHeartBeatTimer(heartBeatTimer)
+		}
+
 		if res.Location == "" {
 			// NFUpdate
 			break
@@ -177,8 +291,8 @@ func (s *nnrfService) RegisterNFInstance(ctx context.Context) (
 					logger.MainLog.Infoln("OAuth2 setting receive from NRF:", oauth2)
 				}
 			}
-			udm_context.GetSelf().OAuth2Required = oauth2
-			if oauth2 && udm_context.GetSelf().NrfCertPem == "" {
+			udm_context.GetSelf().SetOAuth2Required(oauth2)
+			if oauth2 && udm_context.GetSelf().GetNrfCertPem() == "" {
 				logger.CfgLog.Error("OAuth2 enable but no nrfCertPem provided in config.")
 			}
 
@@ -194,21 +308,15 @@ func (s *nnrfService) buildNfProfile(udmContext *udm_context.UDMContext) (
 	profile.NfInstanceId = udmContext.NfId
 	profile.NfType = models.NrfNfManagementNfType_UDM
 	profile.NfStatus = models.NrfNfManagementNfStatus_REGISTERED
-	profile.Ipv4Addresses = append(profile.Ipv4Addresses, udmContext.RegisterIPv4)
+	if udmContext.RegisterIPv4 != "" {
+		profile.Ipv4Addresses = append(profile.Ipv4Addresses, udmContext.RegisterIPv4)
+	}
+	if udmContext.RegisterIPv6 != "" {
+		profile.Ipv6Addresses = append(profile.Ipv6Addresses, udmContext.RegisterIPv6)
+	}
 	for _, nfService := range udmContext.NfService {
 		profile.NfServices = append(profile.NfServices, nfService)
 	}
-	profile.UdmInfo = &models.UdmInfo{
-		// Todo
-		// SupiRanges: &[]models.SupiRange{
-		// 	{
-		// 		//from TS 29.510 6.1.6.2.9 example2
-		//		//no need to set supirange in this moment 2019/10/4
-		// 		Start:   "123456789040000",
-		// 		End:     "123456789059999",
-		// 		Pattern: "^imsi-12345678904[0-9]{4}$",
-		// 	},
-		// },
-	}
+	profile.UdmInfo = udmContext.GetUdmInfo()
 	return
 }
@@ -30,6 +30,7 @@ func (s *nudmService) GetSDMClient(uri string) *Nudm_SubscriberDataManagement.AP
 
 	configuration := Nudm_SubscriberDataManagement.NewConfiguration()
 	configuration.SetBasePath(uri)
+	configuration.SetHTTPClient(s.consumer.httpClient)
 	client = Nudm_SubscriberDataManagement.NewAPIClient(configuration)
 
 	s.nfSDMMu.RUnlock()
@@ -52,6 +53,7 @@ func (s *nudmService) GetUECMClient(uri string) *Nudm_UEContextManagement.APICli
 
 	configuration := Nudm_UEContextManagement.NewConfiguration()
 	configuration.SetBasePath(uri)
+	configuration.SetHTTPClient(s.consumer.httpClient)
 	client = Nudm_UEContextManagement.NewAPIClient(configuration)
 
 	s.nfUECMMu.RUnlock()
@@ -8,6 +8,7 @@ import (
 	Nudr_DataRepository "github.com/free5gc/openapi/udr/DataRepository"
 	udm_context "github.com/free5gc/udm/internal/context"
 	"github.com/free5gc/udm/internal/logger"
+	"github.com/free5gc/udm/internal/util"
 )
 
 type nudrService struct {
@@ -40,6 +41,8 @@ func (s *nudrService) CreateUDMClientToUDR(id string) (*Nudr_DataRepository.APIC
 
 	cfg := Nudr_DataRepository.NewConfiguration()
 	cfg.SetBasePath(uri)
+	// the SQN updates are conditional on the ETag of the authentication subscription read
+	cfg.SetHTTPClient(util.NewConditionalClient(s.consumer.send))
 	client = Nudr_DataRepository.NewAPIClient(cfg)
 
 	s.nfDRMu.RUnlock()
@@ -50,7 +53,8 @@ func (s *nudrService) CreateUDMClientToUDR(id string) (*Nudr_DataRepository.APIC
 }
 
 func (s *nudrService) getUdrURI(id string) string {
-	if strings.Contains(id, "imsi") || strings.Contains(id, "nai") { // supi
+	if strings.Contains(id, "imsi") || strings.Contains(id, "nai") ||
+		strings.HasPrefix(id, "gli-") || strings.HasPrefix(id, "gci-") { // supi
 		ue, ok := udm_context.GetSelf().UdmUeFindBySupi(id)
 		if ok {
 			if ue.UdrUri == "" {
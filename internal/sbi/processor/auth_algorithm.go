@@ -0,0 +1,202 @@
+package processor
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/free5gc/openapi/models"
+	"github.com/free5gc/udm/internal/logger"
+	"github.com/free5gc/udm/internal/util"
+	"github.com/free5gc/udm/pkg/factory"
+	"github.com/free5gc/udm/pkg/keyprovider"
+	"github.com/free5gc/udm/pkg/tuak"
+	"github.com/free5gc/util/milenage"
+)
+
+const (
+	algorithmIdTuak = "tuak"
+	keyLen          = keyStrLen / 2
+	key256Len       = 32
+	opLen           = opStrLen / 2
+	opcLen          = opcStrLen / 2
+)
+
+// authAlgorithm runs the authentication functions f1, f1*, f2, f3, f4, f5 and f5*
+// of TS 33.102 6.3 with the long-term secrets of one subscriber
+type authAlgorithm interface {
+	// F1 fills macA and macS (either may be nil) with macLen() octets
+	F1(rand, sqn, amf, macA, macS []byte) error
+	// F2345 fills res (resLen() octets), ck, ik, ak and akstar; any of them may be nil
+	F2345(rand, res, ck, ik, ak, akstar []byte) error
+	macLen() int
+	resLen() int
+}
+
+type milenageAlgorithm struct {
+	opc, k []byte
+}
+
+func (m *milenageAlgorithm) F1(rand, sqn, amf, macA, macS []byte) error {
+	return milenage.F1(m.opc, m.k, rand, sqn, amf, macA, macS)
+}
+
+func (m *milenageAlgorithm) F2345(rand, res, ck, ik, ak, akstar []byte) error {
+	return milenage.F2345(m.opc, m.k, rand, res, ck, ik, ak, akstar)
+}
+
+func (m *milenageAlgorithm) macLen() int { return 8 }
+
+func (m *milenageAlgorithm) resLen() int { return 8 }
+
+type tuakAlgorithm struct {
+	topc, k []byte
+	profile factory.TuakProfile
+}
+
+func (t *tuakAlgorithm) F1(rand, sqn, amf, macA, macS []byte) error {
+	return tuak.F1(t.topc, t.k, rand, sqn, amf, macA, macS, t.profile.GetKeccakIterations())
+}
+
+func (t *tuakAlgorithm) F2345(rand, res, ck, ik, ak, akstar []byte) error {
+	return tuak.F2345(t.topc, t.k, rand, res, ck, ik, ak, akstar, t.profile.GetKeccakIterations())
+}
+
+func (t *tuakAlgorithm) macLen() int { return t.profile.GetMacLength() / 8 }
+
+func (t *tuakAlgorithm) resLen() int { return t.profile.GetResLength() / 8 }
+
+// getTuakProfile returns the TUAK profile selected by the subscription's algorithmId. Any
+// algorithmId other than "tuak" or one of the configured TUAK profiles selects Milenage.
+func getTuakProfile(algorithmId string, tuakProfiles []factory.TuakProfile) (factory.TuakProfile, bool) {
+	for _, profile := range tuakProfiles {
+		if profile.AlgorithmId == algorithmId {
+			return profile, true
+		}
+	}
+	if strings.EqualFold(algorithmId, algorithmIdTuak) {
+		return factory.TuakProfile{AlgorithmId: algorithmId}, true
+	}
+	return factory.TuakProfile{}, false
+}
+
+// getKeyEncryptionKey returns the key-encryption key selected by the encryptionKey identifier
+// in protectionParameterId, or nil for plaintext subscribers (identifier 0 or empty). Any other
+// identifier must select a configured key-encryption key, so that encrypted keys are never used
+// as plaintext.
+func getKeyEncryptionKey(protectionParameterId string,
+	keks []factory.KeyEncryptionKey,
+) (*factory.KeyEncryptionKey, error) {
+	if protectionParameterId == "" || protectionParameterId == "0" {
+		return nil, nil
+	}
+	encryptionKey, err := strconv.Atoi(protectionParameterId)
+	if err != nil {
+		return nil, fmt.Errorf("protectionParameterId [%s] is not an encryptionKey identifier", protectionParameterId)
+	}
+	for i := range keks {
+		if keks[i].EncryptionKey == encryptionKey {
+			return &keks[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no key-encryption key configured for encryptionKey [%d]", encryptionKey)
+}
+
+// decryptWithKek decrypts a subscriber key with kek, in the key provider when kek is a reference
+func decryptWithKek(cipherText []byte, kek *factory.KeyEncryptionKey,
+	provider keyprovider.KeyProvider,
+) ([]byte, error) {
+	if kek.KeyRef == "" {
+		return util.DecryptWithKek(cipherText, *kek)
+	}
+	if provider == nil {
+		return nil, fmt.Errorf("no key provider for key-encryption key [%s]", kek.KeyRef)
+	}
+	return provider.Decrypt(kek.KeyRef, kek.EncryptionAlgorithm, cipherText)
+}
+
+// decodeKey decodes a hex key from the UDR, decrypts it with kek if any, and
+// checks that the plaintext key has one of the allowed lengths in octets
+func decodeKey(name, value string, kek *factory.KeyEncryptionKey, provider keyprovider.KeyProvider,
+	allowedLen ...int,
+) ([]byte, error) {
+	if value == "" {
+		return nil, fmt.Errorf("%s == ''", name)
+	}
+	key, err := hex.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	if kek != nil {
+		key, err = decryptWithKek(key, kek, provider)
+		if err != nil {
+			return nil, fmt.Errorf("decrypt %s: %w", name, err)
+		}
+	}
+	for _, l := range allowedLen {
+		if len(key) == l {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("len(%s) is %d octets, should be one of %v", name, len(key), allowedLen)
+}
+
+func newAuthAlgorithm(authSubs *models.AuthenticationSubscription,
+	tuakProfiles []factory.TuakProfile, keks []factory.KeyEncryptionKey, provider keyprovider.KeyProvider,
+) (authAlgorithm, error) {
+	/*
+		K, RAND, CK, IK: 128 bits (16 bytes) (hex len = 32)
+		SQN, AK: 48 bits (6 bytes) (hex len = 12) TS33.102 - 6.3.2
+		AMF: 16 bits (2 bytes) (hex len = 4) TS33.102 - Annex H
+		TUAK K: 128 or 256 bits, TOPc: 256 bits (hex len = 64) TS35.231 - 6.1
+	*/
+	kek, err := getKeyEncryptionKey(authSubs.ProtectionParameterId, keks)
+	if err != nil {
+		return nil, err
+	}
+
+	if profile, ok := getTuakProfile(authSubs.AlgorithmId, tuakProfiles); ok {
+		logger.UeauLog.Tracef("Use TUAK for algorithmId [%s]", authSubs.AlgorithmId)
+		k, errK := decodeKey("EncPermanentKey", authSubs.EncPermanentKey, kek, provider, keyLen, key256Len)
+		if errK != nil {
+			return nil, errK
+		}
+		// For TUAK subscriptions encTopcKey carries TOPc
+		topc, errTopc := decodeKey("EncTopcKey", authSubs.EncTopcKey, kek, provider, tuak.TopLen)
+		if errTopc != nil {
+			return nil, errTopc
+		}
+		return &tuakAlgorithm{topc: topc, k: k, profile: profile}, nil
+	}
+
+	k, err := decodeKey("EncPermanentKey", authSubs.EncPermanentKey, kek, provider, keyLen)
+	if err != nil {
+		return nil, err
+	}
+
+	if authSubs.EncOpcKey != "" {
+		opc, errOpc := decodeKey("EncOpcKey", authSubs.EncOpcKey, kek, provider, opcLen)
+		if errOpc == nil {
+			return &milenageAlgorithm{opc: opc, k: k}, nil
+		}
+		logger.UeauLog.Errorln("err:", errOpc)
+	} else {
+		logger.UeauLog.Infoln("Nil Opc")
+	}
+
+	// Subscribers provisioned with the operator OP instead of a per-card OPc
+	if authSubs.EncTopcKey == "" {
+		return nil, fmt.Errorf("neither OPc nor OP is provisioned")
+	}
+	op, err := decodeKey("OP", authSubs.EncTopcKey, kek, provider, opLen)
+	if err != nil {
+		return nil, err
+	}
+	// TS 35.206 4.1: OPc = OP XOR E[OP]K
+	opc, err := milenage.GenerateOPC(k, op)
+	if err != nil {
+		return nil, fmt.Errorf("milenage GenerateOPC: %w", err)
+	}
+	return &milenageAlgorithm{opc: opc, k: k}, nil
+}
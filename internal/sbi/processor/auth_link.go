@@ -0,0 +1,110 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/free5gc/openapi"
+	"github.com/free5gc/openapi/models"
+	Nudr_DataRepository "github.com/free5gc/openapi/udr/DataRepository"
+	"github.com/free5gc/udm/internal/logger"
+	"github.com/free5gc/udm/pkg/factory"
+)
+
+// servingNetworkName is the serving network name of the PLMN or SNPN of plmnId (TS 24.501 9.12.1)
+func servingNetworkName(plmnId *models.PlmnIdNid) string {
+	mnc := plmnId.Mnc
+	if len(mnc) == 2 {
+		mnc = "0" + mnc
+	}
+	name := fmt.Sprintf("5G:mnc%s.mcc%s.3gppnetwork.org", mnc, plmnId.Mcc)
+	if plmnId.Nid != "" {
+		name += ":" + plmnId.Nid
+	}
+	return name
+}
+
+// authLinkFailure returns why authEvent does not link the registration through guami to an
+// authentication within window, or "" when it does
+func authLinkFailure(authEvent *models.AuthEvent, guami *models.Guami, window time.Duration) string {
+	switch {
+	case guami == nil || guami.PlmnId == nil:
+		return "the registration carries no GUAMI"
+	case authEvent == nil:
+		return "no authentication"
+	case !authEvent.Success || authEvent.AuthRemovalInd:
+		return "no successful authentication"
+	case authEvent.ServingNetworkName != servingNetworkName(guami.PlmnId):
+		return fmt.Sprintf("authentication in serving network [%s]", authEvent.ServingNetworkName)
+	case authEvent.TimeStamp == nil || time.Since(*authEvent.TimeStamp) > window:
+		return fmt.Sprintf("no authentication within the last %s", window)
+	default:
+		return ""
+	}
+}
+
+// lastAuthEvent returns the last authentication result of supi. The result confirmed to this UDM
+// is kept when it links the registration through guami, the UDR is queried otherwise as another
+// UDM instance may have confirmed a later authentication.
+func (p *Processor) lastAuthEvent(ctx context.Context, supi string, guami *models.Guami,
+	window time.Duration,
+) (*models.AuthEvent, error) {
+	authEvent, ok := p.Context().LoadAuthEvent(supi)
+	if ok && authLinkFailure(authEvent, guami, window) == "" {
+		return authEvent, nil
+	}
+
+	client, err := p.Consumer().CreateUDMClientToUDR(supi)
+	if err != nil {
+		return nil, err
+	}
+	var queryAuthStatusRequest Nudr_DataRepository.QueryAuthenticationStatusRequest
+	queryAuthStatusRequest.UeId = &supi
+	authStatus, err := client.AuthEventDocumentApi.QueryAuthenticationStatus(ctx, &queryAuthStatusRequest)
+	if err != nil {
+		if apiError, ok := err.(openapi.GenericOpenAPIError); ok && apiError.ErrorStatus == http.StatusNotFound {
+			return authEvent, nil
+		}
+		return nil, err
+	}
+	return &authStatus.AuthEvent, nil
+}
+
+// checkAuthLink verifies that the AMF registering supi serves the network in which the UE
+// successfully authenticated within the configured window (TS 33.501 6.1.4.1). A registration
+// failing the check, or which cannot be checked, is only logged in flag mode and rejected in
+// reject mode.
+func (p *Processor) checkAuthLink(ctx context.Context, supi string, guami *models.Guami) *models.ProblemDetails {
+	authLink := p.Context().AuthLink
+	mode := authLink.GetMode()
+	if mode == factory.AuthLinkModeOff {
+		return nil
+	}
+
+	authEvent, err := p.lastAuthEvent(ctx, supi, guami, authLink.GetWindow())
+	if err != nil {
+		if mode == factory.AuthLinkModeFlag {
+			logger.UecmLog.Warnf("AMF registration of [%s] not checked, query auth event error: %+v", supi, err)
+			return nil
+		}
+		logger.UecmLog.Errorf("Query auth event of [%s] error: %+v", supi, err)
+		return openapi.ProblemDetailsSystemFailure(err.Error())
+	}
+
+	reason := authLinkFailure(authEvent, guami, authLink.GetWindow())
+	if reason == "" {
+		return nil
+	}
+	if mode == factory.AuthLinkModeFlag {
+		logger.UecmLog.Warnf("AMF registration of [%s] not linked to an authentication: %s", supi, reason)
+		return nil
+	}
+	logger.UecmLog.Errorf("Reject AMF registration of [%s]: %s", supi, reason)
+	return &models.ProblemDetails{
+		Status: http.StatusForbidden,
+		Cause:  "ACCESS_NOT_ALLOWED",
+		Detail: "AMF registration not linked to an authentication: " + reason,
+	}
+}
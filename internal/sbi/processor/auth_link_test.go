@@ -0,0 +1,179 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+
+	"github.com/free5gc/openapi/models"
+	"github.com/free5gc/udm/pkg/factory"
+)
+
+func TestServingNetworkName(t *testing.T) {
+	require.Equal(t, "5G:mnc093.mcc208.3gppnetwork.org",
+		servingNetworkName(&models.PlmnIdNid{Mcc: "208", Mnc: "93"}))
+	require.Equal(t, "5G:mnc410.mcc310.3gppnetwork.org",
+		servingNetworkName(&models.PlmnIdNid{Mcc: "310", Mnc: "410"}))
+	require.Equal(t, "5G:mnc093.mcc208.3gppnetwork.org:000007ed9d5",
+		servingNetworkName(&models.PlmnIdNid{Mcc: "208", Mnc: "93", Nid: "000007ed9d5"}))
+}
+
+func TestCheckAuthLink(t *testing.T) {
+	guami := &models.Guami{PlmnId: &models.PlmnIdNid{Mcc: "208", Mnc: "93"}, AmfId: "cafe00"}
+	recent := time.Now().Add(-5 * time.Second)
+	old := time.Now().Add(-time.Hour)
+	successfulAuth := func(timeStamp *time.Time) *models.AuthEvent {
+		return &models.AuthEvent{
+			NfInstanceId:       "ausf-1",
+			Success:            true,
+			TimeStamp:          timeStamp,
+			AuthType:           models.UdmUeauAuthType__5_G_AKA,
+			ServingNetworkName: "5G:mnc093.mcc208.3gppnetwork.org",
+		}
+	}
+
+	testCases := []struct {
+		name string
+		mode string
+		// authentication confirmed to this UDM, or else stored in the UDR by another instance
+		localAuthEvent *models.AuthEvent
+		udrAuthEvent   *models.AuthEvent
+		// the UDR fails to answer the query of the authentication status
+		udrFailure bool
+		guami      *models.Guami
+		// status of the rejection, 0 when the registration is accepted
+		expectStatus int32
+	}{
+		{
+			name:  "check disabled",
+			guami: guami,
+		},
+		{
+			name:           "recent authentication",
+			mode:           factory.AuthLinkModeReject,
+			localAuthEvent: successfulAuth(&recent),
+			guami:          guami,
+		},
+		{
+			name:         "authentication by another UDM instance",
+			mode:         factory.AuthLinkModeReject,
+			udrAuthEvent: successfulAuth(&recent),
+			guami:        guami,
+		},
+		{
+			name:         "no authentication",
+			mode:         factory.AuthLinkModeReject,
+			guami:        guami,
+			expectStatus: http.StatusForbidden,
+		},
+		{
+			name:           "authentication outside the window",
+			mode:           factory.AuthLinkModeReject,
+			localAuthEvent: successfulAuth(&old),
+			guami:          guami,
+			expectStatus:   http.StatusForbidden,
+		},
+		{
+			name:           "other serving network",
+			mode:           factory.AuthLinkModeReject,
+			localAuthEvent: successfulAuth(&recent),
+			guami:          &models.Guami{PlmnId: &models.PlmnIdNid{Mcc: "001", Mnc: "01"}, AmfId: "cafe00"},
+			expectStatus:   http.StatusForbidden,
+		},
+		{
+			name: "failed authentication",
+			mode: factory.AuthLinkModeReject,
+			localAuthEvent: func() *models.AuthEvent {
+				authEvent := successfulAuth(&recent)
+				authEvent.Success = false
+				return authEvent
+			}(),
+			guami:        guami,
+			expectStatus: http.StatusForbidden,
+		},
+		{
+			name: "removed authentication",
+			mode: factory.AuthLinkModeReject,
+			localAuthEvent: func() *models.AuthEvent {
+				authEvent := successfulAuth(&recent)
+				authEvent.AuthRemovalInd = true
+				return authEvent
+			}(),
+			guami:        guami,
+			expectStatus: http.StatusForbidden,
+		},
+		{
+			name:           "authentication outside the window, later one by another UDM instance",
+			mode:           factory.AuthLinkModeReject,
+			localAuthEvent: successfulAuth(&old),
+			udrAuthEvent:   successfulAuth(&recent),
+			guami:          guami,
+		},
+		{
+			name:  "flagged only",
+			mode:  factory.AuthLinkModeFlag,
+			guami: guami,
+		},
+		{
+			name:       "UDR failure flagged only",
+			mode:       factory.AuthLinkModeFlag,
+			udrFailure: true,
+			guami:      guami,
+		},
+		{
+			name:         "UDR failure",
+			mode:         factory.AuthLinkModeReject,
+			udrFailure:   true,
+			guami:        guami,
+			expectStatus: http.StatusInternalServerError,
+		},
+	}
+	for i, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			supi := fmt.Sprintf("imsi-2089300000001%02d", i)
+			udr := &stubUdr{authEvent: tc.udrAuthEvent, authStatusFailure: tc.udrFailure}
+			server := httptest.NewServer(h2c.NewHandler(udr, &http2.Server{}))
+			defer server.Close()
+			testProcessor := newStubUdrProcessor(t, supi, server.URL)
+			testProcessor.Context().AuthLink = &factory.AuthLink{Mode: tc.mode, Window: 60}
+			if tc.localAuthEvent != nil {
+				testProcessor.Context().StoreAuthEvent(supi, tc.localAuthEvent)
+			}
+
+			problemDetails := testProcessor.checkAuthLink(context.Background(), supi, tc.guami)
+			if tc.expectStatus == 0 {
+				require.Nil(t, problemDetails)
+				return
+			}
+			require.NotNil(t, problemDetails)
+			require.Equal(t, tc.expectStatus, problemDetails.Status)
+		})
+	}
+}
+
+func TestRegistrationAmf3gppAccessWithoutAuthentication(t *testing.T) {
+	supi := "imsi-208930000000030"
+	udr := &stubUdr{}
+	server := httptest.NewServer(h2c.NewHandler(udr, &http2.Server{}))
+	defer server.Close()
+	testProcessor := newStubUdrProcessor(t, supi, server.URL)
+	testProcessor.Context().AuthLink = &factory.AuthLink{Mode: factory.AuthLinkModeReject}
+
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	testProcessor.RegistrationAmf3gppAccessProcedure(c, models.Amf3GppAccessRegistration{
+		AmfInstanceId: "rogue-amf",
+		Guami:         &models.Guami{PlmnId: &models.PlmnIdNid{Mcc: "208", Mnc: "93"}, AmfId: "cafe00"},
+		RatType:       models.RatType_NR,
+	}, supi)
+	require.Equal(t, http.StatusForbidden, rec.Code, rec.Body.String())
+	require.Nil(t, testProcessor.Context().GetAmf3gppRegContext(supi))
+}
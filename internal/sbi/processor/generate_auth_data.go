@@ -1,13 +1,11 @@
 package processor
 
 import (
-	cryptoRand "crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
-	"math/big"
 	"math/rand"
 	"net/http"
-	"reflect"
 	"strings"
 	"time"
 
@@ -17,17 +15,15 @@ import (
 	"github.com/free5gc/openapi/models"
 	Nudr_DataRepository "github.com/free5gc/openapi/udr/DataRepository"
 	"github.com/free5gc/udm/internal/logger"
+	"github.com/free5gc/udm/pkg/factory"
 	"github.com/free5gc/udm/pkg/suci"
-	"github.com/free5gc/util/milenage"
 	"github.com/free5gc/util/ueauth"
 )
 
 const (
-	SqnMAx    int64 = 0xFFFFFFFFFFFF
-	ind       int64 = 32
-	keyStrLen int   = 32
-	opStrLen  int   = 32
-	opcStrLen int   = 32
+	keyStrLen int = 32
+	opStrLen  int = 32
+	opcStrLen int = 32
 )
 
 const (
@@ -35,9 +31,9 @@ const (
 	resyncAMF              string = "0000"
 )
 
-func (p *Processor) aucSQN(opc, k, auts, rand []byte) ([]byte, []byte) {
+func (p *Processor) aucSQN(alg authAlgorithm, auts, rand []byte) ([]byte, []byte) {
 	AK, SQNms := make([]byte, 6), make([]byte, 6)
-	macS := make([]byte, 8)
+	macS := make([]byte, alg.macLen())
 	ConcSQNms := auts[:6]
 	AMF, err := hex.DecodeString(resyncAMF)
 	if err != nil {
@@ -46,26 +42,26 @@ func (p *Processor) aucSQN(opc, k, auts, rand []byte) ([]byte, []byte) {
 
 	logger.UeauLog.Tracef("aucSQN: ConcSQNms=[%x]", ConcSQNms)
 
-	err = milenage.F2345(opc, k, rand, nil, nil, nil, nil, AK)
+	err = alg.F2345(rand, nil, nil, nil, nil, AK)
 	if err != nil {
-		logger.UeauLog.Errorln("aucSQN milenage F2345 err:", err)
+		logger.UeauLog.Errorln("aucSQN F2345 err:", err)
 	}
 
 	for i := 0; i < 6; i++ {
 		SQNms[i] = AK[i] ^ ConcSQNms[i]
 	}
 
-	logger.UeauLog.Tracef("aucSQN: opc=[%x], k=[%x], rand=[%x], AMF=[%x], SQNms=[%x]\n", opc, k, rand, AMF, SQNms)
+	logger.UeauLog.Tracef("aucSQN: rand=[%x], AMF=[%x], SQNms=[%x]\n", rand, AMF, SQNms)
 	// The AMF used to calculate MAC-S assumes a dummy value of all zeros
-	err = milenage.F1(opc, k, rand, SQNms, AMF, nil, macS)
+	err = alg.F1(rand, SQNms, AMF, nil, macS)
 	if err != nil {
-		logger.UeauLog.Errorln("aucSQN milenage F1 err:", err)
+		logger.UeauLog.Errorln("aucSQN F1 err:", err)
 	}
 	logger.UeauLog.Tracef("aucSQN: macS=[%x]\n", macS)
 	return SQNms, macS
 }
 
-func (p *Processor) strictHex(ss string, n int) string {
+func strictHex(ss string, n int) string {
 	l := len(ss)
 	if l < n {
 		return strings.Repeat("0", n-l) + ss
@@ -108,8 +104,203 @@ func (p *Processor) ConfirmAuthDataProcedure(c *gin.Context,
 		return
 	}
 
-	// AuthEvent in response body is optional
-	c.JSON(http.StatusCreated, gin.H{})
+	p.Context().StoreAuthEvent(supi, &authEvent)
+	c.Header("Location", p.Context().GetUEAUUri()+"/"+supi+"/auth-events/"+authEventId(&authEvent))
+	c.JSON(http.StatusCreated, authEvent)
+}
+
+// authEventId identifies the authentication status stored in the UDR by the event it records, so
+// that any UDM instance can address the event of a Location header later on
+func authEventId(authEvent *models.AuthEvent) string {
+	var timeStamp string
+	if authEvent.TimeStamp != nil {
+		timeStamp = authEvent.TimeStamp.UTC().Format(time.RFC3339Nano)
+	}
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		authEvent.NfInstanceId, authEvent.ServingNetworkName, string(authEvent.AuthType), timeStamp,
+	}, "|")))
+	return hex.EncodeToString(sum[:16])
+}
+
+// DeleteAuthDataProcedure marks the authentication result of authEventId as removed in the UDR,
+// e.g. when the UE is deregistered
+func (p *Processor) DeleteAuthDataProcedure(c *gin.Context,
+	authEvent models.AuthEvent,
+	supi string,
+	authEventIdInUri string,
+) {
+	if !authEvent.AuthRemovalInd {
+		problemDetails := &models.ProblemDetails{
+			Status: http.StatusBadRequest,
+			Cause:  "MANDATORY_IE_INCORRECT",
+			Detail: "authRemovalInd is not set",
+		}
+		logger.UeauLog.Errorln(problemDetails.Detail)
+		c.JSON(int(problemDetails.Status), problemDetails)
+		return
+	}
+
+	ctx, pd, err := p.Context().GetTokenCtx(models.ServiceName_NUDR_DR, models.NrfNfManagementNfType_UDR)
+	if err != nil {
+		c.JSON(int(pd.Status), pd)
+		return
+	}
+	client, err := p.Consumer().CreateUDMClientToUDR(supi)
+	if err != nil {
+		problemDetails := openapi.ProblemDetailsSystemFailure(err.Error())
+		c.JSON(int(problemDetails.Status), problemDetails)
+		return
+	}
+
+	var queryAuthStatusRequest Nudr_DataRepository.QueryAuthenticationStatusRequest
+	queryAuthStatusRequest.UeId = &supi
+	authStatus, err := client.AuthEventDocumentApi.QueryAuthenticationStatus(ctx, &queryAuthStatusRequest)
+	if err != nil {
+		apiError, ok := err.(openapi.GenericOpenAPIError)
+		if ok {
+			c.JSON(apiError.ErrorStatus, apiError.RawBody)
+			return
+		}
+		logger.UeauLog.Errorln("DeleteAuth err:", err.Error())
+		problemDetails := openapi.ProblemDetailsSystemFailure(err.Error())
+		c.JSON(int(problemDetails.Status), problemDetails)
+		return
+	}
+
+	storedAuthEvent := authStatus.AuthEvent
+	if authEventId(&storedAuthEvent) != authEventIdInUri {
+		problemDetails := &models.ProblemDetails{
+			Status: http.StatusNotFound,
+			Cause:  "DATA_NOT_FOUND",
+			Detail: fmt.Sprintf("auth event [%s] of [%s] not found", authEventIdInUri, supi),
+		}
+		logger.UeauLog.Warnln(problemDetails.Detail)
+		c.JSON(int(problemDetails.Status), problemDetails)
+		return
+	}
+	storedAuthEvent.AuthRemovalInd = true
+
+	var createAuthStatusRequest Nudr_DataRepository.CreateAuthenticationStatusRequest
+	createAuthStatusRequest.AuthEvent = &storedAuthEvent
+	createAuthStatusRequest.UeId = &supi
+	_, err = client.AuthenticationStatusDocumentApi.CreateAuthenticationStatus(
+		ctx, &createAuthStatusRequest)
+	if err != nil {
+		apiError, ok := err.(openapi.GenericOpenAPIError)
+		if ok {
+			c.JSON(apiError.ErrorStatus, apiError.RawBody)
+			return
+		}
+		logger.UeauLog.Errorln("DeleteAuth err:", err.Error())
+		problemDetails := openapi.ProblemDetailsSystemFailure(err.Error())
+		c.JSON(int(problemDetails.Status), problemDetails)
+		return
+	}
+
+	p.Context().StoreAuthEvent(supi, &storedAuthEvent)
+	logger.UeauLog.Infof("Auth event [%s] of [%s] removed", authEventIdInUri, supi)
+	c.Status(http.StatusNoContent)
+}
+
+// deriveAuthenticationVector derives the 5G HE AKA or EAP-AKA' vector of the authentication
+// method for the serving network from vector (TS 33.501 6.1.3)
+func deriveAuthenticationVector(vector *akaVector, authMethod models.AuthMethod, servingNetworkName string) (
+	av models.AuthenticationVector, authType models.UdmUeauAuthType,
+) {
+	RAND, RES, CK, IK := vector.rand, vector.xres, vector.ck, vector.ik
+	SQNxorAK, AUTN := vector.sqnXorAk, vector.autn
+
+	if authMethod == models.AuthMethod__5_G_AKA {
+		authType = models.UdmUeauAuthType__5_G_AKA
+
+		// derive XRES*
+		key := append(CK, IK...)
+		FC := ueauth.FC_FOR_RES_STAR_XRES_STAR_DERIVATION
+		P0 := []byte(servingNetworkName)
+		P1 := RAND
+		P2 := RES
+
+		kdfValForXresStar, err := ueauth.GetKDFValue(
+			key, FC, P0, ueauth.KDFLen(P0), P1, ueauth.KDFLen(P1), P2, ueauth.KDFLen(P2))
+		if err != nil {
+			logger.UeauLog.Errorf("Get kdfValForXresStar err: %+v", err)
+		}
+		xresStar := kdfValForXresStar[len(kdfValForXresStar)/2:]
+		logger.UeauLog.Tracef("xresStar=[%x]", xresStar)
+
+		// derive Kausf
+		FC = ueauth.FC_FOR_KAUSF_DERIVATION
+		P0 = []byte(servingNetworkName)
+		P1 = SQNxorAK
+		kdfValForKausf, err := ueauth.GetKDFValue(key, FC, P0, ueauth.KDFLen(P0), P1, ueauth.KDFLen(P1))
+		if err != nil {
+			logger.UeauLog.Errorf("Get kdfValForKausf err: %+v", err)
+		}
+		logger.UeauLog.Tracef("Kausf=[%x]", kdfValForKausf)
+
+		// Fill in rand, xresStar, autn, kausf
+		av.Rand = hex.EncodeToString(RAND)
+		av.XresStar = hex.EncodeToString(xresStar)
+		av.Autn = hex.EncodeToString(AUTN)
+		av.Kausf = hex.EncodeToString(kdfValForKausf)
+		av.AvType = models.AvType__5_G_HE_AKA
+	} else { // EAP-AKA'
+		authType = models.UdmUeauAuthType_EAP_AKA_PRIME
+		// derive CK' and IK'
+		key := append(CK, IK...)
+		FC := ueauth.FC_FOR_CK_PRIME_IK_PRIME_DERIVATION
+		P0 := []byte(servingNetworkName)
+		P1 := SQNxorAK
+		kdfVal, err := ueauth.GetKDFValue(key, FC, P0, ueauth.KDFLen(P0), P1, ueauth.KDFLen(P1))
+		if err != nil {
+			logger.UeauLog.Errorf("Get kdfVal err: %+v", err)
+		}
+		logger.UeauLog.Tracef("kdfVal=[%x] (len=%d)", kdfVal, len(kdfVal))
+
+		// For TS 35.208 test set 19 & RFC 5448 test vector 1
+		// CK': 0093 962d 0dd8 4aa5 684b 045c 9edf fa04
+		// IK': ccfc 230c a74f cc96 c0a5 d611 64f5 a76
+
+		ckPrime := kdfVal[:len(kdfVal)/2]
+		ikPrime := kdfVal[len(kdfVal)/2:]
+		logger.UeauLog.Tracef("ckPrime=[%x], kPrime=[%x]", ckPrime, ikPrime)
+
+		// Fill in rand, xres, autn, ckPrime, ikPrime
+		av.Rand = hex.EncodeToString(RAND)
+		av.Xres = hex.EncodeToString(RES)
+		av.Autn = hex.EncodeToString(AUTN)
+		av.CkPrime = hex.EncodeToString(ckPrime)
+		av.IkPrime = hex.EncodeToString(ikPrime)
+		av.AvType = models.AvType_EAP_AKA_PRIME
+	}
+
+	return av, authType
+}
+
+// ComputeAuthenticationVector computes the vector of the subscriber of the plaintext authSubs for
+// rand, sqn and amf, as the UDM would for a 5G-AKA or EAP-AKA' authentication in the serving
+// network, for debugging vectors offline. The algorithm is selected as for GenerateAuthDataProcedure:
+// TUAK for an algorithmId of tuakProfiles or "tuak", Milenage otherwise
+func ComputeAuthenticationVector(authSubs *models.AuthenticationSubscription, tuakProfiles []factory.TuakProfile,
+	rand, sqn, amf []byte, authMethod models.AuthMethod, servingNetworkName string,
+) (*models.AuthenticationVector, error) {
+	if len(rand) != 16 || len(sqn) != sqnLen || len(amf) != 2 {
+		return nil, fmt.Errorf("RAND, SQN and AMF should be 16, %d and 2 octets", sqnLen)
+	}
+	if authMethod != models.AuthMethod__5_G_AKA && authMethod != models.AuthMethod_EAP_AKA_PRIME {
+		return nil, fmt.Errorf("unsupported authentication method [%s]", authMethod)
+	}
+
+	alg, err := newAuthAlgorithm(authSubs, tuakProfiles, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	vector, err := computeAkaVector(alg, rand, sqn, amf)
+	if err != nil {
+		return nil, err
+	}
+	av, _ := deriveAuthenticationVector(vector, authMethod, servingNetworkName)
+	return &av, nil
 }
 
 func (p *Processor) GenerateAuthDataProcedure(
@@ -126,7 +317,11 @@ func (p *Processor) GenerateAuthDataProcedure(
 
 	response := &models.UdmUeauAuthenticationInfoResult{}
 	rand.New(rand.NewSource(time.Now().UnixNano()))
-	supi, err := suci.ToSupi(supiOrSuci, p.Context().SuciProfiles)
+	if problemDetails := p.checkSuciServed(supiOrSuci); problemDetails != nil {
+		c.JSON(int(problemDetails.Status), problemDetails)
+		return
+	}
+	supi, err := suci.ToSupi(supiOrSuci, p.Context().GetSuciProfiles())
 	if err != nil {
 		problemDetails := &models.ProblemDetails{
 			Status: http.StatusForbidden,
@@ -147,10 +342,8 @@ func (p *Processor) GenerateAuthDataProcedure(
 		c.JSON(int(problemDetails.Status), problemDetails)
 		return
 	}
-	var queryAuthSubsDataRequest Nudr_DataRepository.QueryAuthSubsDataRequest
-	queryAuthSubsDataRequest.UeId = &supi
-
-	authSubs, err := client.AuthenticationDataDocumentApi.QueryAuthSubsData(ctx, &queryAuthSubsDataRequest)
+	reservation, problemDetails, err := p.reserveSqns(ctx, client, supi, authInfoRequest.AusfInstanceId,
+		authInfoRequest.ResynchronizationInfo, 1)
 	if err != nil {
 		logger.ProcLog.Errorf("Error on QueryAuthSubsData: %+v", err)
 		apiError, ok := err.(openapi.GenericOpenAPIError)
@@ -168,74 +361,16 @@ func (p *Processor) GenerateAuthDataProcedure(
 		c.JSON(int(problemDetails.Status), problemDetails)
 		return
 	}
-
-	/*
-		K, RAND, CK, IK: 128 bits (16 bytes) (hex len = 32)
-		SQN, AK: 48 bits (6 bytes) (hex len = 12) TS33.102 - 6.3.2
-		AMF: 16 bits (2 bytes) (hex len = 4) TS33.102 - Annex H
-	*/
-
-	hasOPC := false
-	var kStr, opcStr string
-	var k, op, opc []byte
-	if authSubs.AuthenticationSubscription.EncPermanentKey != "" {
-		kStr = authSubs.AuthenticationSubscription.EncPermanentKey
-		if len(kStr) == keyStrLen {
-			k, err = hex.DecodeString(kStr)
-			if err != nil {
-				logger.UeauLog.Errorln("err:", err)
-			}
-		} else {
-			problemDetails := &models.ProblemDetails{
-				Status: http.StatusForbidden,
-				Cause:  authenticationRejected,
-				Detail: "len(kStr) != keyStrLen",
-			}
-
-			logger.UeauLog.Errorln("kStr length is ", len(kStr))
-			c.JSON(int(problemDetails.Status), problemDetails)
-			return
-		}
-	} else {
-		problemDetails := &models.ProblemDetails{
-			Status: http.StatusForbidden,
-			Cause:  authenticationRejected,
-			Detail: "EncPermanentKey == ''",
-		}
-
-		logger.UeauLog.Errorln("Nil PermanentKey")
-		c.JSON(int(problemDetails.Status), problemDetails)
-		return
-	}
-
-	if authSubs.AuthenticationSubscription.EncOpcKey != "" {
-		opcStr = authSubs.AuthenticationSubscription.EncOpcKey
-		if len(opcStr) == opcStrLen {
-			opc, err = hex.DecodeString(opcStr)
-			if err != nil {
-				logger.UeauLog.Errorln("err:", err)
-			} else {
-				hasOPC = true
-			}
-		} else {
-			logger.UeauLog.Errorln("opcStr length is ", len(opcStr))
-		}
-	} else {
-		logger.UeauLog.Infoln("Nil Opc")
-	}
-
-	if !hasOPC {
-		problemDetails := &models.ProblemDetails{
-			Status: http.StatusForbidden,
-			Cause:  authenticationRejected,
-		}
+	if problemDetails != nil {
 		c.JSON(int(problemDetails.Status), problemDetails)
 		return
 	}
+	authSubs, alg, sqn := reservation.authSubs, reservation.alg, reservation.sqns[0]
+	logger.UeauLog.Tracef("sqn=[%x]", sqn)
 
-	sqnStr := p.strictHex(authSubs.AuthenticationSubscription.SequenceNumber.Sqn, 12)
-	logger.UeauLog.Traceln("sqnStr", sqnStr)
-	sqn, err := hex.DecodeString(sqnStr)
+	amfStr := strictHex(authSubs.AuthenticationManagementField, 4)
+	logger.UeauLog.Traceln("amfStr", amfStr)
+	AMF, err := hex.DecodeString(amfStr)
 	if err != nil {
 		problemDetails := &models.ProblemDetails{
 			Status: http.StatusForbidden,
@@ -248,10 +383,7 @@ func (p *Processor) GenerateAuthDataProcedure(
 		return
 	}
 
-	logger.UeauLog.Tracef("K=[%x], sqn=[%x], OP=[%x], OPC=[%x]", k, sqn, op, opc)
-
-	RAND := make([]byte, 16)
-	_, err = cryptoRand.Read(RAND)
+	vector, err := newAkaVector(alg, sqn, AMF)
 	if err != nil {
 		problemDetails := &models.ProblemDetails{
 			Status: http.StatusForbidden,
@@ -263,99 +395,32 @@ func (p *Processor) GenerateAuthDataProcedure(
 		c.JSON(int(problemDetails.Status), problemDetails)
 		return
 	}
+	av, authType := deriveAuthenticationVector(vector, authSubs.AuthenticationMethod,
+		authInfoRequest.ServingNetworkName)
+	response.AuthType = authType
+	response.AuthenticationVector = &av
+	response.Supi = supi
+	c.JSON(http.StatusOK, response)
+}
 
-	amfStr := p.strictHex(authSubs.AuthenticationSubscription.AuthenticationManagementField, 4)
-	logger.UeauLog.Traceln("amfStr", amfStr)
-	AMF, err := hex.DecodeString(amfStr)
+// GetRgAuthDataProcedure tells the AUSF whether a 5G-RG or FN-RG accessing through a W-AGF is
+// authenticated by the wireline access network (TS 33.501 7B.7): the authentication reported by
+// the W-AGF in authenticatedInd is only accepted when the subscription allows it. Otherwise
+// authInd is false and the RG has to be authenticated by the 5GC.
+func (p *Processor) GetRgAuthDataProcedure(c *gin.Context, supiOrSuci string, authenticatedInd bool,
+	supportedFeatures string,
+) {
+	ctx, pd, err := p.Context().GetTokenCtx(models.ServiceName_NUDR_DR, models.NrfNfManagementNfType_UDR)
 	if err != nil {
-		problemDetails := &models.ProblemDetails{
-			Status: http.StatusForbidden,
-			Cause:  authenticationRejected,
-			Detail: err.Error(),
-		}
-
-		logger.UeauLog.Errorln("err:", err)
-		c.JSON(int(problemDetails.Status), problemDetails)
+		c.JSON(int(pd.Status), pd)
 		return
 	}
 
-	logger.UeauLog.Tracef("RAND=[%x], AMF=[%x]", RAND, AMF)
-
-	// re-synchronization
-	if authInfoRequest.ResynchronizationInfo != nil {
-		logger.UeauLog.Infof("Authentication re-synchronization")
-
-		Auts, deCodeErr := hex.DecodeString(authInfoRequest.ResynchronizationInfo.Auts)
-		if deCodeErr != nil {
-			problemDetails := &models.ProblemDetails{
-				Status: http.StatusForbidden,
-				Cause:  authenticationRejected,
-				Detail: deCodeErr.Error(),
-			}
-
-			logger.UeauLog.Errorln("err:", deCodeErr)
-			c.JSON(int(problemDetails.Status), problemDetails)
-			return
-		}
-
-		randHex, deCodeErr := hex.DecodeString(authInfoRequest.ResynchronizationInfo.Rand)
-		if deCodeErr != nil {
-			problemDetails := &models.ProblemDetails{
-				Status: http.StatusForbidden,
-				Cause:  authenticationRejected,
-				Detail: deCodeErr.Error(),
-			}
-
-			logger.UeauLog.Errorln("err:", deCodeErr)
-			c.JSON(int(problemDetails.Status), problemDetails)
-			return
-		}
-
-		SQNms, macS := p.aucSQN(opc, k, Auts, randHex)
-		if reflect.DeepEqual(macS, Auts[6:]) {
-			_, err = cryptoRand.Read(RAND)
-			if err != nil {
-				problemDetails := &models.ProblemDetails{
-					Status: http.StatusForbidden,
-					Cause:  authenticationRejected,
-					Detail: err.Error(),
-				}
-
-				logger.UeauLog.Errorln("err:", err)
-				c.JSON(int(problemDetails.Status), problemDetails)
-				return
-			}
-
-			// increment sqn authSubs.SequenceNumber
-			bigSQN := big.NewInt(0)
-			sqnStr = hex.EncodeToString(SQNms)
-			logger.UeauLog.Tracef("SQNstr=[%s]", sqnStr)
-			bigSQN.SetString(sqnStr, 16)
-
-			bigInc := big.NewInt(ind + 1)
-
-			bigP := big.NewInt(SqnMAx)
-			bigSQN = bigInc.Add(bigSQN, bigInc)
-			bigSQN = bigSQN.Mod(bigSQN, bigP)
-			sqnStr = fmt.Sprintf("%x", bigSQN)
-			sqnStr = p.strictHex(sqnStr, 12)
-		} else {
-			logger.UeauLog.Errorf("Re-Sync MAC failed for UE with identity supiOrSuci=[%s], resolvedSupi=[%s]", supiOrSuci, supi)
-			logger.UeauLog.Errorln("MACS ", macS)
-			logger.UeauLog.Errorln("Auts[6:] ", Auts[6:])
-			logger.UeauLog.Errorln("Sqn ", SQNms)
-			problemDetails := &models.ProblemDetails{
-				Status: http.StatusForbidden,
-				Cause:  "modification is rejected",
-			}
-			c.JSON(int(problemDetails.Status), problemDetails)
-			return
-		}
+	if problemDetails := p.checkSuciServed(supiOrSuci); problemDetails != nil {
+		c.JSON(int(problemDetails.Status), problemDetails)
+		return
 	}
-
-	// increment sqn
-	bigSQN := big.NewInt(0)
-	sqn, err = hex.DecodeString(sqnStr)
+	supi, err := suci.ToSupi(supiOrSuci, p.Context().GetSuciProfiles())
 	if err != nil {
 		problemDetails := &models.ProblemDetails{
 			Status: http.StatusForbidden,
@@ -363,144 +428,42 @@ func (p *Processor) GenerateAuthDataProcedure(
 			Detail: err.Error(),
 		}
 
-		logger.UeauLog.Errorln("err:", err)
+		logger.UeauLog.Errorln("suciToSupi error: ", err.Error())
 		c.JSON(int(problemDetails.Status), problemDetails)
 		return
 	}
+	logger.UeauLog.Tracef("supi conversion => [%s]", supi)
 
-	bigSQN.SetString(sqnStr, 16)
-
-	bigInc := big.NewInt(1)
-	bigSQN = bigInc.Add(bigSQN, bigInc)
-
-	SQNheStr := fmt.Sprintf("%x", bigSQN)
-	SQNheStr = p.strictHex(SQNheStr, 12)
-	patchItemArray := []models.PatchItem{
-		{
-			Op:   models.PatchOperation_REPLACE,
-			Path: "/sequenceNumber",
-			Value: models.SequenceNumber{
-				Sqn: SQNheStr,
-			},
-		},
-	}
-
-	logger.ProcLog.Infoln("ModifyAuthenticationSubscriptionRequest: ", patchItemArray)
-
-	var modifyAuthenticationSubscriptionRequest Nudr_DataRepository.ModifyAuthenticationSubscriptionRequest
-	modifyAuthenticationSubscriptionRequest.UeId = &supi
-	modifyAuthenticationSubscriptionRequest.PatchItem = patchItemArray
-	_, err = client.AuthenticationSubscriptionDocumentApi.ModifyAuthenticationSubscription(
-		ctx, &modifyAuthenticationSubscriptionRequest)
+	client, err := p.Consumer().CreateUDMClientToUDR(supi)
 	if err != nil {
-		problemDetails := &models.ProblemDetails{
-			Status: http.StatusForbidden,
-			Cause:  "modification is rejected ",
-			Detail: err.Error(),
-		}
-
-		logger.UeauLog.Errorln("update sqn error:", err)
+		problemDetails := openapi.ProblemDetailsSystemFailure(err.Error())
 		c.JSON(int(problemDetails.Status), problemDetails)
 		return
 	}
 
-	// Run milenage
-	macA, macS := make([]byte, 8), make([]byte, 8)
-	CK, IK := make([]byte, 16), make([]byte, 16)
-	RES := make([]byte, 8)
-	AK, AKstar := make([]byte, 6), make([]byte, 6)
-
-	// Generate macA, macS
-	err = milenage.F1(opc, k, RAND, sqn, AMF, macA, macS)
-	if err != nil {
-		logger.UeauLog.Errorln("milenage F1 err:", err)
-	}
-
-	// Generate RES, CK, IK, AK, AKstar
-	// RES == XRES (expected RES) for server
-	err = milenage.F2345(opc, k, RAND, RES, CK, IK, AK, AKstar)
+	var queryAuthSubsDataRequest Nudr_DataRepository.QueryAuthSubsDataRequest
+	queryAuthSubsDataRequest.UeId = &supi
+	authSubs, err := client.AuthenticationDataDocumentApi.QueryAuthSubsData(ctx, &queryAuthSubsDataRequest)
 	if err != nil {
-		logger.UeauLog.Errorln("milenage F2345 err:", err)
-	}
-	logger.UeauLog.Tracef("milenage RES=[%s]", hex.EncodeToString(RES))
-
-	// Generate AUTN
-	logger.UeauLog.Tracef("SQN=[%x], AK=[%x]", sqn, AK)
-	logger.UeauLog.Tracef("AMF=[%x], macA=[%x]", AMF, macA)
-	SQNxorAK := make([]byte, 6)
-	for i := 0; i < len(sqn); i++ {
-		SQNxorAK[i] = sqn[i] ^ AK[i]
-	}
-	logger.UeauLog.Tracef("SQN xor AK=[%x]", SQNxorAK)
-	AUTN := append(append(SQNxorAK, AMF...), macA...)
-	logger.UeauLog.Tracef("AUTN=[%x]", AUTN)
-
-	var av models.AuthenticationVector
-	if authSubs.AuthenticationSubscription.AuthenticationMethod == models.AuthMethod__5_G_AKA {
-		response.AuthType = models.UdmUeauAuthType__5_G_AKA
-
-		// derive XRES*
-		key := append(CK, IK...)
-		FC := ueauth.FC_FOR_RES_STAR_XRES_STAR_DERIVATION
-		P0 := []byte(authInfoRequest.ServingNetworkName)
-		P1 := RAND
-		P2 := RES
-
-		kdfValForXresStar, err := ueauth.GetKDFValue(
-			key, FC, P0, ueauth.KDFLen(P0), P1, ueauth.KDFLen(P1), P2, ueauth.KDFLen(P2))
-		if err != nil {
-			logger.UeauLog.Errorf("Get kdfValForXresStar err: %+v", err)
-		}
-		xresStar := kdfValForXresStar[len(kdfValForXresStar)/2:]
-		logger.UeauLog.Tracef("xresStar=[%x]", xresStar)
-
-		// derive Kausf
-		FC = ueauth.FC_FOR_KAUSF_DERIVATION
-		P0 = []byte(authInfoRequest.ServingNetworkName)
-		P1 = SQNxorAK
-		kdfValForKausf, err := ueauth.GetKDFValue(key, FC, P0, ueauth.KDFLen(P0), P1, ueauth.KDFLen(P1))
-		if err != nil {
-			logger.UeauLog.Errorf("Get kdfValForKausf err: %+v", err)
-		}
-		logger.UeauLog.Tracef("Kausf=[%x]", kdfValForKausf)
-
-		// Fill in rand, xresStar, autn, kausf
-		av.Rand = hex.EncodeToString(RAND)
-		av.XresStar = hex.EncodeToString(xresStar)
-		av.Autn = hex.EncodeToString(AUTN)
-		av.Kausf = hex.EncodeToString(kdfValForKausf)
-		av.AvType = models.AvType__5_G_HE_AKA
-	} else { // EAP-AKA'
-		response.AuthType = models.UdmUeauAuthType_EAP_AKA_PRIME
-		// derive CK' and IK'
-		key := append(CK, IK...)
-		FC := ueauth.FC_FOR_CK_PRIME_IK_PRIME_DERIVATION
-		P0 := []byte(authInfoRequest.ServingNetworkName)
-		P1 := SQNxorAK
-		kdfVal, err := ueauth.GetKDFValue(key, FC, P0, ueauth.KDFLen(P0), P1, ueauth.KDFLen(P1))
-		if err != nil {
-			logger.UeauLog.Errorf("Get kdfVal err: %+v", err)
+		logger.ProcLog.Errorf("Error on QueryAuthSubsData: %+v", err)
+		apiError, ok := err.(openapi.GenericOpenAPIError)
+		if ok {
+			c.JSON(apiError.ErrorStatus, apiError.RawBody)
+			return
 		}
-		logger.UeauLog.Tracef("kdfVal=[%x] (len=%d)", kdfVal, len(kdfVal))
-
-		// For TS 35.208 test set 19 & RFC 5448 test vector 1
-		// CK': 0093 962d 0dd8 4aa5 684b 045c 9edf fa04
-		// IK': ccfc 230c a74f cc96 c0a5 d611 64f5 a76
+		problemDetails := openapi.ProblemDetailsSystemFailure(err.Error())
+		c.JSON(int(problemDetails.Status), problemDetails)
+		return
+	}
 
-		ckPrime := kdfVal[:len(kdfVal)/2]
-		ikPrime := kdfVal[len(kdfVal)/2:]
-		logger.UeauLog.Tracef("ckPrime=[%x], kPrime=[%x]", ckPrime, ikPrime)
+	rgAuthenticationInd := authSubs.AuthenticationSubscription.RgAuthenticationInd
+	logger.UeauLog.Infof("RG [%s] authenticatedInd [%t], rgAuthenticationInd [%t]", supi, authenticatedInd,
+		rgAuthenticationInd)
 
-		// Fill in rand, xres, autn, ckPrime, ikPrime
-		av.Rand = hex.EncodeToString(RAND)
-		av.Xres = hex.EncodeToString(RES)
-		av.Autn = hex.EncodeToString(AUTN)
-		av.CkPrime = hex.EncodeToString(ckPrime)
-		av.IkPrime = hex.EncodeToString(ikPrime)
-		av.AvType = models.AvType_EAP_AKA_PRIME
+	response := &models.RgAuthCtx{
+		AuthInd:           authenticatedInd && rgAuthenticationInd,
+		Supi:              supi,
+		SupportedFeatures: supportedFeatures,
 	}
-
-	response.AuthenticationVector = &av
-	response.Supi = supi
 	c.JSON(http.StatusOK, response)
 }
@@ -1,20 +1,35 @@
 package processor
 
 import (
+	"crypto/aes"
+	cryptoRand "crypto/rand"
+	"encoding/hex"
+	"encoding/json"
 	"io"
+	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/h2non/gock"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 
 	"github.com/free5gc/openapi"
 	"github.com/free5gc/openapi/models"
 	udm_context "github.com/free5gc/udm/internal/context"
 	"github.com/free5gc/udm/internal/sbi/consumer"
+	"github.com/free5gc/udm/pkg/factory"
+	"github.com/free5gc/udm/pkg/keyprovider"
 	mockapp "github.com/free5gc/udm/pkg/mockapp"
+	"github.com/free5gc/util/milenage"
+	"github.com/free5gc/util/ueauth"
 )
 
 func TestGenerateAuthDataProcedure(t *testing.T) {
@@ -26,7 +41,7 @@ func TestGenerateAuthDataProcedure(t *testing.T) {
 	queryRes := models.AuthenticationSubscription{
 		AuthenticationMethod:          models.AuthMethod__5_G_AKA,
 		EncPermanentKey:               "8baf473f2f8fd09487cccbd7097c6862",
-		ProtectionParameterId:         "8baf473f2f8fd09487cccbd7097c6862",
+		ProtectionParameterId:         "0",
 		SequenceNumber:                &models.SequenceNumber{Sqn: "000000000023"},
 		AuthenticationManagementField: "8000",
 		AlgorithmId:                   "128-EEA0",
@@ -110,3 +125,413 @@ func TestGenerateAuthDataProcedure(t *testing.T) {
 	require.Equal(t, expectResponse.Supi, res.Supi)
 	require.Equal(t, expectResponse.AuthenticationVector.AvType, res.AuthenticationVector.AvType)
 }
+
+// generateAuthData runs GenerateAuthDataProcedure against a mocked UDR holding authSubs
+func generateAuthData(t *testing.T, supi string, authSubs models.AuthenticationSubscription,
+	udmContext *udm_context.UDMContext, authInfoReq models.AuthenticationInfoRequest,
+) (int, models.UdmUeauAuthenticationInfoResult) {
+	t.Helper()
+	defer gock.Off() // Flush pending mocks after test execution
+
+	openapi.InterceptH2CClient()
+	defer openapi.RestoreH2CClient()
+
+	gock.New("http://127.0.0.4:8000/nudr-dr/v2").
+		Get("/subscription-data/"+supi+"/authentication-data/authentication-subscription").
+		Reply(200).
+		AddHeader("Content-Type", "application/json").
+		JSON(authSubs)
+
+	gock.New("http://127.0.0.4:8000").
+		Patch("/nudr-dr/v2/subscription-data/" + supi + "/authentication-data/authentication-subscription").
+		Reply(204).
+		JSON(map[string]string{})
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockApp := mockapp.NewMockApp(ctrl)
+	testConsumer, err := consumer.NewConsumer(mockApp)
+	require.NoError(t, err)
+	testProcessor, err := NewProcessor(mockApp)
+	require.NoError(t, err)
+	ue := new(udm_context.UdmUeContext)
+	ue.Init()
+	ue.Supi = supi
+	ue.UdrUri = "http://127.0.0.4:8000"
+	udm_context.GetSelf().UdmUePool.Store(supi, ue)
+
+	mockApp.EXPECT().Consumer().Return(testConsumer).AnyTimes()
+	mockApp.EXPECT().Context().Return(udmContext).AnyTimes()
+
+	httpRecorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(httpRecorder)
+	testProcessor.GenerateAuthDataProcedure(c, authInfoReq, supi)
+
+	httpResp := httpRecorder.Result()
+	rawBytes, err := io.ReadAll(httpResp.Body)
+	require.NoError(t, err)
+	require.NoError(t, httpResp.Body.Close())
+
+	var res models.UdmUeauAuthenticationInfoResult
+	if httpResp.StatusCode == 200 {
+		err = openapi.Deserialize(&res, rawBytes, httpResp.Header.Get("Content-Type"))
+		require.NoError(t, err)
+	}
+	return httpResp.StatusCode, res
+}
+
+func mustDecodeHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	require.NoError(t, err)
+	return b
+}
+
+func TestGenerateAuthDataProcedureWithOP(t *testing.T) {
+	// TS 35.208 Test Set 1
+	queryRes := models.AuthenticationSubscription{
+		AuthenticationMethod:          models.AuthMethod__5_G_AKA,
+		EncPermanentKey:               "465b5ce8b199b49faa5f0a2ee238a6bc",
+		SequenceNumber:                &models.SequenceNumber{Sqn: "ff9bb4d0b606"},
+		AuthenticationManagementField: "b9b9",
+		EncTopcKey:                    "cdc202d5123e20f62b6d676ac72cb318",
+	}
+	expectOpc := mustDecodeHex(t, "cd63cb71954a9f4e48a5994e37a02baf")
+
+	status, res := generateAuthData(t, "imsi-208930000000002", queryRes,
+		&udm_context.UDMContext{
+			OAuth2Required: false,
+			NrfUri:         "http://127.0.0.10:8000",
+			NfId:           "1",
+		},
+		models.AuthenticationInfoRequest{
+			ServingNetworkName: "5G:mnc093.mcc208.3gppnetwork.org",
+		})
+	require.Equal(t, 200, status)
+	require.Equal(t, models.UdmUeauAuthType__5_G_AKA, res.AuthType)
+
+	// The MAC-A in AUTN must be the one computed with the OPc derived from OP
+	k := mustDecodeHex(t, queryRes.EncPermanentKey)
+	randBytes := mustDecodeHex(t, res.AuthenticationVector.Rand)
+	autn := mustDecodeHex(t, res.AuthenticationVector.Autn)
+	// SEQ_HE + 1 with the IND following the stored one
+	sqn := mustDecodeHex(t, "ff9bb4d0b627")
+	amf := mustDecodeHex(t, queryRes.AuthenticationManagementField)
+
+	macA := make([]byte, 8)
+	require.NoError(t, milenage.F1(expectOpc, k, randBytes, sqn, amf, macA, nil))
+	require.Equal(t, macA, autn[8:])
+}
+
+func TestGenerateAuthDataProcedureWithTuak(t *testing.T) {
+	// TS 35.232 Test Set 1
+	randRead = func(b []byte) (int, error) {
+		return copy(b, mustDecodeHex(t, "42424242424242424242424242424242")), nil
+	}
+	t.Cleanup(func() { randRead = cryptoRand.Read })
+	queryRes := models.AuthenticationSubscription{
+		AuthenticationMethod: models.AuthMethod_EAP_AKA_PRIME,
+		EncPermanentKey:      "abababababababababababababababab",
+		// SQN 111111111111 of the test set follows SEQ_HE 0x8888888887 || IND 16
+		SequenceNumber:                &models.SequenceNumber{Sqn: "1111111110f0"},
+		AuthenticationManagementField: "ffff",
+		AlgorithmId:                   "tuak-res32",
+		EncTopcKey:                    "bd04d9530e87513c5d837ac2ad954623a8e2330c115305a73eb45d1f40cccbff",
+	}
+	servingNetworkName := "5G:mnc093.mcc208.3gppnetwork.org"
+
+	status, res := generateAuthData(t, "imsi-208930000000003", queryRes,
+		&udm_context.UDMContext{
+			OAuth2Required: false,
+			NrfUri:         "http://127.0.0.10:8000",
+			NfId:           "1",
+			TuakProfiles: []factory.TuakProfile{
+				{
+					AlgorithmId: "tuak-res32",
+					MacLength:   64,
+					ResLength:   32,
+				},
+			},
+		},
+		models.AuthenticationInfoRequest{
+			ServingNetworkName: servingNetworkName,
+		})
+	require.Equal(t, 200, status)
+	require.Equal(t, models.UdmUeauAuthType_EAP_AKA_PRIME, res.AuthType)
+
+	av := res.AuthenticationVector
+	require.Equal(t, "42424242424242424242424242424242", av.Rand)
+	// AUTN = SQN xor AK || AMF || MAC-A with AK 719f1e9b9054 and MAC-A f9a54e6aeaa8618d
+	require.Equal(t, "608e0f8a8145"+"ffff"+"f9a54e6aeaa8618d", av.Autn)
+	require.Equal(t, "657acd64", av.Xres)
+
+	// CK' and IK' derived from CK and IK of the test set (TS 33.402 A.2)
+	ckIk := mustDecodeHex(t, "d71a1e5c6caffe986a26f783e5c78be1"+"be849fa2564f869aecee6f62d4337e72")
+	sqnXorAk := mustDecodeHex(t, "608e0f8a8145")
+	kdfVal, err := ueauth.GetKDFValue(ckIk, ueauth.FC_FOR_CK_PRIME_IK_PRIME_DERIVATION,
+		[]byte(servingNetworkName), ueauth.KDFLen([]byte(servingNetworkName)), sqnXorAk, ueauth.KDFLen(sqnXorAk))
+	require.NoError(t, err)
+	require.Equal(t, hex.EncodeToString(kdfVal[:16]), av.CkPrime)
+	require.Equal(t, hex.EncodeToString(kdfVal[16:]), av.IkPrime)
+}
+
+func TestGenerateAuthDataProcedureWithKek(t *testing.T) {
+	// TS 35.208 Test Set 1, K and OPc encrypted with AES-ECB under key-encryption key 1
+	k := mustDecodeHex(t, "465b5ce8b199b49faa5f0a2ee238a6bc")
+	opc := mustDecodeHex(t, "cd63cb71954a9f4e48a5994e37a02baf")
+	kek := factory.KeyEncryptionKey{
+		EncryptionKey:       1,
+		EncryptionAlgorithm: factory.EncryptionAlgorithmAesEcb,
+		Key:                 "000102030405060708090a0b0c0d0e0f",
+	}
+	block, err := aes.NewCipher(mustDecodeHex(t, kek.Key))
+	require.NoError(t, err)
+	encK, encOpc := make([]byte, 16), make([]byte, 16)
+	block.Encrypt(encK, k)
+	block.Encrypt(encOpc, opc)
+
+	queryRes := models.AuthenticationSubscription{
+		AuthenticationMethod:          models.AuthMethod__5_G_AKA,
+		EncPermanentKey:               hex.EncodeToString(encK),
+		EncOpcKey:                     hex.EncodeToString(encOpc),
+		ProtectionParameterId:         "1",
+		SequenceNumber:                &models.SequenceNumber{Sqn: "ff9bb4d0b606"},
+		AuthenticationManagementField: "b9b9",
+	}
+	authInfoReq := models.AuthenticationInfoRequest{
+		ServingNetworkName: "5G:mnc093.mcc208.3gppnetwork.org",
+	}
+
+	status, res := generateAuthData(t, "imsi-208930000000004", queryRes,
+		&udm_context.UDMContext{
+			NrfUri:            "http://127.0.0.10:8000",
+			NfId:              "1",
+			KeyEncryptionKeys: []factory.KeyEncryptionKey{kek},
+		}, authInfoReq)
+	require.Equal(t, 200, status)
+
+	randBytes := mustDecodeHex(t, res.AuthenticationVector.Rand)
+	autn := mustDecodeHex(t, res.AuthenticationVector.Autn)
+	macA := make([]byte, 8)
+	require.NoError(t, milenage.F1(opc, k, randBytes, mustDecodeHex(t, "ff9bb4d0b627"),
+		mustDecodeHex(t, queryRes.AuthenticationManagementField), macA, nil))
+	require.Equal(t, macA, autn[8:])
+
+	// The same key-encryption key referenced in the key provider
+	kekPath := filepath.Join(t.TempDir(), "kek1")
+	require.NoError(t, os.WriteFile(kekPath, []byte(kek.Key), 0o600))
+	status, res = generateAuthData(t, "imsi-208930000000004", queryRes,
+		&udm_context.UDMContext{
+			NrfUri: "http://127.0.0.10:8000",
+			NfId:   "1",
+			KeyEncryptionKeys: []factory.KeyEncryptionKey{{
+				EncryptionKey:       kek.EncryptionKey,
+				EncryptionAlgorithm: kek.EncryptionAlgorithm,
+				KeyRef:              kekPath,
+			}},
+			KeyProvider: keyprovider.NewFileProvider(),
+		}, authInfoReq)
+	require.Equal(t, 200, status)
+	randBytes = mustDecodeHex(t, res.AuthenticationVector.Rand)
+	autn = mustDecodeHex(t, res.AuthenticationVector.Autn)
+	require.NoError(t, milenage.F1(opc, k, randBytes, mustDecodeHex(t, "ff9bb4d0b627"),
+		mustDecodeHex(t, queryRes.AuthenticationManagementField), macA, nil))
+	require.Equal(t, macA, autn[8:])
+
+	// Without the key-encryption key the subscriber is rejected
+	status, _ = generateAuthData(t, "imsi-208930000000004", queryRes,
+		&udm_context.UDMContext{
+			NrfUri: "http://127.0.0.10:8000",
+			NfId:   "1",
+		}, authInfoReq)
+	require.Equal(t, 403, status)
+
+	// An identifier which is not an encryptionKey does not make the keys plaintext
+	queryRes.ProtectionParameterId = "8baf473f2f8fd09487cccbd7097c6862"
+	status, _ = generateAuthData(t, "imsi-208930000000004", queryRes,
+		&udm_context.UDMContext{
+			NrfUri:            "http://127.0.0.10:8000",
+			NfId:              "1",
+			KeyEncryptionKeys: []factory.KeyEncryptionKey{kek},
+		}, authInfoReq)
+	require.Equal(t, 403, status)
+}
+
+func TestGetRgAuthDataProcedure(t *testing.T) {
+	supi := "gli-line-0001@wireline.example.com"
+	testCases := []struct {
+		name                string
+		supiOrSuci          string
+		authenticatedInd    bool
+		rgAuthenticationInd bool
+		expectAuthInd       bool
+	}{
+		{
+			name:                "authenticated by the wireline network",
+			supiOrSuci:          "suci-3-wireline.example.com-0-0-0-line-0001",
+			authenticatedInd:    true,
+			rgAuthenticationInd: true,
+			expectAuthInd:       true,
+		},
+		{
+			name:             "not allowed by the subscription",
+			supiOrSuci:       supi,
+			authenticatedInd: true,
+		},
+		{
+			name:                "not authenticated by the W-AGF",
+			supiOrSuci:          supi,
+			rgAuthenticationInd: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			udr := &stubUdr{
+				authSubs: models.AuthenticationSubscription{
+					AuthenticationMethod: models.AuthMethod__5_G_AKA,
+					RgAuthenticationInd:  tc.rgAuthenticationInd,
+				},
+			}
+			server := httptest.NewServer(h2c.NewHandler(udr, &http2.Server{}))
+			defer server.Close()
+			testProcessor := newStubUdrProcessor(t, supi, server.URL)
+
+			rec := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(rec)
+			testProcessor.GetRgAuthDataProcedure(c, tc.supiOrSuci, tc.authenticatedInd, "")
+			require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+			var res models.RgAuthCtx
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+			require.Equal(t, supi, res.Supi)
+			require.Equal(t, tc.expectAuthInd, res.AuthInd)
+		})
+	}
+}
+
+func TestConfirmAndDeleteAuthDataProcedure(t *testing.T) {
+	supi := "imsi-208930000000014"
+	udr := &stubUdr{}
+	server := httptest.NewServer(h2c.NewHandler(udr, &http2.Server{}))
+	defer server.Close()
+	testProcessor := newStubUdrProcessor(t, supi, server.URL)
+
+	timeStamp := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	authEvent := models.AuthEvent{
+		NfInstanceId:       "ausf-1",
+		Success:            true,
+		TimeStamp:          &timeStamp,
+		AuthType:           models.UdmUeauAuthType__5_G_AKA,
+		ServingNetworkName: "5G:mnc093.mcc208.3gppnetwork.org",
+	}
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	testProcessor.ConfirmAuthDataProcedure(c, authEvent, supi)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	location := rec.Header().Get("Location")
+	prefix := factory.UdmUeauResUriPrefix + "/" + supi + "/auth-events/"
+	require.Contains(t, location, prefix)
+	eventId := location[strings.Index(location, prefix)+len(prefix):]
+	require.NotEmpty(t, eventId)
+	require.NotNil(t, udr.authEvent)
+	require.False(t, udr.authEvent.AuthRemovalInd)
+
+	testCases := []struct {
+		name         string
+		authEventId  string
+		removalInd   bool
+		expectStatus int
+	}{
+		{
+			name:         "unknown auth event",
+			authEventId:  "00000000000000000000000000000000",
+			removalInd:   true,
+			expectStatus: http.StatusNotFound,
+		},
+		{
+			name:         "missing authRemovalInd",
+			authEventId:  eventId,
+			expectStatus: http.StatusBadRequest,
+		},
+		{
+			name:         "removal",
+			authEventId:  eventId,
+			removalInd:   true,
+			expectStatus: http.StatusNoContent,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			removal := authEvent
+			removal.AuthRemovalInd = tc.removalInd
+			rec := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(rec)
+			testProcessor.DeleteAuthDataProcedure(c, removal, supi, tc.authEventId)
+			c.Writer.WriteHeaderNow()
+			require.Equal(t, tc.expectStatus, rec.Code, rec.Body.String())
+			require.Equal(t, tc.expectStatus == http.StatusNoContent, udr.authEvent.AuthRemovalInd)
+			require.Equal(t, authEvent.NfInstanceId, udr.authEvent.NfInstanceId)
+		})
+	}
+}
+
+func TestComputeAuthenticationVector(t *testing.T) {
+	// TS 35.208 Test Set 19, the vector of RFC 5448 Appendix C Test Case 1
+	authSubs := &models.AuthenticationSubscription{
+		EncPermanentKey: "5122250214c33e723a5dd523fc145fc0",
+		EncOpcKey:       "981d464c7c52eb6e5036234984ad0bcf",
+	}
+	rand := mustDecodeHex(t, "81e92b6c0ee0e12ebceba8d92a99dfa5")
+	sqn := mustDecodeHex(t, "16f3b3f70fc2")
+	amf := mustDecodeHex(t, "c3ab")
+
+	av, err := ComputeAuthenticationVector(authSubs, nil, rand, sqn, amf, models.AuthMethod_EAP_AKA_PRIME, "WLAN")
+	require.NoError(t, err)
+	require.Equal(t, models.AvType_EAP_AKA_PRIME, av.AvType)
+	require.Equal(t, "81e92b6c0ee0e12ebceba8d92a99dfa5", av.Rand)
+	require.Equal(t, "bb52e91c747ac3ab2a5c23d15ee351d5", av.Autn)
+	require.Equal(t, "28d7b0f2a2ec3de5", av.Xres)
+	require.Equal(t, "0093962d0dd84aa5684b045c9edffa04", av.CkPrime)
+	require.Equal(t, "ccfc230ca74fcc96c0a5d61164f5a76c", av.IkPrime)
+
+	av, err = ComputeAuthenticationVector(authSubs, nil, rand, sqn, amf, models.AuthMethod__5_G_AKA,
+		"5G:mnc093.mcc208.3gppnetwork.org")
+	require.NoError(t, err)
+	require.Equal(t, models.AvType__5_G_HE_AKA, av.AvType)
+	require.Equal(t, "bb52e91c747ac3ab2a5c23d15ee351d5", av.Autn)
+	require.Len(t, av.XresStar, 32)
+	require.Len(t, av.Kausf, 64)
+
+	shortOpc := &models.AuthenticationSubscription{
+		EncPermanentKey: authSubs.EncPermanentKey,
+		EncOpcKey:       authSubs.EncOpcKey[:16],
+	}
+	_, err = ComputeAuthenticationVector(shortOpc, nil, rand, sqn, amf, models.AuthMethod__5_G_AKA, "WLAN")
+	require.Error(t, err)
+}
+
+func TestComputeAuthenticationVectorTuak(t *testing.T) {
+	// TS 35.232 Test Set 1, with the 32-bit RES of the test set
+	authSubs := &models.AuthenticationSubscription{
+		EncPermanentKey: "abababababababababababababababab",
+		EncTopcKey:      "bd04d9530e87513c5d837ac2ad954623a8e2330c115305a73eb45d1f40cccbff",
+		AlgorithmId:     "tuak-res32",
+	}
+	tuakProfiles := []factory.TuakProfile{{AlgorithmId: "tuak-res32", ResLength: 32}}
+	rand := mustDecodeHex(t, "42424242424242424242424242424242")
+	sqn := mustDecodeHex(t, "111111111111")
+	amf := mustDecodeHex(t, "ffff")
+
+	av, err := ComputeAuthenticationVector(authSubs, tuakProfiles, rand, sqn, amf, models.AuthMethod_EAP_AKA_PRIME,
+		"WLAN")
+	require.NoError(t, err)
+	require.Equal(t, "657acd64", av.Xres)
+	// AUTN = SQN xor AK || AMF || MAC-A
+	require.True(t, strings.HasSuffix(av.Autn, "fffff9a54e6aeaa8618d"), av.Autn)
+
+	// algorithmId "tuak" selects TUAK with the default 64-bit RES without a TUAK profile
+	authSubs.AlgorithmId = "tuak"
+	av, err = ComputeAuthenticationVector(authSubs, nil, rand, sqn, amf, models.AuthMethod_EAP_AKA_PRIME, "WLAN")
+	require.NoError(t, err)
+	require.Len(t, av.Xres, 16)
+}
@@ -0,0 +1,357 @@
+package processor
+
+import (
+	cryptoRand "crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/free5gc/openapi"
+	"github.com/free5gc/openapi/models"
+	"github.com/free5gc/udm/internal/logger"
+	"github.com/free5gc/util/ueauth"
+)
+
+const (
+	// FC of the KASME derivation (TS 33.401 A.2)
+	fcForKasmeDerivation = "10"
+	// numOfRequestedVectors range of HssAuthenticationInfoRequest (TS 29.503)
+	minHssVectors = 1
+	maxHssVectors = 5
+	// lastIndexes key of the vectors requested by an HSS without a requesting node type
+	hssIndKey = "hss"
+	// lastIndexes key of the vectors requested by a BSF
+	bsfIndKey = "bsf"
+	// separation bit of the AMF (TS 33.102 Annex H)
+	amfSeparationBit = 0x80
+)
+
+var (
+	mccRegexp = regexp.MustCompile(`^\d{3}$`)
+	mncRegexp = regexp.MustCompile(`^\d{2,3}$`)
+)
+
+// hssAuthTypes maps the hssAuthType of the URI to the authentication type of the request body
+// and the type of the vectors generated for it
+var hssAuthTypes = map[models.HssAuthTypeInUri]struct {
+	authType models.HssAuthType
+	avType   models.HssAvType
+}{
+	models.HssAuthTypeInUri_EPS_AKA: {models.HssAuthType_EPS_AKA, models.HssAvType_EPS_AKA},
+	models.HssAuthTypeInUri_IMS_AKA: {models.HssAuthType_IMS_AKA, models.HssAvType_IMS_AKA},
+	models.HssAuthTypeInUri_EAP_AKA: {models.HssAuthType_EAP_AKA, models.HssAvType_EAP_AKA},
+	models.HssAuthTypeInUri_GBA_AKA: {models.HssAuthType_GBA_AKA, models.HssAvType_GBA_AKA},
+}
+
+// hssAuthenticationInfoResult is the HssAuthenticationInfoResult of TS 29.503, whose
+// HssAuthenticationVectors oneOf is left empty by the generated models
+type hssAuthenticationInfoResult struct {
+	SupportedFeatures        string      `json:"supportedFeatures,omitempty"`
+	HssAuthenticationVectors interface{} `json:"hssAuthenticationVectors"`
+}
+
+// akaVector is the output of the authentication functions for one SQN (TS 33.102 6.3.2)
+type akaVector struct {
+	rand     []byte
+	xres     []byte
+	ck       []byte
+	ik       []byte
+	sqnXorAk []byte
+	autn     []byte
+}
+
+// randRead fills the RAND of the vectors, replaced by the tests with the RAND of test sets
+var randRead = cryptoRand.Read
+
+// newAkaVector generates a RAND and computes the vector of sqn and amf with alg
+func newAkaVector(alg authAlgorithm, sqn, amf []byte) (*akaVector, error) {
+	rand := make([]byte, 16)
+	if _, err := randRead(rand); err != nil {
+		return nil, err
+	}
+	return computeAkaVector(alg, rand, sqn, amf)
+}
+
+// computeAkaVector computes the vector of rand, sqn and amf with alg
+func computeAkaVector(alg authAlgorithm, rand, sqn, amf []byte) (*akaVector, error) {
+	v := &akaVector{
+		rand:     rand,
+		xres:     make([]byte, alg.resLen()),
+		ck:       make([]byte, 16),
+		ik:       make([]byte, 16),
+		sqnXorAk: make([]byte, sqnLen),
+	}
+	logger.UeauLog.Tracef("RAND=[%x], AMF=[%x]", v.rand, amf)
+
+	macA, macS := make([]byte, alg.macLen()), make([]byte, alg.macLen())
+	if err := alg.F1(v.rand, sqn, amf, macA, macS); err != nil {
+		return nil, fmt.Errorf("F1: %w", err)
+	}
+
+	// RES == XRES (expected RES) for server
+	ak, akStar := make([]byte, sqnLen), make([]byte, sqnLen)
+	if err := alg.F2345(v.rand, v.xres, v.ck, v.ik, ak, akStar); err != nil {
+		return nil, fmt.Errorf("F2345: %w", err)
+	}
+	logger.UeauLog.Tracef("RES=[%x]", v.xres)
+
+	logger.UeauLog.Tracef("SQN=[%x], AK=[%x]", sqn, ak)
+	logger.UeauLog.Tracef("AMF=[%x], macA=[%x]", amf, macA)
+	for i := 0; i < sqnLen; i++ {
+		v.sqnXorAk[i] = sqn[i] ^ ak[i]
+	}
+	logger.UeauLog.Tracef("SQN xor AK=[%x]", v.sqnXorAk)
+	v.autn = append(append(append([]byte{}, v.sqnXorAk...), amf...), macA...)
+	logger.UeauLog.Tracef("AUTN=[%x]", v.autn)
+	return v, nil
+}
+
+// servingNetworkIdBytes encodes plmnId as the 3 octets of the SN id (TS 24.301 9.9.3.32)
+func servingNetworkIdBytes(plmnId *models.PlmnId) ([]byte, error) {
+	if plmnId == nil {
+		return nil, fmt.Errorf("servingNetworkId is missing")
+	}
+	if !mccRegexp.MatchString(plmnId.Mcc) || !mncRegexp.MatchString(plmnId.Mnc) {
+		return nil, fmt.Errorf("invalid servingNetworkId mcc [%s] mnc [%s]", plmnId.Mcc, plmnId.Mnc)
+	}
+	mcc, mnc := []byte(plmnId.Mcc), []byte(plmnId.Mnc)
+	for i := range mcc {
+		mcc[i] -= '0'
+	}
+	for i := range mnc {
+		mnc[i] -= '0'
+	}
+	mnc3 := byte(0xf)
+	if len(mnc) == 3 {
+		mnc3 = mnc[2]
+	}
+	return []byte{
+		mcc[1]<<4 | mcc[0],
+		mnc3<<4 | mcc[2],
+		mnc[1]<<4 | mnc[0],
+	}, nil
+}
+
+// kasme derives KASME from CK, IK, the SN id and SQN xor AK (TS 33.401 A.2)
+func kasme(v *akaVector, snId []byte) ([]byte, error) {
+	key := append(append([]byte{}, v.ck...), v.ik...)
+	return ueauth.GetKDFValue(key, fcForKasmeDerivation, snId, ueauth.KDFLen(snId), v.sqnXorAk,
+		ueauth.KDFLen(v.sqnXorAk))
+}
+
+// generateAkaVectors reserves numVectors SQNs of supi in the IND slots of indKey and computes a
+// vector for each of them. The separation bit of the AMF is set in vectors for E-UTRAN only
+// (TS 33.401 6.1.2). On failure the error response is written to c and nil is returned.
+func (p *Processor) generateAkaVectors(c *gin.Context, supi, indKey string,
+	resyncInfo *models.ResynchronizationInfo, numVectors int, eutran bool,
+) []*akaVector {
+	ctx, pd, err := p.Context().GetTokenCtx(models.ServiceName_NUDR_DR, models.NrfNfManagementNfType_UDR)
+	if err != nil {
+		c.JSON(int(pd.Status), pd)
+		return nil
+	}
+	client, err := p.Consumer().CreateUDMClientToUDR(supi)
+	if err != nil {
+		problemDetails := openapi.ProblemDetailsSystemFailure(err.Error())
+		c.JSON(int(problemDetails.Status), problemDetails)
+		return nil
+	}
+
+	reservation, problemDetails, err := p.reserveSqns(ctx, client, supi, indKey, resyncInfo, numVectors)
+	if err != nil {
+		logger.ProcLog.Errorf("Error on QueryAuthSubsData: %+v", err)
+		apiError, ok := err.(openapi.GenericOpenAPIError)
+		if ok {
+			c.JSON(apiError.ErrorStatus, apiError.RawBody)
+			return nil
+		}
+		problemDetails := openapi.ProblemDetailsSystemFailure(err.Error())
+		c.JSON(int(problemDetails.Status), problemDetails)
+		return nil
+	}
+	if problemDetails != nil {
+		c.JSON(int(problemDetails.Status), problemDetails)
+		return nil
+	}
+
+	amf, err := hex.DecodeString(strictHex(reservation.authSubs.AuthenticationManagementField, 4))
+	if err != nil {
+		problemDetails := &models.ProblemDetails{
+			Status: http.StatusForbidden,
+			Cause:  authenticationRejected,
+			Detail: err.Error(),
+		}
+		logger.UeauLog.Errorln("err:", err)
+		c.JSON(int(problemDetails.Status), problemDetails)
+		return nil
+	}
+	if eutran {
+		amf[0] |= amfSeparationBit
+	} else {
+		amf[0] &^= amfSeparationBit
+	}
+
+	vectors := make([]*akaVector, 0, numVectors)
+	for _, sqn := range reservation.sqns {
+		vector, err := newAkaVector(reservation.alg, sqn, amf)
+		if err != nil {
+			problemDetails := &models.ProblemDetails{
+				Status: http.StatusForbidden,
+				Cause:  authenticationRejected,
+				Detail: err.Error(),
+			}
+			logger.UeauLog.Errorln("err:", err)
+			c.JSON(int(problemDetails.Status), problemDetails)
+			return nil
+		}
+		vectors = append(vectors, vector)
+	}
+	return vectors
+}
+
+func (p *Processor) GenerateAvProcedure(
+	c *gin.Context,
+	hssAuthInfoRequest models.HssAuthenticationInfoRequest,
+	supi string,
+	hssAuthType models.HssAuthTypeInUri,
+) {
+	logger.UeauLog.Traceln("In GenerateAvProcedure")
+
+	authType, ok := hssAuthTypes[hssAuthType]
+	if !ok {
+		problemDetails := &models.ProblemDetails{
+			Status: http.StatusNotImplemented,
+			Cause:  "UNSUPPORTED_RESOURCE_URI",
+			Detail: fmt.Sprintf("hssAuthType [%s] is not supported", hssAuthType),
+		}
+		logger.UeauLog.Errorln(problemDetails.Detail)
+		c.JSON(int(problemDetails.Status), problemDetails)
+		return
+	}
+	if hssAuthInfoRequest.HssAuthType != authType.authType {
+		problemDetails := &models.ProblemDetails{
+			Status: http.StatusBadRequest,
+			Cause:  "MANDATORY_IE_INCORRECT",
+			Detail: fmt.Sprintf("hssAuthType [%s] does not match the URI [%s]",
+				hssAuthInfoRequest.HssAuthType, hssAuthType),
+		}
+		logger.UeauLog.Errorln(problemDetails.Detail)
+		c.JSON(int(problemDetails.Status), problemDetails)
+		return
+	}
+	numVectors := int(hssAuthInfoRequest.NumOfRequestedVectors)
+	if numVectors < minHssVectors || numVectors > maxHssVectors {
+		problemDetails := &models.ProblemDetails{
+			Status: http.StatusBadRequest,
+			Cause:  "MANDATORY_IE_INCORRECT",
+			Detail: fmt.Sprintf("numOfRequestedVectors %d is out of range [%d, %d]",
+				numVectors, minHssVectors, maxHssVectors),
+		}
+		logger.UeauLog.Errorln(problemDetails.Detail)
+		c.JSON(int(problemDetails.Status), problemDetails)
+		return
+	}
+	var snId []byte
+	if authType.avType == models.HssAvType_EPS_AKA {
+		var err error
+		snId, err = servingNetworkIdBytes(hssAuthInfoRequest.ServingNetworkId)
+		if err != nil {
+			problemDetails := &models.ProblemDetails{
+				Status: http.StatusBadRequest,
+				Cause:  "MANDATORY_IE_INCORRECT",
+				Detail: err.Error(),
+			}
+			logger.UeauLog.Errorln(problemDetails.Detail)
+			c.JSON(int(problemDetails.Status), problemDetails)
+			return
+		}
+	}
+
+	// lastIndexes records the IND of the last vector of each requesting node
+	indKey := hssIndKey
+	if hssAuthInfoRequest.RequestingNodeType != "" {
+		indKey = string(hssAuthInfoRequest.RequestingNodeType)
+	}
+	vectors := p.generateAkaVectors(c, supi, indKey, hssAuthInfoRequest.ResynchronizationInfo, numVectors,
+		authType.avType == models.HssAvType_EPS_AKA)
+	if vectors == nil {
+		return
+	}
+
+	var epsAvs []models.AvEpsAka
+	var imsGbaEapAvs []models.AvImsGbaEapAka
+	for _, vector := range vectors {
+		if authType.avType != models.HssAvType_EPS_AKA {
+			imsGbaEapAvs = append(imsGbaEapAvs, models.AvImsGbaEapAka{
+				AvType: authType.avType,
+				Rand:   hex.EncodeToString(vector.rand),
+				Xres:   hex.EncodeToString(vector.xres),
+				Autn:   hex.EncodeToString(vector.autn),
+				Ck:     hex.EncodeToString(vector.ck),
+				Ik:     hex.EncodeToString(vector.ik),
+			})
+			continue
+		}
+
+		kdfValForKasme, err := kasme(vector, snId)
+		if err != nil {
+			logger.UeauLog.Errorf("Get kdfValForKasme err: %+v", err)
+			problemDetails := openapi.ProblemDetailsSystemFailure(err.Error())
+			c.JSON(int(problemDetails.Status), problemDetails)
+			return
+		}
+		logger.UeauLog.Tracef("Kasme=[%x]", kdfValForKasme)
+		epsAvs = append(epsAvs, models.AvEpsAka{
+			AvType: authType.avType,
+			Rand:   hex.EncodeToString(vector.rand),
+			Xres:   hex.EncodeToString(vector.xres),
+			Autn:   hex.EncodeToString(vector.autn),
+			Kasme:  hex.EncodeToString(kdfValForKasme),
+		})
+	}
+
+	response := &hssAuthenticationInfoResult{HssAuthenticationVectors: imsGbaEapAvs}
+	if authType.avType == models.HssAvType_EPS_AKA {
+		response.HssAuthenticationVectors = epsAvs
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func (p *Processor) GenerateGbaAvProcedure(
+	c *gin.Context,
+	gbaAuthInfoRequest models.GbaAuthenticationInfoRequest,
+	supi string,
+) {
+	logger.UeauLog.Traceln("In GenerateGbaAvProcedure")
+
+	if gbaAuthInfoRequest.AuthType != models.GbaAuthType_DIGEST_AKAV1_MD5 {
+		problemDetails := &models.ProblemDetails{
+			Status: http.StatusBadRequest,
+			Cause:  "MANDATORY_IE_INCORRECT",
+			Detail: fmt.Sprintf("authType [%s] is not supported", gbaAuthInfoRequest.AuthType),
+		}
+		logger.UeauLog.Errorln(problemDetails.Detail)
+		c.JSON(int(problemDetails.Status), problemDetails)
+		return
+	}
+
+	vectors := p.generateAkaVectors(c, supi, bsfIndKey, gbaAuthInfoRequest.ResynchronizationInfo, 1, false)
+	if vectors == nil {
+		return
+	}
+	vector := vectors[0]
+
+	response := &models.GbaAuthenticationInfoResult{
+		Var3gAkaAv: &models.Model3GAkaAv{
+			Rand: hex.EncodeToString(vector.rand),
+			Xres: hex.EncodeToString(vector.xres),
+			Autn: hex.EncodeToString(vector.autn),
+			Ck:   hex.EncodeToString(vector.ck),
+			Ik:   hex.EncodeToString(vector.ik),
+		},
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,294 @@
+package processor
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+
+	"github.com/free5gc/openapi/models"
+	"github.com/free5gc/util/milenage"
+)
+
+// sqnSeq returns the SEQ of the hex SQN with the default IND length
+func sqnSeq(t *testing.T, sqn string) uint64 {
+	t.Helper()
+	s, err := newSqnState(&models.SequenceNumber{Sqn: sqn}, nil)
+	require.NoError(t, err)
+	return s.seq
+}
+
+func TestServingNetworkIdBytes(t *testing.T) {
+	snId, err := servingNetworkIdBytes(&models.PlmnId{Mcc: "208", Mnc: "93"})
+	require.NoError(t, err)
+	require.Equal(t, "02f839", hex.EncodeToString(snId))
+
+	snId, err = servingNetworkIdBytes(&models.PlmnId{Mcc: "310", Mnc: "410"})
+	require.NoError(t, err)
+	require.Equal(t, "130014", hex.EncodeToString(snId))
+
+	_, err = servingNetworkIdBytes(&models.PlmnId{Mcc: "20", Mnc: "93"})
+	require.Error(t, err)
+	_, err = servingNetworkIdBytes(nil)
+	require.Error(t, err)
+}
+
+func TestGenerateAvProcedure(t *testing.T) {
+	// TS 35.208 Test Set 1
+	opc := mustDecodeHex(t, "cd63cb71954a9f4e48a5994e37a02baf")
+	k := mustDecodeHex(t, "465b5ce8b199b49faa5f0a2ee238a6bc")
+	supi := "imsi-208930000000011"
+	servingNetworkId := &models.PlmnId{Mcc: "208", Mnc: "93"}
+
+	testCases := []struct {
+		name         string
+		hssAuthType  models.HssAuthTypeInUri
+		request      models.HssAuthenticationInfoRequest
+		expectStatus int
+		expectAmf    string
+	}{
+		{
+			name:        "EPS AKA",
+			hssAuthType: models.HssAuthTypeInUri_EPS_AKA,
+			request: models.HssAuthenticationInfoRequest{
+				HssAuthType:           models.HssAuthType_EPS_AKA,
+				NumOfRequestedVectors: 3,
+				RequestingNodeType:    models.NodeType_MME,
+				ServingNetworkId:      servingNetworkId,
+			},
+			expectStatus: http.StatusOK,
+			expectAmf:    "8000",
+		},
+		{
+			name:        "IMS AKA",
+			hssAuthType: models.HssAuthTypeInUri_IMS_AKA,
+			request: models.HssAuthenticationInfoRequest{
+				HssAuthType:           models.HssAuthType_IMS_AKA,
+				NumOfRequestedVectors: 5,
+				RequestingNodeType:    models.NodeType_S_CSCF,
+			},
+			expectStatus: http.StatusOK,
+			expectAmf:    "0000",
+		},
+		{
+			name:        "EPS AKA without serving network",
+			hssAuthType: models.HssAuthTypeInUri_EPS_AKA,
+			request: models.HssAuthenticationInfoRequest{
+				HssAuthType:           models.HssAuthType_EPS_AKA,
+				NumOfRequestedVectors: 1,
+			},
+			expectStatus: http.StatusBadRequest,
+		},
+		{
+			name:        "too many vectors",
+			hssAuthType: models.HssAuthTypeInUri_IMS_AKA,
+			request: models.HssAuthenticationInfoRequest{
+				HssAuthType:           models.HssAuthType_IMS_AKA,
+				NumOfRequestedVectors: 6,
+			},
+			expectStatus: http.StatusBadRequest,
+		},
+		{
+			name:        "auth type mismatch",
+			hssAuthType: models.HssAuthTypeInUri_IMS_AKA,
+			request: models.HssAuthenticationInfoRequest{
+				HssAuthType:           models.HssAuthType_EPS_AKA,
+				NumOfRequestedVectors: 1,
+				ServingNetworkId:      servingNetworkId,
+			},
+			expectStatus: http.StatusBadRequest,
+		},
+		{
+			name:        "EAP-AKA'",
+			hssAuthType: models.HssAuthTypeInUri_EAP_AKA_PRIME,
+			request: models.HssAuthenticationInfoRequest{
+				HssAuthType:           models.HssAuthType_EAP_AKA_PRIME,
+				NumOfRequestedVectors: 1,
+			},
+			expectStatus: http.StatusNotImplemented,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			udr := &stubUdr{
+				authSubs: models.AuthenticationSubscription{
+					AuthenticationMethod:          models.AuthMethod__5_G_AKA,
+					EncPermanentKey:               hex.EncodeToString(k),
+					EncOpcKey:                     hex.EncodeToString(opc),
+					SequenceNumber:                &models.SequenceNumber{Sqn: "000000000020"},
+					AuthenticationManagementField: "8000",
+				},
+			}
+			server := httptest.NewServer(h2c.NewHandler(udr, &http2.Server{}))
+			defer server.Close()
+			testProcessor := newStubUdrProcessor(t, supi, server.URL)
+
+			rec := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(rec)
+			testProcessor.GenerateAvProcedure(c, tc.request, supi, tc.hssAuthType)
+			require.Equal(t, tc.expectStatus, rec.Code, rec.Body.String())
+			if tc.expectStatus != http.StatusOK {
+				require.Equal(t, "000000000020", udr.authSubs.SequenceNumber.Sqn)
+				return
+			}
+
+			var res struct {
+				HssAuthenticationVectors []struct {
+					models.AvImsGbaEapAka
+					Kasme string `json:"kasme"`
+				} `json:"hssAuthenticationVectors"`
+			}
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+			require.Len(t, res.HssAuthenticationVectors, int(tc.request.NumOfRequestedVectors))
+
+			// One SQN is reserved per vector, cycling through the IND slots of the requesting node
+			sqns := make(map[string]bool)
+			for i, av := range res.HssAuthenticationVectors {
+				sqn := sqnFromAutn(t, opc, k, av.Rand, av.Autn)
+				require.Equal(t, uint64(2+i), sqnSeq(t, sqn))
+				require.False(t, sqns[sqn])
+				sqns[sqn] = true
+				require.Equal(t, tc.expectAmf, av.Autn[12:16])
+
+				ck, ik := make([]byte, 16), make([]byte, 16)
+				xres := make([]byte, 8)
+				require.NoError(t, milenage.F2345(opc, k, mustDecodeHex(t, av.Rand), xres, ck, ik, nil, nil))
+				require.Equal(t, hex.EncodeToString(xres), av.Xres)
+
+				if tc.request.HssAuthType == models.HssAuthType_EPS_AKA {
+					require.Equal(t, models.HssAvType_EPS_AKA, av.AvType)
+					require.Empty(t, av.Ck)
+					// KDF of TS 33.220 B.2 with FC 0x10, SN id and SQN xor AK (TS 33.401 A.2)
+					s := append([]byte{0x10}, 0x02, 0xf8, 0x39, 0x00, 0x03)
+					s = append(append(s, mustDecodeHex(t, av.Autn)[:sqnLen]...), 0x00, 0x06)
+					mac := hmac.New(sha256.New, append(ck, ik...))
+					mac.Write(s)
+					require.Equal(t, hex.EncodeToString(mac.Sum(nil)), av.Kasme)
+				} else {
+					require.Equal(t, models.HssAvType_IMS_AKA, av.AvType)
+					require.Equal(t, hex.EncodeToString(ck), av.Ck)
+					require.Equal(t, hex.EncodeToString(ik), av.Ik)
+					require.Empty(t, av.Kasme)
+				}
+			}
+
+			sequenceNumber := udr.authSubs.SequenceNumber
+			require.Equal(t, map[string]int32{
+				string(tc.request.RequestingNodeType): tc.request.NumOfRequestedVectors,
+			}, sequenceNumber.LastIndexes)
+			require.Equal(t, uint64(1)+uint64(tc.request.NumOfRequestedVectors), sqnSeq(t, sequenceNumber.Sqn))
+		})
+	}
+}
+
+// auts computes the AUTS of a USIM whose SQN_MS is sqnMS, with Milenage (TS 33.102 6.3.3)
+func auts(t *testing.T, opc, k, rand []byte, sqnMS string) string {
+	t.Helper()
+	akStar := make([]byte, sqnLen)
+	require.NoError(t, milenage.F2345(opc, k, rand, nil, nil, nil, nil, akStar))
+	macS := make([]byte, 8)
+	require.NoError(t, milenage.F1(opc, k, rand, mustDecodeHex(t, sqnMS), mustDecodeHex(t, resyncAMF), nil, macS))
+	conc := mustDecodeHex(t, sqnMS)
+	for i := range conc {
+		conc[i] ^= akStar[i]
+	}
+	return hex.EncodeToString(append(conc, macS...))
+}
+
+func TestGenerateGbaAvProcedure(t *testing.T) {
+	// TS 35.208 Test Set 1
+	opc := mustDecodeHex(t, "cd63cb71954a9f4e48a5994e37a02baf")
+	k := mustDecodeHex(t, "465b5ce8b199b49faa5f0a2ee238a6bc")
+	supi := "imsi-208930000000012"
+	resyncRand := mustDecodeHex(t, "23553cbe9637a89d218ae64dae47bf35")
+
+	testCases := []struct {
+		name         string
+		request      models.GbaAuthenticationInfoRequest
+		expectStatus int
+		expectSeq    uint64
+	}{
+		{
+			name:         "GBA AKA",
+			request:      models.GbaAuthenticationInfoRequest{AuthType: models.GbaAuthType_DIGEST_AKAV1_MD5},
+			expectStatus: http.StatusOK,
+			expectSeq:    2,
+		},
+		{
+			name: "resynchronization",
+			request: models.GbaAuthenticationInfoRequest{
+				AuthType: models.GbaAuthType_DIGEST_AKAV1_MD5,
+				ResynchronizationInfo: &models.ResynchronizationInfo{
+					Rand: hex.EncodeToString(resyncRand),
+					Auts: auts(t, opc, k, resyncRand, "000000002003"),
+				},
+			},
+			expectStatus: http.StatusOK,
+			expectSeq:    0x101,
+		},
+		{
+			name: "resynchronization MAC failure",
+			request: models.GbaAuthenticationInfoRequest{
+				AuthType: models.GbaAuthType_DIGEST_AKAV1_MD5,
+				ResynchronizationInfo: &models.ResynchronizationInfo{
+					Rand: hex.EncodeToString(resyncRand),
+					Auts: "0000000020030000000000000000",
+				},
+			},
+			expectStatus: http.StatusForbidden,
+		},
+		{
+			name:         "unsupported auth type",
+			request:      models.GbaAuthenticationInfoRequest{AuthType: "DIGEST_MD5"},
+			expectStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			udr := &stubUdr{
+				authSubs: models.AuthenticationSubscription{
+					AuthenticationMethod:          models.AuthMethod__5_G_AKA,
+					EncPermanentKey:               hex.EncodeToString(k),
+					EncOpcKey:                     hex.EncodeToString(opc),
+					SequenceNumber:                &models.SequenceNumber{Sqn: "000000000020"},
+					AuthenticationManagementField: "8000",
+				},
+			}
+			server := httptest.NewServer(h2c.NewHandler(udr, &http2.Server{}))
+			defer server.Close()
+			testProcessor := newStubUdrProcessor(t, supi, server.URL)
+
+			rec := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(rec)
+			testProcessor.GenerateGbaAvProcedure(c, tc.request, supi)
+			require.Equal(t, tc.expectStatus, rec.Code, rec.Body.String())
+			if tc.expectStatus != http.StatusOK {
+				require.Equal(t, "000000000020", udr.authSubs.SequenceNumber.Sqn)
+				return
+			}
+
+			var res models.GbaAuthenticationInfoResult
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+			av := res.Var3gAkaAv
+			require.NotNil(t, av)
+			require.Equal(t, tc.expectSeq, sqnSeq(t, sqnFromAutn(t, opc, k, av.Rand, av.Autn)))
+			// The separation bit is cleared outside E-UTRAN
+			require.Equal(t, "0000", av.Autn[12:16])
+
+			xres, ck, ik := make([]byte, 8), make([]byte, 16), make([]byte, 16)
+			require.NoError(t, milenage.F2345(opc, k, mustDecodeHex(t, av.Rand), xres, ck, ik, nil, nil))
+			require.Equal(t, hex.EncodeToString(xres), av.Xres)
+			require.Equal(t, hex.EncodeToString(ck), av.Ck)
+			require.Equal(t, hex.EncodeToString(ik), av.Ik)
+			require.Equal(t, map[string]int32{bsfIndKey: 1}, udr.authSubs.SequenceNumber.LastIndexes)
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package processor
+
+import (
+	"encoding/hex"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/free5gc/openapi"
+	"github.com/free5gc/openapi/models"
+	"github.com/free5gc/udm/internal/logger"
+	"github.com/free5gc/udm/pkg/suci"
+	"github.com/free5gc/util/ueauth"
+)
+
+// lastIndexes key of the vectors of ProSe authentications
+const proseIndKey = "prose"
+
+// proSeAuthenticationInfoResult is the ProSeAuthenticationInfoResult of TS 29.503, whose
+// ProSeAuthenticationVectors array is left empty by the generated models
+type proSeAuthenticationInfoResult struct {
+	AuthType                   models.UdmUeauAuthType `json:"authType"`
+	ProseAuthenticationVectors []models.AvEapAkaPrime `json:"proseAuthenticationVectors,omitempty"`
+	Supi                       string                 `json:"supi,omitempty"`
+	SupportedFeatures          string                 `json:"supportedFeatures,omitempty"`
+}
+
+// ckPrimeIkPrime derives CK' and IK' from CK, IK, the serving network name and SQN xor AK
+// (TS 33.402 A.2, TS 33.501 A.3)
+func ckPrimeIkPrime(v *akaVector, servingNetworkName string) ([]byte, []byte, error) {
+	key := append(append([]byte{}, v.ck...), v.ik...)
+	P0 := []byte(servingNetworkName)
+	kdfVal, err := ueauth.GetKDFValue(key, ueauth.FC_FOR_CK_PRIME_IK_PRIME_DERIVATION,
+		P0, ueauth.KDFLen(P0), v.sqnXorAk, ueauth.KDFLen(v.sqnXorAk))
+	if err != nil {
+		return nil, nil, err
+	}
+	return kdfVal[:len(kdfVal)/2], kdfVal[len(kdfVal)/2:], nil
+}
+
+// GenerateProseAvProcedure generates the EAP-AKA' vector of a remote UE authenticated through a
+// UE-to-Network relay (TS 33.503 6.3.3.2). The AUSF derives KAUSF_P and the CP-PRUK from CK' and
+// IK', which are bound to the serving network name. The Relay Service Code of the request only
+// enters the derivation of KNR_ProSe from the CP-PRUK (TS 33.503 6.3.3.2.2), done by
+// the AUSF and the remote UE, so the UDM does not use it.
+func (p *Processor) GenerateProseAvProcedure(
+	c *gin.Context,
+	proseAuthInfoRequest models.ProSeAuthenticationInfoRequest,
+	supiOrSuci string,
+) {
+	logger.UeauLog.Traceln("In GenerateProseAvProcedure")
+
+	if proseAuthInfoRequest.ServingNetworkName == "" {
+		problemDetails := &models.ProblemDetails{
+			Status: http.StatusBadRequest,
+			Cause:  "MANDATORY_IE_MISSING",
+			Detail: "servingNetworkName is missing",
+		}
+		logger.UeauLog.Errorln(problemDetails.Detail)
+		c.JSON(int(problemDetails.Status), problemDetails)
+		return
+	}
+
+	if problemDetails := p.checkSuciServed(supiOrSuci); problemDetails != nil {
+		c.JSON(int(problemDetails.Status), problemDetails)
+		return
+	}
+	supi, err := suci.ToSupi(supiOrSuci, p.Context().GetSuciProfiles())
+	if err != nil {
+		problemDetails := &models.ProblemDetails{
+			Status: http.StatusForbidden,
+			Cause:  authenticationRejected,
+			Detail: err.Error(),
+		}
+
+		logger.UeauLog.Errorln("suciToSupi error: ", err.Error())
+		c.JSON(int(problemDetails.Status), problemDetails)
+		return
+	}
+	logger.UeauLog.Tracef("supi conversion => [%s], relayServiceCode [%d]", supi,
+		proseAuthInfoRequest.RelayServiceCode)
+
+	vectors := p.generateAkaVectors(c, supi, proseIndKey, proseAuthInfoRequest.ResynchronizationInfo, 1, false)
+	if vectors == nil {
+		return
+	}
+	vector := vectors[0]
+
+	ckPrime, ikPrime, err := ckPrimeIkPrime(vector, proseAuthInfoRequest.ServingNetworkName)
+	if err != nil {
+		logger.UeauLog.Errorf("Get kdfVal err: %+v", err)
+		problemDetails := openapi.ProblemDetailsSystemFailure(err.Error())
+		c.JSON(int(problemDetails.Status), problemDetails)
+		return
+	}
+	logger.UeauLog.Tracef("ckPrime=[%x], ikPrime=[%x]", ckPrime, ikPrime)
+
+	response := &proSeAuthenticationInfoResult{
+		AuthType: models.UdmUeauAuthType_EAP_AKA_PRIME,
+		ProseAuthenticationVectors: []models.AvEapAkaPrime{{
+			AvType:  models.AvType_EAP_AKA_PRIME,
+			Rand:    hex.EncodeToString(vector.rand),
+			Xres:    hex.EncodeToString(vector.xres),
+			Autn:    hex.EncodeToString(vector.autn),
+			CkPrime: hex.EncodeToString(ckPrime),
+			IkPrime: hex.EncodeToString(ikPrime),
+		}},
+		Supi: supi,
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,118 @@
+package processor
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+
+	"github.com/free5gc/openapi/models"
+	"github.com/free5gc/util/milenage"
+)
+
+func TestGenerateProseAvProcedure(t *testing.T) {
+	// TS 35.208 Test Set 1
+	opc := mustDecodeHex(t, "cd63cb71954a9f4e48a5994e37a02baf")
+	k := mustDecodeHex(t, "465b5ce8b199b49faa5f0a2ee238a6bc")
+	supi := "imsi-208930000000013"
+	servingNetworkName := "5G:mnc093.mcc208.3gppnetwork.org"
+
+	testCases := []struct {
+		name         string
+		supiOrSuci   string
+		request      models.ProSeAuthenticationInfoRequest
+		expectStatus int
+	}{
+		{
+			name:       "null scheme SUCI",
+			supiOrSuci: "suci-0-208-93-0-0-0-0000000013",
+			request: models.ProSeAuthenticationInfoRequest{
+				ServingNetworkName: servingNetworkName,
+				RelayServiceCode:   0x123456,
+			},
+			expectStatus: http.StatusOK,
+		},
+		{
+			name:       "SUPI",
+			supiOrSuci: supi,
+			request: models.ProSeAuthenticationInfoRequest{
+				ServingNetworkName: servingNetworkName,
+				RelayServiceCode:   1,
+			},
+			expectStatus: http.StatusOK,
+		},
+		{
+			name:         "missing serving network name",
+			supiOrSuci:   supi,
+			request:      models.ProSeAuthenticationInfoRequest{RelayServiceCode: 1},
+			expectStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid SUCI",
+			supiOrSuci: "suci-0-208-93-0-1-1-00",
+			request: models.ProSeAuthenticationInfoRequest{
+				ServingNetworkName: servingNetworkName,
+				RelayServiceCode:   1,
+			},
+			expectStatus: http.StatusForbidden,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			udr := &stubUdr{
+				authSubs: models.AuthenticationSubscription{
+					AuthenticationMethod:          models.AuthMethod_EAP_AKA_PRIME,
+					EncPermanentKey:               hex.EncodeToString(k),
+					EncOpcKey:                     hex.EncodeToString(opc),
+					SequenceNumber:                &models.SequenceNumber{Sqn: "000000000020"},
+					AuthenticationManagementField: "8000",
+				},
+			}
+			server := httptest.NewServer(h2c.NewHandler(udr, &http2.Server{}))
+			defer server.Close()
+			testProcessor := newStubUdrProcessor(t, supi, server.URL)
+
+			rec := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(rec)
+			testProcessor.GenerateProseAvProcedure(c, tc.request, tc.supiOrSuci)
+			require.Equal(t, tc.expectStatus, rec.Code, rec.Body.String())
+			if tc.expectStatus != http.StatusOK {
+				require.Equal(t, "000000000020", udr.authSubs.SequenceNumber.Sqn)
+				return
+			}
+
+			var res proSeAuthenticationInfoResult
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+			require.Equal(t, models.UdmUeauAuthType_EAP_AKA_PRIME, res.AuthType)
+			require.Equal(t, supi, res.Supi)
+			require.Len(t, res.ProseAuthenticationVectors, 1)
+			av := res.ProseAuthenticationVectors[0]
+			require.Equal(t, models.AvType_EAP_AKA_PRIME, av.AvType)
+			require.Equal(t, uint64(2), sqnSeq(t, sqnFromAutn(t, opc, k, av.Rand, av.Autn)))
+
+			xres, ck, ik := make([]byte, 8), make([]byte, 16), make([]byte, 16)
+			require.NoError(t, milenage.F2345(opc, k, mustDecodeHex(t, av.Rand), xres, ck, ik, nil, nil))
+			require.Equal(t, hex.EncodeToString(xres), av.Xres)
+
+			// KDF of TS 33.220 B.2 with FC 0x20, serving network name and SQN xor AK (TS 33.501 A.3)
+			s := append([]byte{0x20}, servingNetworkName...)
+			s = binary.BigEndian.AppendUint16(s, uint16(len(servingNetworkName)))
+			s = append(append(s, mustDecodeHex(t, av.Autn)[:sqnLen]...), 0x00, 0x06)
+			mac := hmac.New(sha256.New, append(ck, ik...))
+			mac.Write(s)
+			kdfVal := mac.Sum(nil)
+			require.Equal(t, hex.EncodeToString(kdfVal[:16]), av.CkPrime)
+			require.Equal(t, hex.EncodeToString(kdfVal[16:]), av.IkPrime)
+			require.Equal(t, map[string]int32{proseIndKey: 1}, udr.authSubs.SequenceNumber.LastIndexes)
+		})
+	}
+}
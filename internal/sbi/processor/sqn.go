@@ -0,0 +1,348 @@
+package processor
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"reflect"
+
+	"github.com/free5gc/openapi"
+	"github.com/free5gc/openapi/models"
+	Nudr_DataRepository "github.com/free5gc/openapi/udr/DataRepository"
+	"github.com/free5gc/udm/internal/logger"
+	"github.com/free5gc/udm/internal/util"
+	"github.com/free5gc/udm/pkg/factory"
+)
+
+const (
+	sqnLen  = 6 // octets
+	sqnBits = 8 * sqnLen
+	// lastIndexes key of the vectors requested without an AUSF instance ID
+	defaultIndKey = "ausf"
+)
+
+// sqnState is the sequence number state of one subscriber in the HE (TS 33.102 Annex C.1 and C.3):
+// SQN = SEQ || IND, SEQ_HE is a counter incremented for every vector and IND cycles through the
+// slots of the USIM array, so that the last 2^indLength vectors, consumed out of order by parallel
+// AUSFs, fall in different slots. lastIndexes records the IND of the last vector of each AUSF.
+type sqnState struct {
+	seq         uint64 // SEQ_HE, SEQ of the last generated vector
+	ind         uint64 // IND of the last generated vector
+	indLength   int
+	delta       uint64
+	ageLimit    uint64
+	lastIndexes map[string]int32
+	scheme      models.SqnScheme
+	difSign     models.Sign
+}
+
+func newSqnState(sequenceNumber *models.SequenceNumber, cfg *factory.Sqn) (*sqnState, error) {
+	s := &sqnState{
+		indLength:   cfg.GetIndLength(),
+		delta:       cfg.GetDelta(),
+		ageLimit:    cfg.GetAgeLimit(),
+		lastIndexes: make(map[string]int32),
+		scheme:      models.SqnScheme_NON_TIME_BASED,
+	}
+	if sequenceNumber == nil {
+		return s, nil
+	}
+	if sequenceNumber.IndLength > 0 {
+		if sequenceNumber.IndLength > factory.SqnMaxIndLength {
+			return nil, fmt.Errorf("indLength %d is out of range", sequenceNumber.IndLength)
+		}
+		s.indLength = int(sequenceNumber.IndLength)
+	}
+	if sequenceNumber.SqnScheme != "" {
+		s.scheme = sequenceNumber.SqnScheme
+	}
+	s.difSign = sequenceNumber.DifSign
+	for key, ind := range sequenceNumber.LastIndexes {
+		s.lastIndexes[key] = ind
+	}
+
+	sqn, err := hex.DecodeString(strictHex(sequenceNumber.Sqn, 2*sqnLen))
+	if err != nil {
+		return nil, fmt.Errorf("sqn: %w", err)
+	}
+	s.seq, s.ind = s.split(sqn)
+	return s, nil
+}
+
+func (s *sqnState) indSlots() uint64 {
+	return 1 << s.indLength
+}
+
+func (s *sqnState) seqMask() uint64 {
+	return 1<<(sqnBits-s.indLength) - 1
+}
+
+func (s *sqnState) split(sqn []byte) (seq, ind uint64) {
+	v := binary.BigEndian.Uint64(append(make([]byte, 8-sqnLen), sqn...))
+	return v >> s.indLength, v & (s.indSlots() - 1)
+}
+
+func (s *sqnState) sqn() []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, s.seq<<s.indLength|s.ind)
+	return b[8-sqnLen:]
+}
+
+// next allocates the SQN of a new vector for the AUSF ausfInstanceId (C.1.1.1 and C.1.2). The
+// AUSFs whose last vector took the same slot are the least recently served ones and are evicted,
+// which bounds lastIndexes to 2^indLength entries.
+func (s *sqnState) next(ausfInstanceId string) []byte {
+	key := ausfInstanceId
+	if key == "" {
+		key = defaultIndKey
+	}
+	s.ind = (s.ind + 1) % s.indSlots()
+	s.seq = (s.seq + 1) & s.seqMask()
+	for k, ind := range s.lastIndexes {
+		// Out of range IND values were allocated with a longer indLength
+		if uint64(ind) == s.ind || uint64(ind) >= s.indSlots() {
+			delete(s.lastIndexes, k)
+		}
+	}
+	s.lastIndexes[key] = int32(s.ind)
+	return s.sqn()
+}
+
+// freshForUsim reports whether the USIM whose highest accepted SEQ is seqMS accepts seq in any IND
+// slot: SEQ > SEQ_MS(i) holds for every slot when SEQ > SEQ_MS (C.2.1), and the jump stays within
+// Δ (C.2.2)
+func (s *sqnState) freshForUsim(seq, seqMS uint64) bool {
+	return seq > seqMS && seq-seqMS <= s.delta
+}
+
+// withinAgeLimit reports whether the USIM checking the age limit L still accepts the vectors in
+// flight from seqMS up to seq once it accepted seq (C.2.3): it rejects a SEQ more than L below
+// SEQ_MS
+func (s *sqnState) withinAgeLimit(seq, seqMS uint64) bool {
+	return s.ageLimit == 0 || seq-seqMS <= s.ageLimit
+}
+
+// resync handles the synchronisation failure reporting SQN_MS (TS 33.102 6.3.5): SEQ_HE is only
+// reset to SEQ_MS when the next SEQ generated from SEQ_HE would not be accepted by the USIM, or
+// would make it reject as too old the vectors in flight since SEQ_MS, so failures caused by vectors
+// consumed out of order do not disturb the counter
+func (s *sqnState) resync(sqnMS []byte) {
+	seqMS, indMS := s.split(sqnMS)
+	seq := (s.seq + 1) & s.seqMask()
+	if s.freshForUsim(seq, seqMS) && s.withinAgeLimit(seq, seqMS) {
+		logger.UeauLog.Infof("Keep SEQ_HE [%d], SEQ_MS [%d] IND_MS [%d]", s.seq, seqMS, indMS)
+		return
+	}
+	logger.UeauLog.Infof("Reset SEQ_HE [%d] to SEQ_MS [%d], IND_MS [%d]", s.seq, seqMS, indMS)
+	s.seq = seqMS
+}
+
+func (s *sqnState) sequenceNumber() models.SequenceNumber {
+	return models.SequenceNumber{
+		SqnScheme:   s.scheme,
+		Sqn:         hex.EncodeToString(s.sqn()),
+		LastIndexes: s.lastIndexes,
+		IndLength:   int32(s.indLength),
+		DifSign:     s.difSign,
+	}
+}
+
+// number of attempts to update the SQN of a subscriber modified concurrently by another UDM
+const sqnUpdateAttempts = 3
+
+// sqnReservation is the authentication subscription of a subscriber with the SQNs reserved in
+// the UDR for its next vectors
+type sqnReservation struct {
+	authSubs *models.AuthenticationSubscription
+	alg      authAlgorithm
+	sqns     [][]byte
+}
+
+// reserveSqns reads the authentication subscription of supi, handles the resynchronisation info
+// if any and reserves numVectors SQNs in the UDR. The update is conditional on the subscription
+// which was read: on its ETag with If-Match when the UDR sends one, and on the SQN with a test
+// operation of the JSON patch (RFC 6902 4.6). The subscription is read again when the UDR rejects
+// the update because another UDM modified it meanwhile. Within this UDM the reservations of a SUPI
+// are serialised, so that concurrent requests do not conflict in the first place.
+// A returned error is an error of the UDR query.
+func (p *Processor) reserveSqns(ctx context.Context, client *Nudr_DataRepository.APIClient, supi string,
+	ausfInstanceId string, resyncInfo *models.ResynchronizationInfo, numVectors int,
+) (*sqnReservation, *models.ProblemDetails, error) {
+	unlock := p.Context().LockSqn(supi)
+	defer unlock()
+
+	var queryAuthSubsDataRequest Nudr_DataRepository.QueryAuthSubsDataRequest
+	queryAuthSubsDataRequest.UeId = &supi
+
+	for attempt := 1; ; attempt++ {
+		// the update is conditional on the ETag of the subscription read in this attempt
+		attemptCtx, _ := util.WithETag(ctx)
+		authSubs, err := client.AuthenticationDataDocumentApi.QueryAuthSubsData(attemptCtx,
+			&queryAuthSubsDataRequest)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		alg, err := newAuthAlgorithm(&authSubs.AuthenticationSubscription,
+			p.Context().TuakProfiles, p.Context().KeyEncryptionKeys, p.Context().KeyProvider)
+		if err != nil {
+			logger.UeauLog.Errorln("err:", err)
+			return nil, &models.ProblemDetails{
+				Status: http.StatusForbidden,
+				Cause:  authenticationRejected,
+				Detail: err.Error(),
+			}, nil
+		}
+
+		// without a SQN to test the update would overwrite the SQN of a concurrent UDM
+		sequenceNumber := authSubs.AuthenticationSubscription.SequenceNumber
+		if sequenceNumber == nil {
+			logger.UeauLog.Errorf("No sequenceNumber in the authentication subscription of [%s]", supi)
+			return nil, &models.ProblemDetails{
+				Status: http.StatusForbidden,
+				Cause:  authenticationRejected,
+				Detail: "sequenceNumber is missing",
+			}, nil
+		}
+
+		sqnState, err := newSqnState(sequenceNumber, p.Context().Sqn)
+		if err != nil {
+			logger.UeauLog.Errorln("err:", err)
+			return nil, &models.ProblemDetails{
+				Status: http.StatusForbidden,
+				Cause:  authenticationRejected,
+				Detail: err.Error(),
+			}, nil
+		}
+
+		if resyncInfo != nil {
+			if problemDetails := p.resyncSqn(alg, sqnState, resyncInfo, supi); problemDetails != nil {
+				return nil, problemDetails, nil
+			}
+		}
+
+		reservation := &sqnReservation{
+			authSubs: &authSubs.AuthenticationSubscription,
+			alg:      alg,
+		}
+		for i := 0; i < numVectors; i++ {
+			reservation.sqns = append(reservation.sqns, sqnState.next(ausfInstanceId))
+		}
+
+		patchItemArray := []models.PatchItem{
+			{
+				Op:    models.PatchOperation_TEST,
+				Path:  "/sequenceNumber/sqn",
+				Value: sequenceNumber.Sqn,
+			},
+			{
+				Op:    models.PatchOperation_REPLACE,
+				Path:  "/sequenceNumber",
+				Value: sqnState.sequenceNumber(),
+			},
+		}
+
+		logger.ProcLog.Infoln("ModifyAuthenticationSubscriptionRequest: ", patchItemArray)
+
+		var modifyAuthenticationSubscriptionRequest Nudr_DataRepository.ModifyAuthenticationSubscriptionRequest
+		modifyAuthenticationSubscriptionRequest.UeId = &supi
+		modifyAuthenticationSubscriptionRequest.PatchItem = patchItemArray
+		_, err = client.AuthenticationSubscriptionDocumentApi.ModifyAuthenticationSubscription(
+			attemptCtx, &modifyAuthenticationSubscriptionRequest)
+		if err == nil {
+			return reservation, nil, nil
+		}
+
+		if attempt < sqnUpdateAttempts && sqnModified(ctx, client, supi, sequenceNumber.Sqn, err) {
+			logger.UeauLog.Warnf("SQN of [%s] modified concurrently, attempt %d", supi, attempt)
+			continue
+		}
+
+		logger.UeauLog.Errorln("update sqn error:", err)
+		return nil, &models.ProblemDetails{
+			Status: http.StatusForbidden,
+			Cause:  "modification is rejected",
+			Detail: err.Error(),
+		}, nil
+	}
+}
+
+// sqnModified reports whether the update of the SQN sqn of supi failed with err because another UDM
+// modified the SQN. A UDR reports an ETag or a test operation which does not hold with 412 or 409,
+// the free5gc UDR rejects any patch which cannot be applied with 403, so the SQN is read again to
+// tell.
+func sqnModified(ctx context.Context, client *Nudr_DataRepository.APIClient, supi string, sqn string,
+	err error,
+) bool {
+	apiError, ok := err.(openapi.GenericOpenAPIError)
+	if !ok {
+		return false
+	}
+	switch apiError.ErrorStatus {
+	case http.StatusConflict, http.StatusPreconditionFailed:
+		return true
+	case http.StatusForbidden:
+		authSubs, err := client.AuthenticationDataDocumentApi.QueryAuthSubsData(ctx,
+			&Nudr_DataRepository.QueryAuthSubsDataRequest{UeId: &supi})
+		if err != nil {
+			return false
+		}
+		sequenceNumber := authSubs.AuthenticationSubscription.SequenceNumber
+		return sequenceNumber != nil && sequenceNumber.Sqn != sqn
+	default:
+		return false
+	}
+}
+
+// resyncSqn verifies AUTS and resynchronises sqnState with the SQN_MS it carries
+func (p *Processor) resyncSqn(alg authAlgorithm, sqnState *sqnState,
+	resyncInfo *models.ResynchronizationInfo, supi string,
+) *models.ProblemDetails {
+	logger.UeauLog.Infof("Authentication re-synchronization")
+
+	Auts, deCodeErr := hex.DecodeString(resyncInfo.Auts)
+	if deCodeErr != nil {
+		logger.UeauLog.Errorln("err:", deCodeErr)
+		return &models.ProblemDetails{
+			Status: http.StatusForbidden,
+			Cause:  authenticationRejected,
+			Detail: deCodeErr.Error(),
+		}
+	}
+
+	randHex, deCodeErr := hex.DecodeString(resyncInfo.Rand)
+	if deCodeErr != nil {
+		logger.UeauLog.Errorln("err:", deCodeErr)
+		return &models.ProblemDetails{
+			Status: http.StatusForbidden,
+			Cause:  authenticationRejected,
+			Detail: deCodeErr.Error(),
+		}
+	}
+
+	if len(Auts) != sqnLen+alg.macLen() {
+		logger.UeauLog.Errorln("AUTS length is ", len(Auts))
+		return &models.ProblemDetails{
+			Status: http.StatusForbidden,
+			Cause:  authenticationRejected,
+			Detail: fmt.Sprintf("len(AUTS) is %d, should be %d", len(Auts), sqnLen+alg.macLen()),
+		}
+	}
+
+	SQNms, macS := p.aucSQN(alg, Auts, randHex)
+	if !reflect.DeepEqual(macS, Auts[sqnLen:]) {
+		logger.UeauLog.Errorf("Re-Sync MAC failed for UE with identity resolvedSupi=[%s]", supi)
+		logger.UeauLog.Errorln("MACS ", macS)
+		logger.UeauLog.Errorln("Auts[6:] ", Auts[sqnLen:])
+		logger.UeauLog.Errorln("Sqn ", SQNms)
+		return &models.ProblemDetails{
+			Status: http.StatusForbidden,
+			Cause:  "modification is rejected",
+		}
+	}
+
+	sqnState.resync(SQNms)
+	return nil
+}
@@ -0,0 +1,504 @@
+package processor
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+
+	"github.com/free5gc/openapi/models"
+	udm_context "github.com/free5gc/udm/internal/context"
+	"github.com/free5gc/udm/internal/sbi/consumer"
+	"github.com/free5gc/udm/pkg/factory"
+	mockapp "github.com/free5gc/udm/pkg/mockapp"
+	"github.com/free5gc/util/milenage"
+)
+
+func TestSqnStateNext(t *testing.T) {
+	s, err := newSqnState(&models.SequenceNumber{Sqn: "000000000047"}, nil)
+	require.NoError(t, err)
+	// 0x47 = SEQ 2 || IND 7 with the default IND length of 5 bits
+	require.Equal(t, uint64(2), s.seq)
+	require.Equal(t, uint64(7), s.ind)
+
+	// IND cycles through the slots for every vector while SEQ_HE counts every vector
+	require.Equal(t, "000000000068", hex.EncodeToString(s.next("ausf-1")))
+	require.Equal(t, "000000000089", hex.EncodeToString(s.next("ausf-2")))
+	require.Equal(t, "0000000000aa", hex.EncodeToString(s.next("ausf-1")))
+	require.Equal(t, "0000000000cb", hex.EncodeToString(s.next("ausf-2")))
+	require.Equal(t, map[string]int32{"ausf-1": 10, "ausf-2": 11}, s.lastIndexes)
+
+	sequenceNumber := s.sequenceNumber()
+	require.Equal(t, "0000000000cb", sequenceNumber.Sqn)
+	require.Equal(t, int32(5), sequenceNumber.IndLength)
+	require.Equal(t, models.SqnScheme_NON_TIME_BASED, sequenceNumber.SqnScheme)
+
+	// The state stored in the UDR is restored on the next request
+	restored, err := newSqnState(&sequenceNumber, nil)
+	require.NoError(t, err)
+	require.Equal(t, "0000000000ec", hex.EncodeToString(restored.next("ausf-1")))
+}
+
+func TestSqnStateLastIndexesEviction(t *testing.T) {
+	s, err := newSqnState(&models.SequenceNumber{
+		Sqn:         "000000000000",
+		IndLength:   2,
+		LastIndexes: map[string]int32{"legacy": 9},
+	}, nil)
+	require.NoError(t, err)
+
+	// Once all the slots are taken, the least recently served AUSF is evicted
+	for _, ausf := range []string{"ausf-1", "ausf-2", "ausf-3", "ausf-4", "ausf-2", "ausf-5"} {
+		s.next(ausf)
+		require.LessOrEqual(t, len(s.lastIndexes), 4)
+	}
+	require.Equal(t, map[string]int32{"ausf-3": 3, "ausf-4": 0, "ausf-2": 1, "ausf-5": 2}, s.lastIndexes)
+}
+
+func TestSqnStateIndLength(t *testing.T) {
+	// indLength of the subscription takes precedence over the configuration
+	s, err := newSqnState(&models.SequenceNumber{Sqn: "000000000010", IndLength: 4},
+		&factory.Sqn{IndLength: 8})
+	require.NoError(t, err)
+	require.Equal(t, "000000000021", hex.EncodeToString(s.next("")))
+
+	s, err = newSqnState(&models.SequenceNumber{Sqn: "000000000100"}, &factory.Sqn{IndLength: 8})
+	require.NoError(t, err)
+	require.Equal(t, "000000000201", hex.EncodeToString(s.next("")))
+	// IND wraps around after 2^indLength slots
+	s.ind = 255
+	require.Equal(t, "000000000300", hex.EncodeToString(s.next("")))
+
+	// SEQ wraps around at 2^(48-indLength)
+	s, err = newSqnState(&models.SequenceNumber{Sqn: "ffffffffffe0"}, nil)
+	require.NoError(t, err)
+	require.Equal(t, "000000000001", hex.EncodeToString(s.next("")))
+
+	_, err = newSqnState(&models.SequenceNumber{Sqn: "000000000000", IndLength: 40}, nil)
+	require.Error(t, err)
+}
+
+func TestSqnStateResync(t *testing.T) {
+	testCases := []struct {
+		name      string
+		cfg       *factory.Sqn
+		sqnHE     string
+		sqnMS     string
+		expectSqn string
+	}{
+		{
+			// Out of order vectors: the next SEQ is fresh for the USIM, SEQ_HE is kept
+			name:      "USIM behind",
+			sqnHE:     "000000001000",
+			sqnMS:     "000000000e05",
+			expectSqn: "000000001021",
+		},
+		{
+			name:      "USIM ahead",
+			sqnHE:     "000000001000",
+			sqnMS:     "000000002005",
+			expectSqn: "000000002021",
+		},
+		{
+			name:      "USIM equal",
+			sqnHE:     "000000001000",
+			sqnMS:     "000000001003",
+			expectSqn: "000000001021",
+		},
+		{
+			// The next SEQ jumps more than Δ beyond SEQ_MS (C.2.2)
+			name:      "beyond delta",
+			sqnHE:     "100000000000",
+			sqnMS:     "000000002005",
+			expectSqn: "000000002021",
+		},
+		{
+			name:      "within age limit",
+			cfg:       &factory.Sqn{AgeLimit: 32},
+			sqnHE:     "000000001000",
+			sqnMS:     "000000000e05",
+			expectSqn: "000000001021",
+		},
+		{
+			// The next SEQ is 17 above SEQ_MS, the USIM would then reject the vectors in flight
+			// more than L below it (C.2.3)
+			name:      "beyond age limit",
+			cfg:       &factory.Sqn{AgeLimit: 8},
+			sqnHE:     "000000001000",
+			sqnMS:     "000000000e05",
+			expectSqn: "000000000e21",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := newSqnState(&models.SequenceNumber{Sqn: tc.sqnHE}, tc.cfg)
+			require.NoError(t, err)
+			sqnMS, err := hex.DecodeString(tc.sqnMS)
+			require.NoError(t, err)
+			s.resync(sqnMS)
+			require.Equal(t, tc.expectSqn, hex.EncodeToString(s.next("")))
+		})
+	}
+}
+
+// stubUdr serves the authentication subscription of one subscriber and applies PATCH requests
+// atomically, unless racyPatch is set. Like the free5gc UDR, it rejects a PATCH which cannot be
+// applied, a test operation which does not hold included, with 403. It also stores the
+// authentication status of the subscriber.
+type stubUdr struct {
+	mu       sync.Mutex
+	authSubs models.AuthenticationSubscription
+	// etags sends the version of the subscription as ETag and rejects a PATCH whose If-Match does
+	// not match the version with 412
+	etags   bool
+	version int
+	// racyPatch reads the subscription and writes it patched in two steps, letting other requests
+	// in between, as a UDR reading, patching and replacing the document does
+	racyPatch bool
+	// onPatch is called before a PATCH is applied, to emulate a concurrent writer
+	onPatch func(authSubs *models.AuthenticationSubscription)
+	// rejectPatch rejects every PATCH, to emulate a UDR failing to update the subscription
+	rejectPatch bool
+	patches     int
+	authEvent   *models.AuthEvent
+	// authStatusFailure fails the queries of the authentication status
+	authStatusFailure bool
+}
+
+func (u *stubUdr) serveAuthStatus(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		if u.authStatusFailure {
+			w.WriteHeader(http.StatusInternalServerError)
+			_ = json.NewEncoder(w).Encode(models.ProblemDetails{Status: http.StatusInternalServerError})
+			return
+		}
+		if u.authEvent == nil {
+			w.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(models.ProblemDetails{Status: http.StatusNotFound, Cause: "DATA_NOT_FOUND"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(u.authEvent)
+	case http.MethodPut:
+		u.authEvent = new(models.AuthEvent)
+		if err := json.NewDecoder(r.Body).Decode(u.authEvent); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (u *stubUdr) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if strings.HasSuffix(r.URL.Path, "/authentication-status") {
+		u.serveAuthStatus(w, r)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		if u.etags {
+			w.Header().Set("ETag", strconv.Quote(strconv.Itoa(u.version)))
+		}
+		_ = json.NewEncoder(w).Encode(u.authSubs)
+	case http.MethodPatch:
+		var patchItems []models.PatchItem
+		if err := json.NewDecoder(r.Body).Decode(&patchItems); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		u.patches++
+		if u.onPatch != nil {
+			u.onPatch(&u.authSubs)
+		}
+		sqn := u.authSubs.SequenceNumber.Sqn
+		if u.racyPatch {
+			u.mu.Unlock()
+			time.Sleep(time.Millisecond)
+			u.mu.Lock()
+		}
+		if ifMatch := r.Header.Get("If-Match"); u.etags && ifMatch != "" &&
+			ifMatch != strconv.Quote(strconv.Itoa(u.version)) {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			_ = json.NewEncoder(w).Encode(models.ProblemDetails{Status: http.StatusPreconditionFailed})
+			return
+		}
+		for _, item := range patchItems {
+			if u.rejectPatch || item.Op == models.PatchOperation_TEST && item.Value != sqn {
+				w.WriteHeader(http.StatusForbidden)
+				_ = json.NewEncoder(w).Encode(models.ProblemDetails{
+					Status: http.StatusForbidden,
+					Cause:  "MODIFY_NOT_ALLOWED",
+				})
+				return
+			}
+		}
+		for _, item := range patchItems {
+			if item.Op == models.PatchOperation_REPLACE {
+				b, _ := json.Marshal(item.Value)
+				var sequenceNumber models.SequenceNumber
+				_ = json.Unmarshal(b, &sequenceNumber)
+				u.authSubs.SequenceNumber = &sequenceNumber
+			}
+		}
+		u.version++
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// sqnFromAutn recovers the SQN of a vector generated with Milenage
+func sqnFromAutn(t *testing.T, opc, k []byte, rand, autn string) string {
+	t.Helper()
+	ak := make([]byte, sqnLen)
+	require.NoError(t, milenage.F2345(opc, k, mustDecodeHex(t, rand), nil, nil, nil, ak, nil))
+	sqn := mustDecodeHex(t, autn)[:sqnLen]
+	for i := range sqn {
+		sqn[i] ^= ak[i]
+	}
+	return hex.EncodeToString(sqn)
+}
+
+// newStubUdrProcessor returns a Processor reaching the UDR at udrUri for supi
+func newStubUdrProcessor(t *testing.T, supi, udrUri string) *Processor {
+	t.Helper()
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+	mockApp := mockapp.NewMockApp(ctrl)
+	testConsumer, err := consumer.NewConsumer(mockApp)
+	require.NoError(t, err)
+	testProcessor, err := NewProcessor(mockApp)
+	require.NoError(t, err)
+	ue := new(udm_context.UdmUeContext)
+	ue.Init()
+	ue.Supi = supi
+	ue.UdrUri = udrUri
+	udm_context.GetSelf().UdmUePool.Store(supi, ue)
+	mockApp.EXPECT().Consumer().Return(testConsumer).AnyTimes()
+	mockApp.EXPECT().Context().Return(&udm_context.UDMContext{
+		NrfUri: "http://127.0.0.10:8000",
+		NfId:   "1",
+	}).AnyTimes()
+	return testProcessor
+}
+
+func TestGenerateAuthDataProcedureConcurrent(t *testing.T) {
+	// TS 35.208 Test Set 1
+	opc := mustDecodeHex(t, "cd63cb71954a9f4e48a5994e37a02baf")
+	k := mustDecodeHex(t, "465b5ce8b199b49faa5f0a2ee238a6bc")
+	const numRequests = 20
+
+	testCases := []struct {
+		name string
+		// number of PATCH requests preceded by a write of another UDM instance
+		concurrentWrites int
+	}{
+		{name: "parallel requests"},
+		{name: "concurrent writer", concurrentWrites: 2},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			udr := &stubUdr{
+				authSubs: models.AuthenticationSubscription{
+					AuthenticationMethod:          models.AuthMethod__5_G_AKA,
+					EncPermanentKey:               hex.EncodeToString(k),
+					EncOpcKey:                     hex.EncodeToString(opc),
+					SequenceNumber:                &models.SequenceNumber{Sqn: "000000000020"},
+					AuthenticationManagementField: "8000",
+				},
+			}
+			var otherSqns []string
+			if tc.concurrentWrites > 0 {
+				writes := tc.concurrentWrites
+				other, err := newSqnState(udr.authSubs.SequenceNumber, nil)
+				require.NoError(t, err)
+				udr.onPatch = func(authSubs *models.AuthenticationSubscription) {
+					if writes == 0 {
+						return
+					}
+					writes--
+					otherSqns = append(otherSqns, hex.EncodeToString(other.next("other-ausf")))
+					sequenceNumber := other.sequenceNumber()
+					authSubs.SequenceNumber = &sequenceNumber
+				}
+			}
+			server := httptest.NewServer(h2c.NewHandler(udr, &http2.Server{}))
+			defer server.Close()
+
+			supi := "imsi-208930000000010"
+			testProcessor := newStubUdrProcessor(t, supi, server.URL)
+
+			results := make([]*httptest.ResponseRecorder, numRequests)
+			var wg sync.WaitGroup
+			for i := 0; i < numRequests; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					results[i] = httptest.NewRecorder()
+					c, _ := gin.CreateTestContext(results[i])
+					testProcessor.GenerateAuthDataProcedure(c, models.AuthenticationInfoRequest{
+						ServingNetworkName: "5G:mnc093.mcc208.3gppnetwork.org",
+						AusfInstanceId:     fmt.Sprintf("ausf-%d", i%3),
+					}, supi)
+				}(i)
+			}
+			wg.Wait()
+
+			sqns := make(map[string]bool)
+			for _, sqn := range otherSqns {
+				sqns[sqn] = true
+			}
+			for _, rec := range results {
+				require.Equal(t, http.StatusOK, rec.Code)
+				var res models.UdmUeauAuthenticationInfoResult
+				require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+				sqn := sqnFromAutn(t, opc, k, res.AuthenticationVector.Rand, res.AuthenticationVector.Autn)
+				require.False(t, sqns[sqn], "SQN %s handed out twice", sqn)
+				sqns[sqn] = true
+			}
+			require.Len(t, sqns, numRequests+tc.concurrentWrites)
+
+			// SEQ_HE counted every vector
+			final, err := newSqnState(udr.authSubs.SequenceNumber, nil)
+			require.NoError(t, err)
+			require.Equal(t, uint64(1+numRequests+tc.concurrentWrites), final.seq)
+		})
+	}
+}
+
+func TestGenerateAuthDataProcedureTwoInstances(t *testing.T) {
+	// TS 35.208 Test Set 1
+	opc := mustDecodeHex(t, "cd63cb71954a9f4e48a5994e37a02baf")
+	k := mustDecodeHex(t, "465b5ce8b199b49faa5f0a2ee238a6bc")
+	const numRequests = 10
+
+	// The UDR applies the test operation of the patch non-atomically, the UDM instances rely on the
+	// ETag of the subscription
+	udr := &stubUdr{
+		authSubs: models.AuthenticationSubscription{
+			AuthenticationMethod:          models.AuthMethod__5_G_AKA,
+			EncPermanentKey:               hex.EncodeToString(k),
+			EncOpcKey:                     hex.EncodeToString(opc),
+			SequenceNumber:                &models.SequenceNumber{Sqn: "000000000020"},
+			AuthenticationManagementField: "8000",
+		},
+		etags:     true,
+		racyPatch: true,
+	}
+	server := httptest.NewServer(h2c.NewHandler(udr, &http2.Server{}))
+	defer server.Close()
+
+	supi := "imsi-208930000000012"
+	// each instance has its own context, and so its own SQN locks
+	instances := []*Processor{
+		newStubUdrProcessor(t, supi, server.URL),
+		newStubUdrProcessor(t, supi, server.URL),
+	}
+
+	results := make([]*httptest.ResponseRecorder, numRequests)
+	var wg sync.WaitGroup
+	for i := 0; i < numRequests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(results[i])
+			instances[i%len(instances)].GenerateAuthDataProcedure(c, models.AuthenticationInfoRequest{
+				ServingNetworkName: "5G:mnc093.mcc208.3gppnetwork.org",
+				AusfInstanceId:     fmt.Sprintf("ausf-%d", i%3),
+			}, supi)
+		}(i)
+	}
+	wg.Wait()
+
+	sqns := make(map[string]bool)
+	for _, rec := range results {
+		if rec.Code != http.StatusOK {
+			// the attempts of a request may all lose to the other instance
+			continue
+		}
+		var res models.UdmUeauAuthenticationInfoResult
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		sqn := sqnFromAutn(t, opc, k, res.AuthenticationVector.Rand, res.AuthenticationVector.Autn)
+		require.False(t, sqns[sqn], "SQN %s handed out twice", sqn)
+		sqns[sqn] = true
+	}
+	require.NotEmpty(t, sqns)
+
+	// SEQ_HE counted every vector handed out
+	final, err := newSqnState(udr.authSubs.SequenceNumber, nil)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1+len(sqns)), final.seq)
+}
+
+func TestGenerateAuthDataProcedureSqnRejected(t *testing.T) {
+	testCases := []struct {
+		name           string
+		sequenceNumber *models.SequenceNumber
+		rejectPatch    bool
+		expectCause    string
+		expectPatches  int
+	}{
+		{
+			name:        "no sequenceNumber",
+			expectCause: authenticationRejected,
+		},
+		{
+			name:           "update rejected",
+			sequenceNumber: &models.SequenceNumber{Sqn: "000000000020"},
+			rejectPatch:    true,
+			expectCause:    "modification is rejected",
+			// the SQN did not change, so the UDM does not try again
+			expectPatches: 1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			udr := &stubUdr{
+				authSubs: models.AuthenticationSubscription{
+					AuthenticationMethod:          models.AuthMethod__5_G_AKA,
+					EncPermanentKey:               "465b5ce8b199b49faa5f0a2ee238a6bc",
+					EncOpcKey:                     "cd63cb71954a9f4e48a5994e37a02baf",
+					SequenceNumber:                tc.sequenceNumber,
+					AuthenticationManagementField: "8000",
+				},
+				rejectPatch: tc.rejectPatch,
+			}
+			server := httptest.NewServer(h2c.NewHandler(udr, &http2.Server{}))
+			defer server.Close()
+
+			supi := "imsi-208930000000011"
+			testProcessor := newStubUdrProcessor(t, supi, server.URL)
+
+			rec := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(rec)
+			testProcessor.GenerateAuthDataProcedure(c, models.AuthenticationInfoRequest{
+				ServingNetworkName: "5G:mnc093.mcc208.3gppnetwork.org",
+			}, supi)
+
+			require.Equal(t, http.StatusForbidden, rec.Code)
+			var problemDetails models.ProblemDetails
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problemDetails))
+			require.Equal(t, tc.expectCause, problemDetails.Cause)
+			require.Equal(t, tc.expectPatches, udr.patches)
+		})
+	}
+}
@@ -85,6 +85,11 @@ func (p *Processor) RegistrationAmf3gppAccessProcedure(c *gin.Context,
 		c.JSON(int(pd.Status), pd)
 		return
 	}
+	if problemDetails := p.checkAuthLink(ctx, ueID, registerRequest.Guami); problemDetails != nil {
+		c.JSON(int(problemDetails.Status), problemDetails)
+		return
+	}
+
 	// TODO: EPS interworking with N26 is not supported yet in this stage
 	var oldAmf3GppAccessRegContext *models.Amf3GppAccessRegistration
 	var ue *udm_context.UdmUeContext
@@ -163,6 +168,11 @@ func (p *Processor) RegisterAmfNon3gppAccessProcedure(c *gin.Context,
 		c.JSON(int(pd.Status), pd)
 		return
 	}
+	if problemDetails := p.checkAuthLink(ctx, ueID, registerRequest.Guami); problemDetails != nil {
+		c.JSON(int(problemDetails.Status), problemDetails)
+		return
+	}
+
 	var oldAmfNon3GppAccessRegContext *models.AmfNon3GppAccessRegistration
 	if p.Context().UdmAmfNon3gppRegContextExists(ueID) {
 		ue, _ := p.Context().UdmUeFindBySupi(ueID)
@@ -0,0 +1,136 @@
+package processor
+
+import (
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/free5gc/openapi/models"
+	udm_context "github.com/free5gc/udm/internal/context"
+	"github.com/free5gc/udm/internal/logger"
+	"github.com/free5gc/udm/pkg/suci"
+)
+
+const (
+	homeNetworkNotServed      = "HOME_NETWORK_NOT_SERVED"
+	routingIndicatorNotServed = "ROUTING_INDICATOR_NOT_SERVED"
+)
+
+// checkSuciServed rejects a SUCI of a home network or routing indicator not served by the UDM,
+// so that no SUPI of another operator's range is de-concealed
+func (p *Processor) checkSuciServed(supiOrSuci string) *models.ProblemDetails {
+	udmContext := p.Context()
+	err := suci.CheckServed(supiOrSuci, udmContext.ServedPlmnIds(), udmContext.GetRoutingIndicators())
+	if err == nil {
+		return nil
+	}
+
+	problemDetails := &models.ProblemDetails{
+		Status: http.StatusForbidden,
+		Cause:  homeNetworkNotServed,
+		Detail: err.Error(),
+	}
+	if errors.Is(err, suci.ErrRoutingIndicatorNotServed) {
+		problemDetails.Cause = routingIndicatorNotServed
+	}
+	logger.UeidLog.Warnln(problemDetails.Detail)
+	return problemDetails
+}
+
+// checkUeidConsumer rejects a request of an NF service consumer whose NF type, found at the NRF
+// from its NF instance ID, is not one of the UeidAllowedNfTypes. This holds without OAuth2 too,
+// where the NRF grants no access token restricted to the allowedNfTypes of the service.
+func (p *Processor) checkUeidConsumer(c *gin.Context) *models.ProblemDetails {
+	var peerCertificates []*x509.Certificate
+	if c.Request != nil && c.Request.TLS != nil && len(c.Request.TLS.VerifiedChains) > 0 {
+		peerCertificates = c.Request.TLS.PeerCertificates
+	}
+	var token string
+	if c.Request != nil {
+		token = c.Request.Header.Get("Authorization")
+	}
+
+	problemDetails := &models.ProblemDetails{
+		Status: http.StatusForbidden,
+		Cause:  "ACCESS_NOT_ALLOWED",
+	}
+	nfInstanceId, err := p.Context().ConsumerNfInstanceId(token, peerCertificates)
+	if err != nil {
+		problemDetails.Detail = err.Error()
+		logger.UeidLog.Warnln(problemDetails.Detail)
+		return problemDetails
+	}
+	nfType, err := p.Consumer().SearchNFInstanceNfType(nfInstanceId, udm_context.UeidAllowedNfTypes)
+	if err != nil {
+		logger.UeidLog.Errorf("Search NF type of NF instance [%s] error: %+v", nfInstanceId, err)
+		return &models.ProblemDetails{
+			Status: http.StatusInternalServerError,
+			Cause:  "SYSTEM_FAILURE",
+			Detail: err.Error(),
+		}
+	}
+	if nfType == "" {
+		problemDetails.Detail = "NF instance [" + nfInstanceId + "] is not allowed to de-conceal SUCIs"
+		logger.UeidLog.Warnln(problemDetails.Detail)
+		return problemDetails
+	}
+	return nil
+}
+
+// DeconcealProcedure returns the SUPI concealed in the SUCI of the request (TS 29.503 5.11.2.2)
+func (p *Processor) DeconcealProcedure(c *gin.Context, deconcealReqData models.DeconcealReqData) {
+	logger.UeidLog.Traceln("In DeconcealProcedure")
+
+	if problemDetails := p.checkUeidConsumer(c); problemDetails != nil {
+		c.JSON(int(problemDetails.Status), problemDetails)
+		return
+	}
+
+	suciToDeconceal := deconcealReqData.Suci
+	if suciToDeconceal == "" {
+		problemDetails := &models.ProblemDetails{
+			Status: http.StatusBadRequest,
+			Cause:  "MANDATORY_IE_MISSING",
+			Detail: "suci is missing",
+		}
+		logger.UeidLog.Errorln(problemDetails.Detail)
+		c.JSON(int(problemDetails.Status), problemDetails)
+		return
+	}
+	if !strings.HasPrefix(suciToDeconceal, suci.PrefixSUCI+"-") {
+		problemDetails := &models.ProblemDetails{
+			Status: http.StatusBadRequest,
+			Cause:  "MANDATORY_IE_INCORRECT",
+			Detail: "suci [" + suciToDeconceal + "] is not a SUCI",
+		}
+		logger.UeidLog.Errorln(problemDetails.Detail)
+		c.JSON(int(problemDetails.Status), problemDetails)
+		return
+	}
+
+	if problemDetails := p.checkSuciServed(suciToDeconceal); problemDetails != nil {
+		c.JSON(int(problemDetails.Status), problemDetails)
+		return
+	}
+
+	supi, err := suci.ToSupi(suciToDeconceal, p.Context().GetSuciProfiles())
+	if err != nil {
+		problemDetails := &models.ProblemDetails{
+			Status: http.StatusBadRequest,
+			Cause:  "MANDATORY_IE_INCORRECT",
+			Detail: err.Error(),
+		}
+		if errors.Is(err, suci.ErrUnsupportedProtectionScheme) {
+			problemDetails.Status = http.StatusNotImplemented
+			problemDetails.Cause = "UNSUPPORTED_PROTECTION_SCHEME"
+		}
+		logger.UeidLog.Errorf("Deconceal [%s] error: %+v", suciToDeconceal, err)
+		c.JSON(int(problemDetails.Status), problemDetails)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.DeconcealRspData{Supi: supi})
+}
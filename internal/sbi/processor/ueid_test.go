@@ -0,0 +1,180 @@
+package processor
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+
+	"github.com/free5gc/openapi/models"
+	"github.com/free5gc/udm/pkg/suci"
+)
+
+const (
+	testAusfInstanceId = "2c2b2f5a-1b5e-4b53-8d0c-6a8f1f0e4b01"
+	testSmfInstanceId  = "7d3e9f46-30a4-4f8e-9b1d-5c2a8e7f6d02"
+)
+
+// newStubNrf returns an NRF discovering the NF instances of nfTypes by target NF type and NF
+// instance ID
+func newStubNrf(t *testing.T, nfTypes map[string]models.NrfNfManagementNfType) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		var result models.SearchResult
+		nfInstanceId := query.Get("target-nf-instance-id")
+		if nfType, ok := nfTypes[nfInstanceId]; ok && string(nfType) == query.Get("target-nf-type") {
+			result.NfInstances = []models.NrfNfDiscoveryNfProfile{{
+				NfInstanceId: nfInstanceId,
+				NfType:       nfType,
+				NfStatus:     models.NrfNfManagementNfStatus_REGISTERED,
+			}}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		require.NoError(t, json.NewEncoder(w).Encode(result))
+	}), &http2.Server{}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newMtlsRequest returns a request received over mutual TLS from the NF instance nfInstanceId,
+// or without TLS if nfInstanceId is empty
+func newMtlsRequest(nfInstanceId string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/nudm-ueid/v1/deconceal", nil)
+	if nfInstanceId == "" {
+		return req
+	}
+	cert := &x509.Certificate{URIs: []*url.URL{{Scheme: "urn", Opaque: "uuid:" + nfInstanceId}}}
+	req.TLS = &tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{cert},
+		VerifiedChains:   [][]*x509.Certificate{{cert}},
+	}
+	return req
+}
+
+func TestDeconcealProcedure(t *testing.T) {
+	testProcessor := newStubUdrProcessor(t, "imsi-20893001002086", "")
+	testProcessor.Context().NrfUri = newStubNrf(t, map[string]models.NrfNfManagementNfType{
+		testAusfInstanceId: models.NrfNfManagementNfType_AUSF,
+		testSmfInstanceId:  models.NrfNfManagementNfType_SMF,
+	}).URL
+	testProcessor.Context().SetSuciProfiles([]suci.SuciProfile{
+		{
+			ProtectionScheme: suci.ProfileAScheme,
+			PrivateKey:       "c53c22208b61860b06c62e5406a7b330c2b577aa5558981510d128247d38bd1d",
+			PublicKey:        "5a8d38864820197c3394b92613b20b91633cbd897119273bf8e4a6f4eec0a650",
+		},
+	}, "test")
+	testProcessor.Context().PlmnSupportList = []models.PlmnId{{Mcc: "208", Mnc: "93"}}
+	testProcessor.Context().RoutingIndicators = []string{"0"}
+
+	testCases := []struct {
+		name string
+		// NF instance of the client certificate, an AUSF unless set
+		nfInstanceId  string
+		noCertificate bool
+		suci          string
+		expectStatus  int
+		expectSupi    string
+		expectCause   string
+	}{
+		{
+			name:         "null scheme",
+			suci:         "suci-0-208-93-0-0-0-00007487",
+			expectStatus: http.StatusOK,
+			expectSupi:   "imsi-2089300007487",
+		},
+		{
+			// Without OAuth2 the NF service consumer must be authenticated by its certificate
+			name:          "no client certificate",
+			noCertificate: true,
+			suci:          "suci-0-208-93-0-0-0-00007487",
+			expectStatus:  http.StatusForbidden,
+			expectCause:   "ACCESS_NOT_ALLOWED",
+		},
+		{
+			name:         "NF type not allowed",
+			nfInstanceId: testSmfInstanceId,
+			suci:         "suci-0-208-93-0-0-0-00007487",
+			expectStatus: http.StatusForbidden,
+			expectCause:  "ACCESS_NOT_ALLOWED",
+		},
+		{
+			name: "profile A",
+			suci: "suci-0-208-93-0-1-1-b2e92f836055a255837debf850b528997ce0201cb82a" +
+				"dfe4be1f587d07d8457dcb02352410cddd9e730ef3fa87",
+			expectStatus: http.StatusOK,
+			expectSupi:   "imsi-20893001002086",
+		},
+		{
+			name:         "missing SUCI",
+			expectStatus: http.StatusBadRequest,
+			expectCause:  "MANDATORY_IE_MISSING",
+		},
+		{
+			name:         "SUPI",
+			suci:         "imsi-20893001002086",
+			expectStatus: http.StatusBadRequest,
+			expectCause:  "MANDATORY_IE_INCORRECT",
+		},
+		{
+			name: "wrong MAC",
+			suci: "suci-0-208-93-0-1-1-b2e92f836055a255837debf850b528997ce0201cb82a" +
+				"dfe4be1f587d07d8457dcb02352410cddd9e730ef3fa88",
+			expectStatus: http.StatusBadRequest,
+			expectCause:  "MANDATORY_IE_INCORRECT",
+		},
+		{
+			name:         "unsupported protection scheme",
+			suci:         "suci-0-208-93-0-7-1-b2e92f836055a255837debf850b528997ce0201cb82a",
+			expectStatus: http.StatusNotImplemented,
+			expectCause:  "UNSUPPORTED_PROTECTION_SCHEME",
+		},
+		{
+			name:         "foreign home network",
+			suci:         "suci-0-208-95-0-0-0-00007487",
+			expectStatus: http.StatusForbidden,
+			expectCause:  "HOME_NETWORK_NOT_SERVED",
+		},
+		{
+			name:         "unserved routing indicator",
+			suci:         "suci-0-208-93-12-0-0-00007487",
+			expectStatus: http.StatusForbidden,
+			expectCause:  "ROUTING_INDICATOR_NOT_SERVED",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(rec)
+			nfInstanceId := tc.nfInstanceId
+			if nfInstanceId == "" {
+				nfInstanceId = testAusfInstanceId
+			}
+			if tc.noCertificate {
+				nfInstanceId = ""
+			}
+			c.Request = newMtlsRequest(nfInstanceId)
+			testProcessor.DeconcealProcedure(c, models.DeconcealReqData{Suci: tc.suci})
+			require.Equal(t, tc.expectStatus, rec.Code, rec.Body.String())
+			if tc.expectStatus != http.StatusOK {
+				var problemDetails models.ProblemDetails
+				require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problemDetails))
+				require.Equal(t, tc.expectCause, problemDetails.Cause)
+				return
+			}
+
+			var rsp models.DeconcealRspData
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &rsp))
+			require.Equal(t, tc.expectSupi, rsp.Supi)
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"runtime/debug"
 	"sync"
@@ -46,13 +47,19 @@ func NewServer(udm ServerUdm, tlsKeyLogPath string) (*Server, error) {
 
 	cfg := s.Config()
 	bindAddr := cfg.GetSbiBindingAddr()
-	logger.SBILog.Infof("Binding addr: [%s]", bindAddr)
+	logger.SBILog.Infof("Binding addr: %v", cfg.GetSbiBindingAddrs())
 	var err error
 	if s.httpServer, err = httpwrapper.NewHttp2Server(bindAddr, tlsKeyLogPath, s.router); err != nil {
 		logger.InitLog.Errorf("Initialize HTTP server failed: %v", err)
 		return nil, err
 	}
 	s.httpServer.ErrorLog = log.New(logger.SBILog.WriterLevel(logrus.ErrorLevel), "HTTP2: ", 0)
+	if cfg.GetSbiScheme() == "https" {
+		if err = util.ConfigureServerMutualTLS(s.httpServer, cfg); err != nil {
+			logger.InitLog.Errorf("Initialize mutual TLS failed: %v", err)
+			return nil, err
+		}
+	}
 
 	return s, err
 }
@@ -82,27 +89,49 @@ func (s *Server) startServer(wg *sync.WaitGroup) {
 		wg.Done()
 	}()
 
-	logger.SBILog.Infof("Start SBI server (listen on %s)", s.httpServer.Addr)
+	cfg := s.Config()
+	bindAddrs := cfg.GetSbiBindingAddrs()
+	logger.SBILog.Infof("Start SBI server (listen on %v)", bindAddrs)
 
 	s.router = newRouter(s)
 
-	var err error
-	cfg := s.Config()
+	// Dual-stack: the server listens on the IPv4 and the IPv6 address, each with its own family,
+	// so that an IPv6 wildcard address does not take the IPv4 one too
+	networks := []string{"tcp"}
+	if len(bindAddrs) > 1 {
+		networks = []string{"tcp4", "tcp6"}
+	}
+	var serveWg sync.WaitGroup
+	for i, bindAddr := range bindAddrs {
+		serveWg.Add(1)
+		go func(network, bindAddr string) {
+			defer serveWg.Done()
+			err := s.serve(cfg, network, bindAddr)
+			if err != nil && err != http.ErrServerClosed {
+				logger.SBILog.Errorf("SBI server (listen on %s) error: %v", bindAddr, err)
+			}
+		}(networks[i], bindAddr)
+	}
+	serveWg.Wait()
+	logger.SBILog.Infof("SBI server (listen on %v) stopped", bindAddrs)
+}
+
+// serve serves the SBI on bindAddr of network until the server is shut down
+func (s *Server) serve(cfg *factory.Config, network, bindAddr string) error {
 	scheme := cfg.GetSbiScheme()
-	if scheme == "http" {
-		err = s.httpServer.ListenAndServe()
-	} else if scheme == "https" {
-		err = s.httpServer.ListenAndServeTLS(
-			cfg.GetCertPemPath(),
-			cfg.GetCertKeyPath())
-	} else {
-		err = fmt.Errorf("No support this scheme[%s]", scheme)
+	if scheme != "http" && scheme != "https" {
+		return fmt.Errorf("No support this scheme[%s]", scheme)
 	}
 
-	if err != nil && err != http.ErrServerClosed {
-		logger.SBILog.Errorf("SBI server error: %v", err)
+	listener, err := net.Listen(network, bindAddr)
+	if err != nil {
+		return err
+	}
+
+	if scheme == "http" {
+		return s.httpServer.Serve(listener)
 	}
-	logger.SBILog.Infof("SBI server (listen on %s) stopped", s.httpServer.Addr)
+	return s.httpServer.ServeTLS(listener, cfg.GetCertPemPath(), cfg.GetCertKeyPath())
 }
 
 func (s *Server) Shutdown() {
@@ -0,0 +1,66 @@
+package util
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// ETag is the entity tag of the resource read with a context of WithETag. The requests modifying
+// the resource with the same context are conditional on it with If-Match (RFC 9110 13.1.1), so
+// that the server rejects them with 412 when the resource was modified meanwhile.
+type ETag struct {
+	mu    sync.Mutex
+	value string
+}
+
+type etagKey struct{}
+
+// WithETag returns a context recording the ETag of the resource read with it
+func WithETag(ctx context.Context) (context.Context, *ETag) {
+	etag := new(ETag)
+	return context.WithValue(ctx, etagKey{}, etag), etag
+}
+
+// Value returns the recorded ETag, empty when the server sent none
+func (e *ETag) Value() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.value
+}
+
+func (e *ETag) set(value string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.value = value
+}
+
+// conditionalTransport sends the requests with send, handling the ETags of the requests with a
+// context of WithETag
+type conditionalTransport func(req *http.Request) (*http.Response, error)
+
+func (t conditionalTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	etag, ok := req.Context().Value(etagKey{}).(*ETag)
+	if !ok {
+		return t(req)
+	}
+	if req.Method != http.MethodGet {
+		if value := etag.Value(); value != "" {
+			req = req.Clone(req.Context())
+			req.Header.Set("If-Match", value)
+		}
+		return t(req)
+	}
+
+	rsp, err := t(req)
+	if err == nil && rsp.StatusCode == http.StatusOK {
+		etag.set(rsp.Header.Get("ETag"))
+	}
+	return rsp, err
+}
+
+// NewConditionalClient returns a client sending the requests with send, which makes the requests
+// with a context of WithETag conditional on the ETag of the resource read
+func NewConditionalClient(send func(req *http.Request) (*http.Response, error)) *http.Client {
+	return &http.Client{Transport: conditionalTransport(send)}
+}
@@ -0,0 +1,41 @@
+package util
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConditionalClient(t *testing.T) {
+	var ifMatch []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Header().Set("ETag", `"1"`)
+			return
+		}
+		ifMatch = append(ifMatch, r.Header.Get("If-Match"))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	client := NewConditionalClient(http.DefaultClient.Do)
+
+	do := func(ctx context.Context, method string) {
+		req, err := http.NewRequestWithContext(ctx, method, server.URL, nil)
+		require.NoError(t, err)
+		rsp, err := client.Do(req)
+		require.NoError(t, err)
+		require.NoError(t, rsp.Body.Close())
+	}
+
+	// A request is conditional on the ETag read with its context only
+	ctx, etag := WithETag(context.Background())
+	do(ctx, http.MethodPatch)
+	do(ctx, http.MethodGet)
+	require.Equal(t, `"1"`, etag.Value())
+	do(ctx, http.MethodPatch)
+	do(context.Background(), http.MethodPatch)
+	require.Equal(t, []string{"", `"1"`, ""}, ifMatch)
+}
@@ -11,7 +11,7 @@ import (
 	"github.com/free5gc/udm/pkg/factory"
 )
 
-func InitUDMContext(udmContext *context.UDMContext) {
+func InitUDMContext(udmContext *context.UDMContext) error {
 	config := factory.UdmConfig
 	logger.UtilLog.Info("udmconfig Info: Version[", config.Info.Version, "] Description[", config.Info.Description, "]")
 	configuration := config.Configuration
@@ -45,7 +45,13 @@ func InitUDMContext(udmContext *context.UDMContext) {
 	udmContext.NrfUri = configuration.NrfUri
 	servingNameList := configuration.ServiceNameList
 
-	udmContext.SuciProfiles = configuration.SuciProfiles
+	if err := udmContext.InitKeys(configuration); err != nil {
+		return err
+	}
+	udmContext.TuakProfiles = configuration.TuakProfiles
+	udmContext.Sqn = configuration.Sqn
+	udmContext.AuthLink = configuration.AuthLink
 
 	udmContext.InitNFService(servingNameList, config.Info.Version)
+	return nil
 }
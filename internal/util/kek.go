@@ -0,0 +1,19 @@
+package util
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/free5gc/udm/pkg/factory"
+	"github.com/free5gc/udm/pkg/keyprovider"
+)
+
+// DecryptWithKek decrypts a subscriber key stored in the UDR with the key-encryption key kek
+// configured in plaintext
+func DecryptWithKek(cipherText []byte, kek factory.KeyEncryptionKey) ([]byte, error) {
+	key, err := hex.DecodeString(kek.Key)
+	if err != nil {
+		return nil, fmt.Errorf("decode key-encryption key [%d]: %w", kek.EncryptionKey, err)
+	}
+	return keyprovider.DecryptWithKey(key, kek.EncryptionAlgorithm, cipherText)
+}
@@ -0,0 +1,97 @@
+package util
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/free5gc/udm/pkg/factory"
+)
+
+func TestDecryptWithKek(t *testing.T) {
+	plainKey, err := hex.DecodeString("00112233445566778899aabbccddeeff")
+	if err != nil {
+		t.Fatalf("hex decode error: %+v", err)
+	}
+
+	kekBytes, err := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	if err != nil {
+		t.Fatalf("hex decode error: %+v", err)
+	}
+	block, err := aes.NewCipher(kekBytes)
+	if err != nil {
+		t.Fatalf("AES error: %+v", err)
+	}
+	ecbCipherText := make([]byte, len(plainKey))
+	block.Encrypt(ecbCipherText, plainKey)
+
+	tests := []struct {
+		name       string
+		kek        factory.KeyEncryptionKey
+		cipherText string
+		wantErr    bool
+	}{
+		{
+			name: "AES-ECB",
+			kek: factory.KeyEncryptionKey{
+				EncryptionKey:       1,
+				EncryptionAlgorithm: factory.EncryptionAlgorithmAesEcb,
+				Key:                 "000102030405060708090a0b0c0d0e0f",
+			},
+			cipherText: hex.EncodeToString(ecbCipherText),
+		},
+		{
+			// RFC 3394 4.1 Wrap 128 bits of Key Data with a 128-bit KEK
+			name: "AES key wrap 128-bit KEK",
+			kek: factory.KeyEncryptionKey{
+				EncryptionKey:       2,
+				EncryptionAlgorithm: factory.EncryptionAlgorithmAesKeyWrap,
+				Key:                 "000102030405060708090a0b0c0d0e0f",
+			},
+			cipherText: "1fa68b0a8112b447aef34bd8fb5a7b829d3e862371d2cfe5",
+		},
+		{
+			// RFC 3394 4.3 Wrap 128 bits of Key Data with a 256-bit KEK
+			name: "AES key wrap 256-bit KEK",
+			kek: factory.KeyEncryptionKey{
+				EncryptionKey:       3,
+				EncryptionAlgorithm: factory.EncryptionAlgorithmAesKeyWrap,
+				Key:                 "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f",
+			},
+			cipherText: "64e8c3f9ce0f5ba263e9777905818a2a93c8191e7d6e8ae7",
+		},
+		{
+			name: "AES key wrap integrity failure",
+			kek: factory.KeyEncryptionKey{
+				EncryptionKey:       2,
+				EncryptionAlgorithm: factory.EncryptionAlgorithmAesKeyWrap,
+				Key:                 "000102030405060708090a0b0c0d0e0f",
+			},
+			cipherText: "1fa68b0a8112b447aef34bd8fb5a7b829d3e862371d2cfe6",
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cipherText, err := hex.DecodeString(tt.cipherText)
+			if err != nil {
+				t.Fatalf("hex decode error: %+v", err)
+			}
+			key, err := DecryptWithKek(cipherText, tt.kek)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected error, got key[%x]", key)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DecryptWithKek error: %+v", err)
+			}
+			if !bytes.Equal(key, plainKey) {
+				t.Errorf("key[%x], expected[%x]", key, plainKey)
+			}
+		})
+	}
+}
@@ -32,5 +32,14 @@ func (rac *RouterAuthorizationCheck) Check(c *gin.Context, udmContext udm_contex
 		return
 	}
 
+	if c.Request.TLS != nil {
+		if err = udmContext.CheckNfInstanceId(token, c.Request.TLS.PeerCertificates); err != nil {
+			logger.UtilLog.Warnf("RouterAuthorizationCheck::Check Forbidden: %s", err.Error())
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+	}
+
 	logger.UtilLog.Debugf("RouterAuthorizationCheck::Check Authorized")
 }
@@ -1,6 +1,7 @@
 package util
 
 import (
+	"crypto/x509"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -30,6 +31,10 @@ func (m *mockUDMContext) AuthorizationCheck(token string, serviceName models.Ser
 	return errors.New("invalid token")
 }
 
+func (m *mockUDMContext) CheckNfInstanceId(token string, peerCertificates []*x509.Certificate) error {
+	return nil
+}
+
 func TestRouterAuthorizationCheck_Check(t *testing.T) {
 	// Mock gin.Context
 	w := httptest.NewRecorder()
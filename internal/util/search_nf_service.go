@@ -2,12 +2,16 @@ package util
 
 import (
 	"fmt"
+	"net"
+	"strconv"
 
 	"github.com/free5gc/openapi/models"
 )
 
+// SearchNFServiceUri returns the URI of the service of nfProfile. Of the IP endpoints, the IPv6
+// ones are preferred with preferIPv6, for an IPv6-only UDM, and the IPv4 ones otherwise.
 func SearchNFServiceUri(nfProfile models.NrfNfDiscoveryNfProfile, serviceName models.ServiceName,
-	nfServiceStatus models.NfServiceStatus,
+	nfServiceStatus models.NfServiceStatus, preferIPv6 bool,
 ) (nfUri string) {
 	if nfProfile.NfServices != nil {
 		for _, service := range nfProfile.NfServices {
@@ -20,10 +24,11 @@ func SearchNFServiceUri(nfProfile models.NrfNfDiscoveryNfProfile, serviceName mo
 					nfUri = service.ApiPrefix
 				} else if service.IpEndPoints != nil {
 					point := (service.IpEndPoints)[0]
-					if point.Ipv4Address != "" {
-						nfUri = getSbiUri(service.Scheme, point.Ipv4Address, point.Port)
-					} else if len(nfProfile.Ipv4Addresses) != 0 {
-						nfUri = getSbiUri(service.Scheme, nfProfile.Ipv4Addresses[0], point.Port)
+					if ip := selectIp(preferIPv6, point.Ipv4Address, point.Ipv6Address); ip != "" {
+						nfUri = getSbiUri(service.Scheme, ip, point.Port)
+					} else if ip := selectIp(preferIPv6, firstOf(nfProfile.Ipv4Addresses),
+						firstOf(nfProfile.Ipv6Addresses)); ip != "" {
+						nfUri = getSbiUri(service.Scheme, ip, point.Port)
 					}
 				}
 			}
@@ -36,16 +41,32 @@ func SearchNFServiceUri(nfProfile models.NrfNfDiscoveryNfProfile, serviceName mo
 	return nfUri
 }
 
-func getSbiUri(scheme models.UriScheme, ipv4Address string, port int32) (uri string) {
-	if port != 0 {
-		uri = fmt.Sprintf("%s://%s:%d", scheme, ipv4Address, port)
-	} else {
+// selectIp returns the preferred one of ipv4Address and ipv6Address available
+func selectIp(preferIPv6 bool, ipv4Address, ipv6Address string) string {
+	if ipv6Address != "" && (preferIPv6 || ipv4Address == "") {
+		return ipv6Address
+	}
+	return ipv4Address
+}
+
+func firstOf(addresses []string) string {
+	if len(addresses) == 0 {
+		return ""
+	}
+	return addresses[0]
+}
+
+func getSbiUri(scheme models.UriScheme, ipAddress string, port int32) (uri string) {
+	if port == 0 {
 		switch scheme {
 		case models.UriScheme_HTTP:
-			uri = fmt.Sprintf("%s://%s:80", scheme, ipv4Address)
+			port = 80
 		case models.UriScheme_HTTPS:
-			uri = fmt.Sprintf("%s://%s:443", scheme, ipv4Address)
+			port = 443
+		default:
+			return ""
 		}
 	}
-	return
+	// IPv6 addresses are enclosed in brackets (RFC 3986 3.2.2)
+	return fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(ipAddress, strconv.Itoa(int(port))))
 }
@@ -0,0 +1,74 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/free5gc/openapi/models"
+)
+
+func TestSearchNFServiceUri(t *testing.T) {
+	udrService := func(ipEndPoints ...models.IpEndPoint) []models.NrfNfDiscoveryNfService {
+		return []models.NrfNfDiscoveryNfService{
+			{
+				ServiceName:     models.ServiceName_NUDR_DR,
+				NfServiceStatus: models.NfServiceStatus_REGISTERED,
+				Scheme:          models.UriScheme_HTTP,
+				IpEndPoints:     ipEndPoints,
+			},
+		}
+	}
+
+	tests := []struct {
+		name       string
+		nfProfile  models.NrfNfDiscoveryNfProfile
+		preferIPv6 bool
+		want       string
+	}{
+		{
+			name: "IPv4 endpoint",
+			nfProfile: models.NrfNfDiscoveryNfProfile{
+				NfServices: udrService(models.IpEndPoint{Ipv4Address: "127.0.0.4", Port: 8000}),
+			},
+			want: "http://127.0.0.4:8000",
+		},
+		{
+			name: "IPv6 endpoint",
+			nfProfile: models.NrfNfDiscoveryNfProfile{
+				NfServices: udrService(models.IpEndPoint{Ipv6Address: "2001:db8::4", Port: 8000}),
+			},
+			want: "http://[2001:db8::4]:8000",
+		},
+		{
+			name: "dual-stack endpoint",
+			nfProfile: models.NrfNfDiscoveryNfProfile{
+				NfServices: udrService(models.IpEndPoint{Ipv4Address: "127.0.0.4", Ipv6Address: "2001:db8::4"}),
+			},
+			want: "http://127.0.0.4:80",
+		},
+		{
+			name: "dual-stack endpoint for an IPv6-only UDM",
+			nfProfile: models.NrfNfDiscoveryNfProfile{
+				NfServices: udrService(models.IpEndPoint{Ipv4Address: "127.0.0.4", Ipv6Address: "2001:db8::4"}),
+			},
+			preferIPv6: true,
+			want:       "http://[2001:db8::4]:80",
+		},
+		{
+			name: "IPv6 address of the profile",
+			nfProfile: models.NrfNfDiscoveryNfProfile{
+				Ipv6Addresses: []string{"2001:db8::4"},
+				NfServices:    udrService(models.IpEndPoint{Port: 8000}),
+			},
+			want: "http://[2001:db8::4]:8000",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SearchNFServiceUri(tt.nfProfile, models.ServiceName_NUDR_DR, models.NfServiceStatus_REGISTERED,
+				tt.preferIPv6)
+			if got != tt.want {
+				t.Errorf("SearchNFServiceUri() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
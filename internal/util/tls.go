@@ -0,0 +1,141 @@
+package util
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"golang.org/x/net/http2"
+
+	"github.com/free5gc/udm/pkg/factory"
+)
+
+// Same as the default clients of openapi
+const (
+	clientTimeout         = 10 * time.Second
+	clientReadIdleTimeout = 1 * time.Second
+	clientPingTimeout     = 1 * time.Second
+)
+
+// LoadCaPool reads the CA bundle of caPemPath
+func LoadCaPool(caPemPath string) (*x509.CertPool, error) {
+	caPem, err := os.ReadFile(caPemPath)
+	if err != nil {
+		return nil, fmt.Errorf("read CA bundle: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPem) {
+		return nil, fmt.Errorf("no PEM certificate in CA bundle [%s]", caPemPath)
+	}
+	return pool, nil
+}
+
+// ConfigureServerMutualTLS makes server require client certificates issued by the CA bundle of
+// the configuration, if any
+func ConfigureServerMutualTLS(server *http.Server, cfg *factory.Config) error {
+	caPemPath := cfg.GetCaPemPath()
+	if caPemPath == "" {
+		return nil
+	}
+	pool, err := LoadCaPool(caPemPath)
+	if err != nil {
+		return err
+	}
+	if server.TLSConfig == nil {
+		server.TLSConfig = &tls.Config{}
+	}
+	server.TLSConfig.MinVersion = tls.VersionTLS12
+	server.TLSConfig.ClientCAs = pool
+	server.TLSConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	return nil
+}
+
+// NewMutualTLSClient returns the HTTP/2 client of the NF services consumed, which verifies the
+// producers against the CA bundle of the configuration and presents the certificate of the UDM.
+// It returns nil when mutual TLS is not used, the consumers then keep the clients of openapi.
+func NewMutualTLSClient(cfg *factory.Config) (*http.Client, error) {
+	caPemPath := cfg.GetCaPemPath()
+	if caPemPath == "" {
+		return nil, nil
+	}
+	pool, err := LoadCaPool(caPemPath)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := tls.LoadX509KeyPair(cfg.GetCertPemPath(), cfg.GetCertKeyPath())
+	if err != nil {
+		return nil, fmt.Errorf("load client certificate: %w", err)
+	}
+
+	return &http.Client{
+		Transport: &mutualTLSTransport{
+			tlsTransport: &http2.Transport{
+				TLSClientConfig: &tls.Config{
+					MinVersion:   tls.VersionTLS12,
+					RootCAs:      pool,
+					Certificates: []tls.Certificate{cert},
+				},
+				ReadIdleTimeout: clientReadIdleTimeout,
+				PingTimeout:     clientPingTimeout,
+			},
+			cleartextTransport: &http2.Transport{
+				AllowHTTP: true,
+				DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+					dialer := &net.Dialer{}
+					return dialer.DialContext(ctx, network, addr)
+				},
+				ReadIdleTimeout: clientReadIdleTimeout,
+				PingTimeout:     clientPingTimeout,
+			},
+		},
+		Timeout: clientTimeout,
+	}, nil
+}
+
+// mutualTLSTransport sends the https requests with mutual TLS and the http ones in cleartext
+// HTTP/2, as the producers of the UDM do not all use TLS
+type mutualTLSTransport struct {
+	tlsTransport       http.RoundTripper
+	cleartextTransport http.RoundTripper
+}
+
+func (t *mutualTLSTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme == "http" {
+		return t.cleartextTransport.RoundTrip(req)
+	}
+	rsp, err := t.tlsTransport.RoundTrip(req)
+	if err != nil {
+		return nil, HandshakeError(req.URL.Host, err)
+	}
+	return rsp, nil
+}
+
+// HandshakeError explains err when it is a TLS handshake failure with host, and returns it
+// unchanged otherwise
+func HandshakeError(host string, err error) error {
+	var (
+		unknownAuthorityErr x509.UnknownAuthorityError
+		hostnameErr         x509.HostnameError
+		certificateErr      x509.CertificateInvalidError
+		opErr               *net.OpError
+	)
+	switch {
+	case errors.As(err, &unknownAuthorityErr):
+		return fmt.Errorf("TLS handshake with [%s] failed, its certificate is not issued by the CA bundle: %w",
+			host, err)
+	case errors.As(err, &hostnameErr):
+		return fmt.Errorf("TLS handshake with [%s] failed, its certificate is not valid for this host: %w", host, err)
+	case errors.As(err, &certificateErr):
+		return fmt.Errorf("TLS handshake with [%s] failed, its certificate is invalid: %w", host, err)
+	case errors.As(err, &opErr) && opErr.Op == "remote error":
+		// A TLS alert of the peer, e.g. for a certificate of the UDM it does not trust
+		return fmt.Errorf("TLS handshake with [%s] failed, rejected by the peer: %w", host, err)
+	}
+	return err
+}
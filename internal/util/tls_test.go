@@ -0,0 +1,163 @@
+package util
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/free5gc/udm/pkg/factory"
+)
+
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	pemPath string
+	keyPath string
+}
+
+// newTestCert writes a certificate signed by issuer, self-signed when issuer is nil
+func newTestCert(t *testing.T, dir, name string, template *x509.Certificate, issuer *testCert) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	template.Subject = pkix.Name{CommonName: name}
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	parent, parentKey := template, key
+	if issuer != nil {
+		parent, parentKey = issuer.cert, issuer.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	c := &testCert{
+		cert:    cert,
+		key:     key,
+		pemPath: filepath.Join(dir, name+".pem"),
+		keyPath: filepath.Join(dir, name+".key"),
+	}
+	require.NoError(t, os.WriteFile(c.pemPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(c.keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}),
+		0o600))
+	return c
+}
+
+func newTestCa(t *testing.T, dir, name string) *testCert {
+	return newTestCert(t, dir, name, &x509.Certificate{
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil)
+}
+
+func newTlsConfig(cert *testCert, ca *testCert) *factory.Config {
+	return &factory.Config{
+		Configuration: &factory.Configuration{
+			Sbi: &factory.Sbi{
+				Scheme: "https",
+				Tls: &factory.Tls{
+					Pem:   cert.pemPath,
+					Key:   cert.keyPath,
+					CaPem: ca.pemPath,
+				},
+			},
+		},
+	}
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCa(t, dir, "ca")
+	otherCa := newTestCa(t, dir, "other-ca")
+	server := newTestCert(t, dir, "udr", &x509.Certificate{
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}, ca)
+	client := newTestCert(t, dir, "udm", &x509.Certificate{
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}, ca)
+	untrustedClient := newTestCert(t, dir, "rogue", &x509.Certificate{
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, otherCa)
+
+	httpServer := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}),
+		ReadHeaderTimeout: time.Second,
+	}
+	require.NoError(t, ConfigureServerMutualTLS(httpServer, newTlsConfig(server, ca)))
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		_ = httpServer.ServeTLS(listener, server.pemPath, server.keyPath)
+	}()
+	defer httpServer.Close()
+	url := "https://" + listener.Addr().String()
+
+	tests := []struct {
+		name    string
+		cfg     *factory.Config
+		wantErr string
+	}{
+		{
+			name: "trusted client",
+			cfg:  newTlsConfig(client, ca),
+		},
+		{
+			name:    "client certificate of another CA",
+			cfg:     newTlsConfig(untrustedClient, otherCa),
+			wantErr: "not issued by the CA bundle",
+		},
+		{
+			name:    "client trusting another CA",
+			cfg:     newTlsConfig(client, otherCa),
+			wantErr: "not issued by the CA bundle",
+		},
+		{
+			name:    "client certificate rejected by the server",
+			cfg:     newTlsConfig(untrustedClient, ca),
+			wantErr: "rejected by the peer",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpClient, err := NewMutualTLSClient(tt.cfg)
+			require.NoError(t, err)
+			rsp, err := httpClient.Get(url)
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.NoError(t, rsp.Body.Close())
+			require.Equal(t, http.StatusNoContent, rsp.StatusCode)
+		})
+	}
+}
+
+func TestNewMutualTLSClientDisabled(t *testing.T) {
+	httpClient, err := NewMutualTLSClient(&factory.Config{
+		Configuration: &factory.Configuration{Sbi: &factory.Sbi{Scheme: "http"}},
+	})
+	require.NoError(t, err)
+	require.Nil(t, httpClient)
+}
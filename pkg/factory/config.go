@@ -6,13 +6,20 @@ package factory
 
 import (
 	"fmt"
+	"net"
 	"os"
+	"reflect"
+	"regexp"
 	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/asaskevich/govalidator"
 
+	"github.com/free5gc/openapi/models"
 	"github.com/free5gc/udm/internal/logger"
+	"github.com/free5gc/udm/pkg/keyprovider"
 	"github.com/free5gc/udm/pkg/suci"
 )
 
@@ -71,7 +78,201 @@ type Configuration struct {
 	NrfUri          string             `yaml:"nrfUri,omitempty"  valid:"required, url"`
 	NrfCertPem      string             `yaml:"nrfCertPem,omitempty" valid:"optional"`
 	SuciProfiles    []suci.SuciProfile `yaml:"SuciProfile,omitempty"`
+	// Home networks of the SUCIs served by the UDM; any when empty
+	PlmnSupportList []models.PlmnId `yaml:"plmnSupportList,omitempty"`
+	// Routing indicators of the SUCIs served by the UDM (TS 23.003 2.2B), registered in the
+	// UdmInfo; any when empty
+	RoutingIndicators []string `yaml:"routingIndicators,omitempty"`
+	// UDM group and identity ranges registered in the UdmInfo, used by the NFs selecting a UDM
+	UdmInfo *UdmInfo `yaml:"udmInfo,omitempty" valid:"optional"`
+	// File of the SuciProfile list, instead of SuciProfile, reloaded by the UDM when it changes
+	SuciProfileFile string        `yaml:"suciProfileFile,omitempty" valid:"optional"`
+	TuakProfiles    []TuakProfile `yaml:"tuakProfiles,omitempty"`
+	// Key-encryption keys protecting encPermanentKey, encOpcKey and encTopcKey in the UDR
+	KeyEncryptionKeys []KeyEncryptionKey `yaml:"keyEncryptionKeys,omitempty"`
+	// Where the keys referenced by PrivateKeyRef and keyRef are kept; files by default
+	KeyProvider *keyprovider.Config `yaml:"keyProvider,omitempty" valid:"optional"`
+	Sqn         *Sqn                `yaml:"sqn,omitempty" valid:"optional"`
+	AuthLink    *AuthLink           `yaml:"authLink,omitempty" valid:"optional"`
 }
+
+// UdmInfo is the UDM specific data registered in the NF profile (TS 29.510 6.1.6.2.7); the routing
+// indicators are those of routingIndicators
+type UdmInfo struct {
+	GroupId                        string                 `yaml:"groupId,omitempty"`
+	SupiRanges                     []models.SupiRange     `yaml:"supiRanges,omitempty"`
+	GpsiRanges                     []models.IdentityRange `yaml:"gpsiRanges,omitempty"`
+	ExternalGroupIdentifiersRanges []models.IdentityRange `yaml:"externalGroupIdentifiersRanges,omitempty"`
+}
+
+func (u *UdmInfo) validate() (bool, error) {
+	var errs govalidator.Errors
+	for _, r := range u.SupiRanges {
+		if err := validateIdentityRange("supiRanges", r.Start, r.End, r.Pattern); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, r := range u.GpsiRanges {
+		if err := validateIdentityRange("gpsiRanges", r.Start, r.End, r.Pattern); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, r := range u.ExternalGroupIdentifiersRanges {
+		if err := validateIdentityRange("externalGroupIdentifiersRanges", r.Start, r.End, r.Pattern); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return false, errs
+	}
+	return true, nil
+}
+
+// validateIdentityRange validates a SUPI or identity range (TS 29.510 6.1.6.2.10, 6.1.6.2.11): a
+// numeric range from start to end, or a regular expression
+func validateIdentityRange(field, start, end, pattern string) error {
+	if pattern != "" {
+		if start != "" || end != "" {
+			return fmt.Errorf("Invalid UdmInfo %s: start/end and pattern are exclusive", field)
+		}
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("Invalid UdmInfo %s pattern [%s]: %w", field, pattern, err)
+		}
+		return nil
+	}
+	if !govalidator.StringMatches(start, "^[0-9]+$") || !govalidator.StringMatches(end, "^[0-9]+$") {
+		return fmt.Errorf("Invalid UdmInfo %s: start [%s] end [%s], should be digits, or a pattern given", field,
+			start, end)
+	}
+	if len(start) != len(end) || start > end {
+		return fmt.Errorf("Invalid UdmInfo %s: start [%s] end [%s], should have as many digits and start <= end",
+			field, start, end)
+	}
+	return nil
+}
+
+// AuthLink links the AMF registrations of a UE to its last successful authentication
+// (TS 33.501 6.1.4), so that an AMF which did not authenticate the UE cannot register it
+type AuthLink struct {
+	// off: registrations are not checked, flag: registrations without a matching authentication
+	// are accepted with a warning, reject: they are rejected
+	Mode string `yaml:"mode,omitempty" valid:"optional,in(off|flag|reject)"`
+	// Seconds after the authentication within which the AMF has to register the UE
+	Window int `yaml:"window,omitempty"`
+}
+
+const (
+	AuthLinkModeOff       = "off"
+	AuthLinkModeFlag      = "flag"
+	AuthLinkModeReject    = "reject"
+	AuthLinkDefaultWindow = 30
+)
+
+func (a *AuthLink) GetMode() string {
+	if a == nil || a.Mode == "" {
+		return AuthLinkModeOff
+	}
+	return a.Mode
+}
+
+func (a *AuthLink) GetWindow() time.Duration {
+	if a == nil || a.Window == 0 {
+		return AuthLinkDefaultWindow * time.Second
+	}
+	return time.Duration(a.Window) * time.Second
+}
+
+// Sqn configures the management of sequence numbers SQN = SEQ || IND of TS 33.102 Annex C
+type Sqn struct {
+	// Length in bits of IND, used unless the subscription carries its own indLength
+	IndLength int `yaml:"indLength,omitempty"`
+	// Maximum jump Δ of SEQ accepted by the USIM (C.2.2), used to decide on a resynchronisation
+	// whether SEQ_HE must be reset to SEQ_MS
+	Delta uint64 `yaml:"delta,omitempty"`
+	// Age limit L of the USIM (C.2.3), rejecting a SEQ more than L below SEQ_MS; 0 when the USIM
+	// does not check the age of SEQ
+	AgeLimit uint64 `yaml:"ageLimit,omitempty"`
+}
+
+const (
+	SqnDefaultIndLength = 5
+	SqnDefaultDelta     = 1 << 28
+	SqnMaxIndLength     = 16
+)
+
+func (s *Sqn) GetIndLength() int {
+	if s == nil || s.IndLength == 0 {
+		return SqnDefaultIndLength
+	}
+	return s.IndLength
+}
+
+func (s *Sqn) GetDelta() uint64 {
+	if s == nil || s.Delta == 0 {
+		return SqnDefaultDelta
+	}
+	return s.Delta
+}
+
+func (s *Sqn) GetAgeLimit() uint64 {
+	if s == nil {
+		return 0
+	}
+	return s.AgeLimit
+}
+
+// KeyEncryptionKey is selected by the encryptionKey identifier carried in the
+// protectionParameterId of an authentication subscription. Identifier 0 means plaintext.
+type KeyEncryptionKey struct {
+	EncryptionKey       int    `yaml:"encryptionKey"`
+	EncryptionAlgorithm int    `yaml:"encryptionAlgorithm"`
+	Key                 string `yaml:"key,omitempty"`
+	// Reference of the key in the KeyProvider, instead of key
+	KeyRef string `yaml:"keyRef,omitempty"`
+}
+
+const (
+	EncryptionAlgorithmNone       = keyprovider.AlgorithmNone
+	EncryptionAlgorithmAesEcb     = keyprovider.AlgorithmAesEcb
+	EncryptionAlgorithmAesKeyWrap = keyprovider.AlgorithmAesKeyWrap
+)
+
+// TuakProfile holds the TUAK parameters (TS 35.231) of the subscriptions whose
+// authentication subscription carries the same algorithmId
+type TuakProfile struct {
+	AlgorithmId      string `yaml:"algorithmId"`
+	MacLength        int    `yaml:"macLength,omitempty"`        // bits: 64, 128 or 256
+	ResLength        int    `yaml:"resLength,omitempty"`        // bits: 32, 64, 128 or 256
+	KeccakIterations int    `yaml:"keccakIterations,omitempty"` // 1-255
+}
+
+const (
+	TuakDefaultMacLength        = 64
+	TuakDefaultResLength        = 64
+	TuakDefaultKeccakIterations = 1
+)
+
+func (t *TuakProfile) GetMacLength() int {
+	if t.MacLength == 0 {
+		return TuakDefaultMacLength
+	}
+	return t.MacLength
+}
+
+func (t *TuakProfile) GetResLength() int {
+	if t.ResLength == 0 {
+		return TuakDefaultResLength
+	}
+	return t.ResLength
+}
+
+func (t *TuakProfile) GetKeccakIterations() int {
+	if t.KeccakIterations == 0 {
+		return TuakDefaultKeccakIterations
+	}
+	return t.KeccakIterations
+}
+
 type Logger struct {
 	Enable       bool   `yaml:"enable" valid:"type(bool)"`
 	Level        string `yaml:"level" valid:"required,in(trace|debug|info|warn|error|fatal|panic)"`
@@ -88,9 +289,10 @@ func (c *Configuration) validate() (bool, error) {
 	if c.ServiceNameList != nil {
 		var errs govalidator.Errors
 		for _, v := range c.ServiceNameList {
-			if v != "nudm-sdm" && v != "nudm-uecm" && v != "nudm-ueau" && v != "nudm-ee" && v != "nudm-pp" {
+			if v != "nudm-sdm" && v != "nudm-uecm" && v != "nudm-ueau" && v != "nudm-ee" && v != "nudm-pp" &&
+				v != "nudm-ueid" {
 				err := fmt.Errorf("Invalid ServiceNameList: [%s],"+
-					" value should be nudm-sdm or nudm-uecm or nudm-ueau or nudm-ee or nudm-pp", v)
+					" value should be nudm-sdm or nudm-uecm or nudm-ueau or nudm-ee or nudm-pp or nudm-ueid", v)
 				errs = append(errs, err)
 			}
 		}
@@ -100,25 +302,78 @@ func (c *Configuration) validate() (bool, error) {
 	}
 
 	if c.SuciProfiles != nil {
+		if c.SuciProfileFile != "" {
+			return false, govalidator.Errors{fmt.Errorf("Invalid SuciProfile: SuciProfile and suciProfileFile are exclusive")}
+		}
+		if err := ValidateSuciProfiles(c.SuciProfiles); err != nil {
+			return false, err
+		}
+	}
+
+	if err := validateServedSuciNetworks(c.PlmnSupportList, c.RoutingIndicators); err != nil {
+		return false, err
+	}
+
+	if udmInfo := c.UdmInfo; udmInfo != nil {
+		if result, err := udmInfo.validate(); err != nil {
+			return result, err
+		}
+	}
+
+	if c.TuakProfiles != nil {
 		var errs govalidator.Errors
-		for _, s := range c.SuciProfiles {
-			protectScheme := s.ProtectionScheme
-			if result := govalidator.StringMatches(protectScheme, "^[A-F0-9]{1}$"); !result {
-				err := fmt.Errorf("Invalid ProtectionScheme: %s, should be a single hexadecimal digit", protectScheme)
-				errs = append(errs, err)
+		algorithmIds := make(map[string]bool)
+		for _, t := range c.TuakProfiles {
+			if t.AlgorithmId == "" {
+				errs = append(errs, fmt.Errorf("Invalid TuakProfile: algorithmId is required"))
+			} else if algorithmIds[t.AlgorithmId] {
+				errs = append(errs, fmt.Errorf("Invalid TuakProfile: duplicated algorithmId [%s]", t.AlgorithmId))
 			}
+			algorithmIds[t.AlgorithmId] = true
 
-			privateKey := s.PrivateKey
-			if result := govalidator.StringMatches(privateKey, "^[A-Fa-f0-9]{64}$"); !result {
-				err := fmt.Errorf("Invalid PrivateKey: %s, should be 64 hexadecimal digits", privateKey)
-				errs = append(errs, err)
+			if m := t.GetMacLength(); m != 64 && m != 128 && m != 256 {
+				errs = append(errs, fmt.Errorf("Invalid TuakProfile macLength: %d, should be 64, 128 or 256", m))
+			}
+			if r := t.GetResLength(); r != 32 && r != 64 && r != 128 && r != 256 {
+				errs = append(errs, fmt.Errorf("Invalid TuakProfile resLength: %d, should be 32, 64, 128 or 256", r))
 			}
+			if i := t.GetKeccakIterations(); i < 1 || i > 255 {
+				errs = append(errs, fmt.Errorf("Invalid TuakProfile keccakIterations: %d, should be 1-255", i))
+			}
+		}
+		if len(errs) > 0 {
+			return false, error(errs)
+		}
+	}
 
-			publicKey := s.PublicKey
-			if result := govalidator.StringMatches(publicKey, "^[A-Fa-f0-9]{64,130}$"); !result {
-				err := fmt.Errorf("Invalid PublicKey: %s, should be 64(profile A), 66(profile B, compressed),"+
-					"or 130(profile B, uncompressed) hexadecimal digits", publicKey)
-				errs = append(errs, err)
+	if c.KeyEncryptionKeys != nil {
+		var errs govalidator.Errors
+		encryptionKeys := make(map[int]bool)
+		for _, kek := range c.KeyEncryptionKeys {
+			if kek.EncryptionKey <= 0 {
+				errs = append(errs, fmt.Errorf("Invalid KeyEncryptionKey encryptionKey: %d, should be positive",
+					kek.EncryptionKey))
+			} else if encryptionKeys[kek.EncryptionKey] {
+				errs = append(errs, fmt.Errorf("Invalid KeyEncryptionKey: duplicated encryptionKey [%d]",
+					kek.EncryptionKey))
+			}
+			encryptionKeys[kek.EncryptionKey] = true
+
+			if kek.EncryptionAlgorithm != EncryptionAlgorithmAesEcb &&
+				kek.EncryptionAlgorithm != EncryptionAlgorithmAesKeyWrap {
+				errs = append(errs, fmt.Errorf("Invalid KeyEncryptionKey encryptionAlgorithm: %d, should be %d(AES-ECB)"+
+					" or %d(AES key wrap)", kek.EncryptionAlgorithm, EncryptionAlgorithmAesEcb, EncryptionAlgorithmAesKeyWrap))
+			}
+
+			if kek.KeyRef != "" {
+				if kek.Key != "" {
+					errs = append(errs, fmt.Errorf("Invalid KeyEncryptionKey [%d]: key and keyRef are exclusive",
+						kek.EncryptionKey))
+				}
+			} else if result := govalidator.StringMatches(kek.Key,
+				"^([A-Fa-f0-9]{32}|[A-Fa-f0-9]{48}|[A-Fa-f0-9]{64})$"); !result {
+				errs = append(errs, fmt.Errorf("Invalid KeyEncryptionKey [%d] key, should be 32, 48 or 64 hexadecimal digits",
+					kek.EncryptionKey))
 			}
 		}
 		if len(errs) > 0 {
@@ -126,10 +381,137 @@ func (c *Configuration) validate() (bool, error) {
 		}
 	}
 
+	if i := c.Sqn.GetIndLength(); i < 1 || i > SqnMaxIndLength {
+		return false, govalidator.Errors{fmt.Errorf("Invalid Sqn indLength: %d, should be 1-%d", i, SqnMaxIndLength)}
+	}
+
+	if c.AuthLink != nil && c.AuthLink.Window < 0 {
+		return false, govalidator.Errors{
+			fmt.Errorf("Invalid AuthLink window: %d, should not be negative", c.AuthLink.Window),
+		}
+	}
+
+	if c.KeyProvider.GetType() == keyprovider.TypePkcs11 && c.KeyProvider.Pkcs11 == nil {
+		return false, govalidator.Errors{fmt.Errorf("Invalid KeyProvider: pkcs11 is required for type pkcs11")}
+	}
+
 	result, err := govalidator.ValidateStruct(c)
 	return result, err
 }
 
+// ChangedFields returns the yaml names of the fields of the configuration whose value differs in
+// newConfiguration
+func (c *Configuration) ChangedFields(newConfiguration *Configuration) []string {
+	var changed []string
+	oldValue, newValue := reflect.ValueOf(c).Elem(), reflect.ValueOf(newConfiguration).Elem()
+	for i := 0; i < oldValue.NumField(); i++ {
+		if !reflect.DeepEqual(oldValue.Field(i).Interface(), newValue.Field(i).Interface()) {
+			name, _, _ := strings.Cut(oldValue.Type().Field(i).Tag.Get("yaml"), ",")
+			changed = append(changed, name)
+		}
+	}
+	return changed
+}
+
+// validateServedSuciNetworks validates the PLMNs and routing indicators of the SUCIs served
+func validateServedSuciNetworks(plmnSupportList []models.PlmnId, routingIndicators []string) error {
+	var errs govalidator.Errors
+	plmnIds := make(map[string]bool)
+	for _, plmnId := range plmnSupportList {
+		if !govalidator.StringMatches(plmnId.Mcc, "^[0-9]{3}$") || !govalidator.StringMatches(plmnId.Mnc, "^[0-9]{2,3}$") {
+			errs = append(errs, fmt.Errorf("Invalid plmnSupportList: mcc [%s] mnc [%s], should be 3 and 2-3 digits",
+				plmnId.Mcc, plmnId.Mnc))
+		} else if plmnIds[plmnId.Mcc+plmnId.Mnc] {
+			errs = append(errs, fmt.Errorf("Invalid plmnSupportList: duplicated mcc [%s] mnc [%s]",
+				plmnId.Mcc, plmnId.Mnc))
+		}
+		plmnIds[plmnId.Mcc+plmnId.Mnc] = true
+	}
+
+	routingIndicatorSet := make(map[string]bool)
+	for _, routingIndicator := range routingIndicators {
+		if !govalidator.StringMatches(routingIndicator, "^[0-9]{1,4}$") {
+			errs = append(errs, fmt.Errorf("Invalid routingIndicators: [%s], should be 1-4 digits", routingIndicator))
+		} else if routingIndicatorSet[routingIndicator] {
+			errs = append(errs, fmt.Errorf("Invalid routingIndicators: duplicated [%s]", routingIndicator))
+		}
+		routingIndicatorSet[routingIndicator] = true
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// ValidateSuciProfiles validates the SUCI profiles of the configuration or of a suciProfileFile
+func ValidateSuciProfiles(suciProfiles []suci.SuciProfile) error {
+	var errs govalidator.Errors
+	publicKeyIds := make(map[string]bool)
+	for i, s := range suciProfiles {
+		protectScheme := s.ProtectionScheme
+		if result := govalidator.StringMatches(protectScheme, "^[A-F0-9]{1}$"); !result {
+			err := fmt.Errorf("Invalid ProtectionScheme: %s, should be a single hexadecimal digit", protectScheme)
+			errs = append(errs, err)
+		}
+
+		publicKeyId := s.GetPublicKeyId(i)
+		if publicKeyId < 1 || publicKeyId > 255 {
+			errs = append(errs, fmt.Errorf("Invalid PublicKeyId: %d, should be 1-255", publicKeyId))
+		} else if key := fmt.Sprintf("%s-%d", protectScheme, publicKeyId); publicKeyIds[key] {
+			errs = append(errs, fmt.Errorf("Invalid SuciProfile: duplicated PublicKeyId [%d] for ProtectionScheme [%s]",
+				publicKeyId, protectScheme))
+		} else {
+			publicKeyIds[key] = true
+		}
+
+		if protectScheme == suci.ProfileMlKemScheme {
+			errs = append(errs, validateMlKemSuciProfile(s)...)
+			continue
+		}
+
+		privateKey := s.PrivateKey
+		if s.PrivateKeyRef != "" {
+			if privateKey != "" {
+				err := fmt.Errorf("Invalid SuciProfile: PrivateKey and PrivateKeyRef [%s] are exclusive", s.PrivateKeyRef)
+				errs = append(errs, err)
+			}
+		} else if result := govalidator.StringMatches(privateKey, "^[A-Fa-f0-9]{64}$"); !result {
+			err := fmt.Errorf("Invalid PrivateKey: %s, should be 64 hexadecimal digits", privateKey)
+			errs = append(errs, err)
+		}
+
+		publicKey := s.PublicKey
+		if result := govalidator.StringMatches(publicKey, "^[A-Fa-f0-9]{64,130}$"); !result {
+			err := fmt.Errorf("Invalid PublicKey: %s, should be 64(profile A), 66(profile B, compressed),"+
+				"or 130(profile B, uncompressed) hexadecimal digits", publicKey)
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// validateMlKemSuciProfile validates the keys of a SUCI profile of the ML-KEM scheme, whose
+// PrivateKey is the 64-octet seed of the ML-KEM-768 decapsulation key and PublicKey the
+// encapsulation key
+func validateMlKemSuciProfile(s suci.SuciProfile) govalidator.Errors {
+	var errs govalidator.Errors
+	if s.PrivateKeyRef != "" {
+		errs = append(errs, fmt.Errorf("Invalid SuciProfile: PrivateKeyRef [%s] is not supported by ProtectionScheme %s",
+			s.PrivateKeyRef, s.ProtectionScheme))
+	} else if result := govalidator.StringMatches(s.PrivateKey, "^[A-Fa-f0-9]{128}$"); !result {
+		errs = append(errs, fmt.Errorf("Invalid PrivateKey: %s, should be 128 hexadecimal digits", s.PrivateKey))
+	}
+	// RE2 repetitions are limited to 1000, so the length is checked apart
+	if result := govalidator.StringMatches(s.PublicKey, "^[A-Fa-f0-9]+$"); !result || len(s.PublicKey) != 2368 {
+		errs = append(errs, fmt.Errorf("Invalid PublicKey: should be 2368 hexadecimal digits for ProtectionScheme %s",
+			s.ProtectionScheme))
+	}
+	return errs
+}
+
 func (c *Config) GetCertPemPath() string {
 	c.RLock()
 	defer c.RUnlock()
@@ -142,11 +524,29 @@ func (c *Config) GetCertKeyPath() string {
 	return c.Configuration.Sbi.Tls.Key
 }
 
+// GetCaPemPath returns the CA bundle verifying the peers, or "" when mutual TLS is not used
+func (c *Config) GetCaPemPath() string {
+	c.RLock()
+	defer c.RUnlock()
+	if c.Configuration.Sbi == nil || c.Configuration.Sbi.Tls == nil {
+		return ""
+	}
+	return c.Configuration.Sbi.Tls.CaPem
+}
+
+func (c *Config) GetNfInstanceIdCheck() bool {
+	c.RLock()
+	defer c.RUnlock()
+	return c.Configuration.Sbi != nil && c.Configuration.Sbi.Tls != nil && c.Configuration.Sbi.Tls.NfInstanceIdCheck
+}
+
 type Sbi struct {
 	Scheme       string `yaml:"scheme" valid:"scheme"`
-	RegisterIPv4 string `yaml:"registerIPv4,omitempty" valid:"host,required"` // IP that is registered at NRF.
-	// IPv6Addr string `yaml:"ipv6Addr,omitempty"`
-	BindingIPv4 string `yaml:"bindingIPv4,omitempty" valid:"host,required"` // IP used to run the server in the node.
+	RegisterIPv4 string `yaml:"registerIPv4,omitempty" valid:"host,optional"` // IP that is registered at NRF.
+	RegisterIPv6 string `yaml:"registerIPv6,omitempty" valid:"ipv6,optional"` // IPv6 that is registered at NRF.
+	BindingIPv4  string `yaml:"bindingIPv4,omitempty" valid:"host,optional"`  // IP used to run the server in the node.
+	// IPv6 used to run the server in the node, alone or besides bindingIPv4 for dual-stack
+	BindingIPv6 string `yaml:"bindingIPv6,omitempty" valid:"host,optional"`
 	Port        int    `yaml:"port,omitempty" valid:"port,required"`
 	Tls         *Tls   `yaml:"tls,omitempty" valid:"optional"`
 }
@@ -162,6 +562,13 @@ func (s *Sbi) validate() (bool, error) {
 		}
 	}
 
+	if s.RegisterIPv4 == "" && s.RegisterIPv6 == "" {
+		return false, govalidator.Errors{fmt.Errorf("Invalid Sbi: registerIPv4 or registerIPv6 is required")}
+	}
+	if s.BindingIPv4 == "" && s.BindingIPv6 == "" {
+		return false, govalidator.Errors{fmt.Errorf("Invalid Sbi: bindingIPv4 or bindingIPv6 is required")}
+	}
+
 	result, err := govalidator.ValidateStruct(s)
 	return result, err
 }
@@ -169,9 +576,18 @@ func (s *Sbi) validate() (bool, error) {
 type Tls struct {
 	Pem string `yaml:"pem,omitempty" valid:"type(string),minstringlength(1),required"`
 	Key string `yaml:"key,omitempty" valid:"type(string),minstringlength(1),required"`
+	// CA bundle enabling mutual TLS (TS 33.501 13.1): the SBI server requires client certificates
+	// issued by it, and the clients verify the peers against it and present pem and key
+	CaPem string `yaml:"caPem,omitempty" valid:"type(string),optional"`
+	// Whether the NF instance ID in the client certificate (TS 33.310 6.1.3c) has to be the
+	// nfInstanceId (sub claim) of the access token it presents
+	NfInstanceIdCheck bool `yaml:"nfInstanceIdCheck,omitempty"`
 }
 
 func (t *Tls) validate() (bool, error) {
+	if t.NfInstanceIdCheck && t.CaPem == "" {
+		return false, govalidator.Errors{fmt.Errorf("Invalid Tls: nfInstanceIdCheck needs caPem")}
+	}
 	result, err := govalidator.ValidateStruct(t)
 	return result, err
 }
@@ -275,10 +691,29 @@ func (c *Config) GetLogReportCaller() bool {
 	return c.Logger.ReportCaller
 }
 
+// GetSbiBindingAddr returns the first address the SBI server listens on
 func (c *Config) GetSbiBindingAddr() string {
+	return c.GetSbiBindingAddrs()[0]
+}
+
+// GetSbiBindingAddrs returns the addresses the SBI server listens on: the IPv4 one, the IPv6 one,
+// or both for dual-stack
+func (c *Config) GetSbiBindingAddrs() []string {
+	port := strconv.Itoa(c.GetSbiPort())
+	bindIPv6 := c.GetSbiBindingIPv6()
+
 	c.RLock()
-	defer c.RUnlock()
-	return c.GetSbiBindingIP() + ":" + strconv.Itoa(c.GetSbiPort())
+	withIPv4 := bindIPv6 == "" || c.Configuration.Sbi.BindingIPv4 != ""
+	c.RUnlock()
+
+	var addrs []string
+	if withIPv4 {
+		addrs = append(addrs, net.JoinHostPort(c.GetSbiBindingIP(), port))
+	}
+	if bindIPv6 != "" {
+		addrs = append(addrs, net.JoinHostPort(bindIPv6, port))
+	}
+	return addrs
 }
 
 func (c *Config) GetSbiBindingIP() string {
@@ -298,6 +733,22 @@ func (c *Config) GetSbiBindingIP() string {
 	return bindIP
 }
 
+// GetSbiBindingIPv6 returns the IPv6 address the SBI server listens on, or "" when it does not
+// listen on IPv6
+func (c *Config) GetSbiBindingIPv6() string {
+	c.RLock()
+	defer c.RUnlock()
+	if c.Configuration == nil || c.Configuration.Sbi == nil || c.Configuration.Sbi.BindingIPv6 == "" {
+		return ""
+	}
+	bindIP := os.Getenv(c.Configuration.Sbi.BindingIPv6)
+	if bindIP != "" {
+		logger.CfgLog.Infof("Parsing ServerIPv6 [%s] from ENV Variable", bindIP)
+		return bindIP
+	}
+	return c.Configuration.Sbi.BindingIPv6
+}
+
 func (c *Config) GetSbiPort() int {
 	c.RLock()
 	defer c.RUnlock()
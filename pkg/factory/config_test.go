@@ -0,0 +1,219 @@
+package factory
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testSbi = `  sbi:
+    scheme: http
+    registerIPv4: 127.0.0.3
+    bindingIPv4: 127.0.0.3
+    port: 8000
+`
+
+func writeTestConfig(t *testing.T, sbi, configuration string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "udmcfg.yaml")
+	content := `info:
+  version: 1.0.3
+configuration:
+` + sbi + `  serviceNameList:
+    - nudm-ueau
+  nrfUri: http://127.0.0.10:8000
+` + configuration + `logger:
+  enable: true
+  level: info
+  reportCaller: false
+`
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestReadConfigValidation(t *testing.T) {
+	tests := []struct {
+		name          string
+		sbi           string
+		configuration string
+		wantErr       bool
+	}{
+		{
+			name: "valid",
+			sbi:  testSbi,
+			configuration: `  sqn:
+    indLength: 5
+  authLink:
+    mode: reject
+    window: 30
+  plmnSupportList:
+    - mcc: "208"
+      mnc: "93"
+  routingIndicators:
+    - "0"
+  udmInfo:
+    groupId: udm-group-1
+    supiRanges:
+      - start: "208930000000000"
+        end: "208930000000099"
+    gpsiRanges:
+      - pattern: "^msisdn-0900[0-9]{8}$"
+  tuakProfiles:
+    - algorithmId: "1"
+  keyEncryptionKeys:
+    - encryptionKey: 1
+      encryptionAlgorithm: 1
+      key: 000102030405060708090a0b0c0d0e0f
+`,
+		},
+		{
+			name:          "sqn indLength out of range",
+			sbi:           testSbi,
+			configuration: "  sqn:\n    indLength: 40\n",
+			wantErr:       true,
+		},
+		{
+			name:          "negative authLink window",
+			sbi:           testSbi,
+			configuration: "  authLink:\n    mode: flag\n    window: -1\n",
+			wantErr:       true,
+		},
+		{
+			name:          "unknown authLink mode",
+			sbi:           testSbi,
+			configuration: "  authLink:\n    mode: warn\n",
+			wantErr:       true,
+		},
+		{
+			name:          "pkcs11 key provider without pkcs11",
+			sbi:           testSbi,
+			configuration: "  keyProvider:\n    type: pkcs11\n",
+			wantErr:       true,
+		},
+		{
+			name: "SuciProfile and suciProfileFile",
+			sbi:  testSbi,
+			configuration: `  SuciProfile:
+    - ProtectionScheme: 1
+      PublicKeyId: 1
+      PrivateKey: c53c22208b61860b06c62e5406a7b330c2b577aa5558981510d128247d38bd1d
+      PublicKey: 5a8d38864820197c3394b92613b20b91633cbd897119273bf8e4a6f4eec0a650
+  suciProfileFile: suci.yaml
+`,
+			wantErr: true,
+		},
+		{
+			name:          "duplicated TUAK algorithmId",
+			sbi:           testSbi,
+			configuration: "  tuakProfiles:\n    - algorithmId: \"1\"\n    - algorithmId: \"1\"\n",
+			wantErr:       true,
+		},
+		{
+			name:          "invalid TUAK macLength",
+			sbi:           testSbi,
+			configuration: "  tuakProfiles:\n    - algorithmId: \"1\"\n      macLength: 32\n",
+			wantErr:       true,
+		},
+		{
+			name: "key-encryption key and keyRef",
+			sbi:  testSbi,
+			configuration: `  keyEncryptionKeys:
+    - encryptionKey: 1
+      encryptionAlgorithm: 1
+      key: 000102030405060708090a0b0c0d0e0f
+      keyRef: kek-1
+`,
+			wantErr: true,
+		},
+		{
+			name:          "invalid plmnSupportList",
+			sbi:           testSbi,
+			configuration: "  plmnSupportList:\n    - mcc: \"20\"\n      mnc: \"93\"\n",
+			wantErr:       true,
+		},
+		{
+			name:          "invalid routingIndicators",
+			sbi:           testSbi,
+			configuration: "  routingIndicators:\n    - \"12345\"\n",
+			wantErr:       true,
+		},
+		{
+			name:          "udmInfo range with start after end",
+			sbi:           testSbi,
+			configuration: "  udmInfo:\n    supiRanges:\n      - start: \"20893009\"\n        end: \"20893000\"\n",
+			wantErr:       true,
+		},
+		{
+			name:          "udmInfo range with invalid pattern",
+			sbi:           testSbi,
+			configuration: "  udmInfo:\n    gpsiRanges:\n      - pattern: \"^msisdn-(\"\n",
+			wantErr:       true,
+		},
+		{
+			name: "dual-stack sbi without register IP",
+			sbi: `  sbi:
+    scheme: http
+    bindingIPv4: 0.0.0.0
+    bindingIPv6: "::"
+    port: 8000
+`,
+			wantErr: true,
+		},
+		{
+			name: "sbi without binding IP",
+			sbi: `  sbi:
+    scheme: http
+    registerIPv6: 2001:db8::3
+    port: 8000
+`,
+			wantErr: true,
+		},
+		{
+			name: "IPv6-only sbi",
+			sbi: `  sbi:
+    scheme: http
+    registerIPv6: 2001:db8::3
+    bindingIPv6: "::"
+    port: 8000
+`,
+		},
+		{
+			name: "dual-stack sbi",
+			sbi: `  sbi:
+    scheme: http
+    registerIPv4: 127.0.0.3
+    registerIPv6: 2001:db8::3
+    bindingIPv4: 0.0.0.0
+    bindingIPv6: "::"
+    port: 8000
+`,
+		},
+		{
+			name: "nfInstanceIdCheck without caPem",
+			sbi: `  sbi:
+    scheme: https
+    registerIPv4: 127.0.0.3
+    bindingIPv4: 127.0.0.3
+    port: 8000
+    tls:
+      pem: cert/udm.pem
+      key: cert/udm.key
+      nfInstanceIdCheck: true
+`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := ReadConfig(writeTestConfig(t, tt.sbi, tt.configuration))
+			if tt.wantErr {
+				require.Error(t, err)
+				require.Nil(t, cfg)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@
 package factory
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
@@ -12,6 +13,7 @@ import (
 	"gopkg.in/yaml.v2"
 
 	"github.com/free5gc/udm/internal/logger"
+	"github.com/free5gc/udm/pkg/suci"
 )
 
 var UdmConfig *Config
@@ -40,9 +42,13 @@ func ReadConfig(cfgPath string) (*Config, error) {
 		return nil, fmt.Errorf("ReadConfig [%s] Error: %+v", cfgPath, err)
 	}
 	if _, err := cfg.Validate(); err != nil {
-		validErrs := err.(govalidator.Errors).Errors()
-		for _, validErr := range validErrs {
-			logger.CfgLog.Errorf("%+v", validErr)
+		var validErrs govalidator.Errors
+		if errors.As(err, &validErrs) {
+			for _, validErr := range validErrs.Errors() {
+				logger.CfgLog.Errorf("%+v", validErr)
+			}
+		} else {
+			logger.CfgLog.Errorf("%+v", err)
 		}
 		logger.CfgLog.Errorf("[-- PLEASE REFER TO SAMPLE CONFIG FILE COMMENTS --]")
 		return nil, fmt.Errorf("Config validate Error")
@@ -50,3 +56,21 @@ func ReadConfig(cfgPath string) (*Config, error) {
 
 	return cfg, nil
 }
+
+// ReadSuciProfileFile reads and validates the SuciProfile list of a suciProfileFile
+func ReadSuciProfileFile(path string) ([]suci.SuciProfile, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("[Factory] %+v", err)
+	}
+	var suciProfileFile struct {
+		SuciProfiles []suci.SuciProfile `yaml:"SuciProfile"`
+	}
+	if err = yaml.UnmarshalStrict(content, &suciProfileFile); err != nil {
+		return nil, fmt.Errorf("[Factory] %+v", err)
+	}
+	if err = ValidateSuciProfiles(suciProfileFile.SuciProfiles); err != nil {
+		return nil, fmt.Errorf("[Factory] invalid SuciProfile in [%s]: %+v", path, err)
+	}
+	return suciProfileFile.SuciProfiles, nil
+}
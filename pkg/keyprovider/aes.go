@@ -0,0 +1,75 @@
+package keyprovider
+
+import (
+	"crypto/aes"
+	"crypto/subtle"
+	"encoding/binary"
+	"fmt"
+)
+
+// keyWrapDefaultIV is the default initial value of RFC 3394 2.2.3.1
+var keyWrapDefaultIV = []byte{0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6}
+
+// DecryptWithKey decrypts cipherText with the plaintext key-encryption key key
+func DecryptWithKey(key []byte, algorithm int, cipherText []byte) ([]byte, error) {
+	switch algorithm {
+	case AlgorithmNone:
+		return cipherText, nil
+	case AlgorithmAesEcb:
+		return aesEcbDecrypt(key, cipherText)
+	case AlgorithmAesKeyWrap:
+		return aesKeyUnwrap(key, cipherText)
+	default:
+		return nil, fmt.Errorf("unsupported encryptionAlgorithm [%d]", algorithm)
+	}
+}
+
+func aesEcbDecrypt(key, cipherText []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(cipherText) == 0 || len(cipherText)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("AES-ECB ciphertext length %d is not a multiple of %d", len(cipherText), aes.BlockSize)
+	}
+
+	plainText := make([]byte, len(cipherText))
+	for i := 0; i < len(cipherText); i += aes.BlockSize {
+		block.Decrypt(plainText[i:i+aes.BlockSize], cipherText[i:i+aes.BlockSize])
+	}
+	return plainText, nil
+}
+
+// aesKeyUnwrap implements the key unwrap process of RFC 3394 2.2.2
+func aesKeyUnwrap(key, cipherText []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(cipherText) < 24 || len(cipherText)%8 != 0 {
+		return nil, fmt.Errorf("AES key wrap ciphertext length %d is invalid", len(cipherText))
+	}
+
+	n := len(cipherText)/8 - 1
+	a := make([]byte, 8)
+	copy(a, cipherText[:8])
+	r := make([]byte, n*8)
+	copy(r, cipherText[8:])
+
+	b := make([]byte, aes.BlockSize)
+	for j := 5; j >= 0; j-- {
+		for i := n; i >= 1; i-- {
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(b[:8], binary.BigEndian.Uint64(a)^t)
+			copy(b[8:], r[(i-1)*8:i*8])
+			block.Decrypt(b, b)
+			copy(a, b[:8])
+			copy(r[(i-1)*8:i*8], b[8:])
+		}
+	}
+
+	if subtle.ConstantTimeCompare(a, keyWrapDefaultIV) != 1 {
+		return nil, fmt.Errorf("AES key unwrap integrity check failed")
+	}
+	return r, nil
+}
@@ -0,0 +1,86 @@
+package keyprovider
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// fileProvider reads the keys from files outside the configuration: the SUCI private
+// keys from PEM files (PKCS#8 "PRIVATE KEY" or SEC 1 "EC PRIVATE KEY") and the
+// key-encryption keys from files holding the key in hexadecimal
+type fileProvider struct{}
+
+func NewFileProvider() KeyProvider {
+	return &fileProvider{}
+}
+
+func (f *fileProvider) ECDH(keyRef string, peer *ecdh.PublicKey) ([]byte, error) {
+	priv, err := readPrivateKey(keyRef)
+	if err != nil {
+		return nil, err
+	}
+	if priv.Curve() != peer.Curve() {
+		return nil, fmt.Errorf("private key [%s] is not on the curve of the peer public key", keyRef)
+	}
+	return priv.ECDH(peer)
+}
+
+func (f *fileProvider) Decrypt(keyRef string, algorithm int, cipherText []byte) ([]byte, error) {
+	content, err := os.ReadFile(keyRef)
+	if err != nil {
+		return nil, fmt.Errorf("read key-encryption key: %w", err)
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(string(content)))
+	if err != nil {
+		return nil, fmt.Errorf("decode key-encryption key [%s]: %w", keyRef, err)
+	}
+	return DecryptWithKey(key, algorithm, cipherText)
+}
+
+func (f *fileProvider) Close() error {
+	return nil
+}
+
+func readPrivateKey(path string) (*ecdh.PrivateKey, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read private key: %w", err)
+	}
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block in private key file [%s]", path)
+	}
+	return ParsePrivateKey(block)
+}
+
+// ParsePrivateKey parses a X25519 or P-256 private key from a PKCS#8 or SEC 1 PEM block
+func ParsePrivateKey(block *pem.Block) (*ecdh.PrivateKey, error) {
+	var key any
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type [%s]", block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse private key: %w", err)
+	}
+
+	switch k := key.(type) {
+	case *ecdh.PrivateKey:
+		return k, nil
+	case *ecdsa.PrivateKey:
+		return k.ECDH()
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+}
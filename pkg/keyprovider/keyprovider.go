@@ -0,0 +1,72 @@
+// Package keyprovider gives the UDM access to its long-term secrets, the SUCI home network
+// private keys and the key-encryption keys of subscriber credentials, through a KeyProvider
+// so that the keys themselves may stay in files outside the configuration or in an HSM.
+package keyprovider
+
+import (
+	"crypto/ecdh"
+	"fmt"
+)
+
+const (
+	TypeFile   = "file"
+	TypePkcs11 = "pkcs11"
+)
+
+// Encryption algorithms of a key-encryption key
+const (
+	AlgorithmNone       = 0
+	AlgorithmAesEcb     = 1 // AES-ECB over each 128-bit block, length preserving
+	AlgorithmAesKeyWrap = 2 // AES Key Wrap, RFC 3394
+)
+
+// KeyProvider performs the operations which need a UDM private or secret key. A key is
+// designated by a reference whose meaning depends on the provider: a file path for the
+// file provider, the CKA_LABEL of the key object for the PKCS#11 provider.
+//
+// Only the UDM keys stay in the provider. The ECDH shared secrets and the subscriber keys,
+// K and OPc, are returned to the UDM, which runs Milenage or TUAK in its own memory: keeping
+// K in an HSM would need the HSM to compute the authentication vectors.
+type KeyProvider interface {
+	// ECDH computes the shared secret of the home network private key keyRef and the
+	// ephemeral public key of the UE (TS 33.501 C.3)
+	ECDH(keyRef string, peer *ecdh.PublicKey) ([]byte, error)
+	// Decrypt decrypts a subscriber key with the key-encryption key keyRef
+	Decrypt(keyRef string, algorithm int, cipherText []byte) ([]byte, error)
+	Close() error
+}
+
+type Config struct {
+	Type   string        `yaml:"type,omitempty" valid:"optional,in(file|pkcs11)"`
+	Pkcs11 *Pkcs11Config `yaml:"pkcs11,omitempty" valid:"optional"`
+}
+
+type Pkcs11Config struct {
+	// Path of the PKCS#11 module, e.g. /usr/lib/softhsm/libsofthsm2.so
+	Library    string `yaml:"library" valid:"required"`
+	TokenLabel string `yaml:"tokenLabel" valid:"required"`
+	// User PIN, or the name of the environment variable holding it
+	Pin string `yaml:"pin,omitempty" valid:"optional"`
+}
+
+func (c *Config) GetType() string {
+	if c == nil || c.Type == "" {
+		return TypeFile
+	}
+	return c.Type
+}
+
+// New returns the KeyProvider described by cfg; the file provider when cfg is nil
+func New(cfg *Config) (KeyProvider, error) {
+	switch cfg.GetType() {
+	case TypeFile:
+		return NewFileProvider(), nil
+	case TypePkcs11:
+		if cfg.Pkcs11 == nil {
+			return nil, fmt.Errorf("pkcs11 key provider is not configured")
+		}
+		return NewPkcs11Provider(cfg.Pkcs11)
+	default:
+		return nil, fmt.Errorf("unsupported key provider type [%s]", cfg.Type)
+	}
+}
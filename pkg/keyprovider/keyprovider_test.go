@@ -0,0 +1,103 @@
+package keyprovider
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writePem(t *testing.T, blockType string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "key.pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600))
+	return path
+}
+
+func TestFileProviderECDH(t *testing.T) {
+	x25519Key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	x25519Der, err := x509.MarshalPKCS8PrivateKey(x25519Key)
+	require.NoError(t, err)
+
+	p256Key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	p256Der, err := x509.MarshalECPrivateKey(p256Key)
+	require.NoError(t, err)
+	p256EcdhKey, err := p256Key.ECDH()
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name    string
+		keyRef  string
+		priv    *ecdh.PrivateKey
+		peer    ecdh.Curve
+		wantErr bool
+	}{
+		{
+			name:   "X25519 PKCS#8",
+			keyRef: writePem(t, "PRIVATE KEY", x25519Der),
+			priv:   x25519Key,
+			peer:   ecdh.X25519(),
+		},
+		{
+			name:   "P-256 SEC 1",
+			keyRef: writePem(t, "EC PRIVATE KEY", p256Der),
+			priv:   p256EcdhKey,
+			peer:   ecdh.P256(),
+		},
+		{
+			name:    "curve mismatch",
+			keyRef:  writePem(t, "PRIVATE KEY", x25519Der),
+			peer:    ecdh.P256(),
+			wantErr: true,
+		},
+		{
+			name:    "missing file",
+			keyRef:  filepath.Join(t.TempDir(), "missing.pem"),
+			peer:    ecdh.X25519(),
+			wantErr: true,
+		},
+	}
+
+	provider := NewFileProvider()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ephemeral, err := tc.peer.GenerateKey(rand.Reader)
+			require.NoError(t, err)
+
+			shared, err := provider.ECDH(tc.keyRef, ephemeral.PublicKey())
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			expected, err := ephemeral.ECDH(tc.priv.PublicKey())
+			require.NoError(t, err)
+			require.Equal(t, expected, shared)
+		})
+	}
+}
+
+func TestFileProviderDecrypt(t *testing.T) {
+	// RFC 3394 4.1: wrap 128 bits of key data with a 128-bit KEK
+	kekPath := filepath.Join(t.TempDir(), "kek")
+	require.NoError(t, os.WriteFile(kekPath, []byte("000102030405060708090A0B0C0D0E0F\n"), 0o600))
+	cipherText, err := hex.DecodeString("1FA68B0A8112B447AEF34BD8FB5A7B829D3E862371D2CFE5")
+	require.NoError(t, err)
+
+	plainText, err := NewFileProvider().Decrypt(kekPath, AlgorithmAesKeyWrap, cipherText)
+	require.NoError(t, err)
+	require.Equal(t, "00112233445566778899aabbccddeeff", hex.EncodeToString(plainText))
+
+	_, err = NewFileProvider().Decrypt(kekPath, 3, cipherText)
+	require.Error(t, err)
+}
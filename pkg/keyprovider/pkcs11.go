@@ -0,0 +1,181 @@
+//go:build cgo
+
+package keyprovider
+
+import (
+	"crypto/ecdh"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/miekg/pkcs11"
+)
+
+// pkcs11Provider keeps the keys in a PKCS#11 token; the SUCI private keys and the
+// key-encryption keys never leave it. Keys are found by their CKA_LABEL.
+type pkcs11Provider struct {
+	// a PKCS#11 session must not be used concurrently
+	mu      sync.Mutex
+	ctx     *pkcs11.Ctx
+	session pkcs11.SessionHandle
+}
+
+func NewPkcs11Provider(cfg *Pkcs11Config) (KeyProvider, error) {
+	ctx := pkcs11.New(cfg.Library)
+	if ctx == nil {
+		return nil, fmt.Errorf("load PKCS#11 module [%s] failed", cfg.Library)
+	}
+	if err := ctx.Initialize(); err != nil {
+		ctx.Destroy()
+		return nil, fmt.Errorf("PKCS#11 initialize: %w", err)
+	}
+
+	p := &pkcs11Provider{ctx: ctx}
+	if err := p.openSession(cfg); err != nil {
+		p.finalize()
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *pkcs11Provider) openSession(cfg *Pkcs11Config) error {
+	slots, err := p.ctx.GetSlotList(true)
+	if err != nil {
+		return fmt.Errorf("PKCS#11 get slot list: %w", err)
+	}
+	for _, slot := range slots {
+		tokenInfo, err := p.ctx.GetTokenInfo(slot)
+		if err != nil || tokenInfo.Label != cfg.TokenLabel {
+			continue
+		}
+		p.session, err = p.ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION|pkcs11.CKF_RW_SESSION)
+		if err != nil {
+			return fmt.Errorf("PKCS#11 open session: %w", err)
+		}
+		pin := os.Getenv(cfg.Pin)
+		if pin == "" {
+			pin = cfg.Pin
+		}
+		if err = p.ctx.Login(p.session, pkcs11.CKU_USER, pin); err != nil {
+			return fmt.Errorf("PKCS#11 login to token [%s]: %w", cfg.TokenLabel, err)
+		}
+		return nil
+	}
+	return fmt.Errorf("PKCS#11 token [%s] not found", cfg.TokenLabel)
+}
+
+func (p *pkcs11Provider) findObject(class uint, label string) (pkcs11.ObjectHandle, error) {
+	template := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, class),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+	}
+	if err := p.ctx.FindObjectsInit(p.session, template); err != nil {
+		return 0, fmt.Errorf("PKCS#11 find objects: %w", err)
+	}
+	objects, _, err := p.ctx.FindObjects(p.session, 1)
+	if errFinal := p.ctx.FindObjectsFinal(p.session); err == nil {
+		err = errFinal
+	}
+	if err != nil {
+		return 0, fmt.Errorf("PKCS#11 find objects: %w", err)
+	}
+	if len(objects) == 0 {
+		return 0, fmt.Errorf("PKCS#11 key [%s] not found", label)
+	}
+	return objects[0], nil
+}
+
+// extractableSecret is the template of the session objects holding a derived or
+// unwrapped secret until its value is read and returned to the UDM, see KeyProvider
+func extractableSecret(attrs ...*pkcs11.Attribute) []*pkcs11.Attribute {
+	return append([]*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_SECRET_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_GENERIC_SECRET),
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, false),
+		pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, false),
+		pkcs11.NewAttribute(pkcs11.CKA_EXTRACTABLE, true),
+	}, attrs...)
+}
+
+func (p *pkcs11Provider) readAndDestroy(object pkcs11.ObjectHandle) ([]byte, error) {
+	defer func() {
+		_ = p.ctx.DestroyObject(p.session, object)
+	}()
+	attrs, err := p.ctx.GetAttributeValue(p.session, object,
+		[]*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_VALUE, nil)})
+	if err != nil {
+		return nil, fmt.Errorf("PKCS#11 get value: %w", err)
+	}
+	return attrs[0].Value, nil
+}
+
+func (p *pkcs11Provider) ECDH(keyRef string, peer *ecdh.PublicKey) ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	priv, err := p.findObject(pkcs11.CKO_PRIVATE_KEY, keyRef)
+	if err != nil {
+		return nil, err
+	}
+	// X25519 and P-256 shared secrets are both 32 octets
+	params := pkcs11.NewECDH1DeriveParams(pkcs11.CKD_NULL, nil, peer.Bytes())
+	mech := []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_ECDH1_DERIVE, params)}
+	secret, err := p.ctx.DeriveKey(p.session, mech, priv,
+		extractableSecret(pkcs11.NewAttribute(pkcs11.CKA_VALUE_LEN, 32)))
+	if err != nil {
+		return nil, fmt.Errorf("PKCS#11 ECDH with key [%s]: %w", keyRef, err)
+	}
+	return p.readAndDestroy(secret)
+}
+
+func (p *pkcs11Provider) Decrypt(keyRef string, algorithm int, cipherText []byte) ([]byte, error) {
+	if algorithm == AlgorithmNone {
+		return cipherText, nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	kek, err := p.findObject(pkcs11.CKO_SECRET_KEY, keyRef)
+	if err != nil {
+		return nil, err
+	}
+	switch algorithm {
+	case AlgorithmAesEcb:
+		mech := []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_AES_ECB, nil)}
+		if err = p.ctx.DecryptInit(p.session, mech, kek); err != nil {
+			return nil, fmt.Errorf("PKCS#11 decrypt with key [%s]: %w", keyRef, err)
+		}
+		plainText, err := p.ctx.Decrypt(p.session, cipherText)
+		if err != nil {
+			return nil, fmt.Errorf("PKCS#11 decrypt with key [%s]: %w", keyRef, err)
+		}
+		return plainText, nil
+	case AlgorithmAesKeyWrap:
+		mech := []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_AES_KEY_WRAP, nil)}
+		secret, err := p.ctx.UnwrapKey(p.session, mech, kek, cipherText, extractableSecret())
+		if err != nil {
+			return nil, fmt.Errorf("PKCS#11 unwrap with key [%s]: %w", keyRef, err)
+		}
+		return p.readAndDestroy(secret)
+	default:
+		return nil, fmt.Errorf("unsupported encryptionAlgorithm [%d]", algorithm)
+	}
+}
+
+func (p *pkcs11Provider) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.finalize()
+}
+
+func (p *pkcs11Provider) finalize() error {
+	if p.session != 0 {
+		_ = p.ctx.Logout(p.session)
+		_ = p.ctx.CloseSession(p.session)
+		p.session = 0
+	}
+	err := p.ctx.Finalize()
+	p.ctx.Destroy()
+	return err
+}
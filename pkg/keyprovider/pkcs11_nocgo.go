@@ -0,0 +1,9 @@
+//go:build !cgo
+
+package keyprovider
+
+import "fmt"
+
+func NewPkcs11Provider(cfg *Pkcs11Config) (KeyProvider, error) {
+	return nil, fmt.Errorf("PKCS#11 key provider requires a cgo build")
+}
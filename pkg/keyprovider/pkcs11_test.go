@@ -0,0 +1,79 @@
+//go:build cgo
+
+package keyprovider
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/asn1"
+	"os"
+	"testing"
+
+	"github.com/miekg/pkcs11"
+	"github.com/stretchr/testify/require"
+)
+
+// oidP256 is the DER encoding of the prime256v1 curve OID
+var oidP256 = []byte{0x06, 0x08, 0x2a, 0x86, 0x48, 0xce, 0x3d, 0x03, 0x01, 0x07}
+
+// generatePkcs11P256Key generates a session P-256 key pair labelled label in the token
+func generatePkcs11P256Key(t *testing.T, provider KeyProvider, label string) *ecdh.PublicKey {
+	t.Helper()
+	p := provider.(*pkcs11Provider)
+	pub, _, err := p.ctx.GenerateKeyPair(p.session,
+		[]*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_EC_KEY_PAIR_GEN, nil)},
+		[]*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_TOKEN, false),
+			pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, oidP256),
+		},
+		[]*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_TOKEN, false),
+			pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+			pkcs11.NewAttribute(pkcs11.CKA_DERIVE, true),
+		})
+	require.NoError(t, err)
+
+	attrs, err := p.ctx.GetAttributeValue(p.session, pub,
+		[]*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, nil)})
+	require.NoError(t, err)
+	// CKA_EC_POINT is a DER OCTET STRING
+	var point []byte
+	_, err = asn1.Unmarshal(attrs[0].Value, &point)
+	require.NoError(t, err)
+	hnPublicKey, err := ecdh.P256().NewPublicKey(point)
+	require.NoError(t, err)
+	return hnPublicKey
+}
+
+// TestPkcs11Provider runs against a PKCS#11 token, e.g. SoftHSM:
+//
+//	softhsm2-util --init-token --free --label udm --pin 1234 --so-pin 1234
+//	UDM_PKCS11_LIBRARY=/usr/lib/softhsm/libsofthsm2.so UDM_PKCS11_TOKEN=udm UDM_PKCS11_PIN=1234 go test
+func TestPkcs11Provider(t *testing.T) {
+	library := os.Getenv("UDM_PKCS11_LIBRARY")
+	if library == "" {
+		t.Skip("UDM_PKCS11_LIBRARY is not set")
+	}
+	provider, err := NewPkcs11Provider(&Pkcs11Config{
+		Library:    library,
+		TokenLabel: os.Getenv("UDM_PKCS11_TOKEN"),
+		Pin:        "UDM_PKCS11_PIN",
+	})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, provider.Close())
+	}()
+
+	hnPublicKey := generatePkcs11P256Key(t, provider, "udm-test-suci")
+	ephemeral, err := ecdh.P256().GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	shared, err := provider.ECDH("udm-test-suci", ephemeral.PublicKey())
+	require.NoError(t, err)
+	expected, err := ephemeral.ECDH(hnPublicKey)
+	require.NoError(t, err)
+	require.True(t, bytes.Equal(expected, shared))
+
+	_, err = provider.ECDH("udm-test-missing", ephemeral.PublicKey())
+	require.Error(t, err)
+}
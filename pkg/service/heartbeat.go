@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/free5gc/udm/internal/logger"
+	"github.com/free5gc/udm/internal/sbi/consumer"
+)
+
+// Seconds between the heartbeats when the NRF did not return a heartBeatTimer
+const defaultHeartBeatTimer = 60
+
+// Unit of the heartBeatTimer, shortened by the tests
+var heartBeatTimerUnit = time.Second
+
+// Status of the UDM at its NRF, as seen from the heartbeats
+type nrfStatus string
+
+const (
+	nrfStatusRegistered   nrfStatus = "REGISTERED"
+	nrfStatusUnreachable  nrfStatus = "UNREACHABLE"
+	nrfStatusUnregistered nrfStatus = "UNREGISTERED"
+)
+
+// runNfHeartbeat sends the heartbeats of the UDM to its NRF every heartBeatTimer until ctx is
+// done (TS 29.510 5.2.2.3.2), and registers the UDM again when the NRF lost its profile, e.g.
+// after a restart
+func (a *UdmApp) runNfHeartbeat(ctx context.Context) {
+	status := nrfStatusRegistered
+	setStatus := func(newStatus nrfStatus, err error) {
+		if newStatus == status {
+			return
+		}
+		if err != nil {
+			logger.ConsumerLog.Warnf("NRF [%s] status %s -> %s: %v", a.Context().GetNrfUri(), status, newStatus, err)
+		} else {
+			logger.ConsumerLog.Infof("NRF [%s] status %s -> %s", a.Context().GetNrfUri(), status, newStatus)
+		}
+		status = newStatus
+	}
+
+	for {
+		heartBeatTimer := a.Context().GetHeartBeatTimer()
+		if heartBeatTimer <= 0 {
+			heartBeatTimer = defaultHeartBeatTimer
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Duration(heartBeatTimer) * heartBeatTimerUnit):
+		}
+
+		err := a.Consumer().SendNFHeartbeat()
+		switch {
+		case err == nil:
+			setStatus(nrfStatusRegistered, nil)
+		case errors.Is(err, consumer.ErrNfInstanceNotFound):
+			setStatus(nrfStatusUnregistered, err)
+			if _, _, err = a.Consumer().RegisterNFInstance(ctx); err != nil {
+				// Only when ctx is done, RegisterNFInstance retries until it succeeds
+				return
+			}
+			setStatus(nrfStatusRegistered, nil)
+		default:
+			setStatus(nrfStatusUnreachable, err)
+		}
+	}
+}
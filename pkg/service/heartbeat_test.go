@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+
+	"github.com/free5gc/openapi/models"
+	udm_context "github.com/free5gc/udm/internal/context"
+	"github.com/free5gc/udm/internal/sbi/consumer"
+)
+
+// stubNrf keeps the NF profiles registered, as an NRF which loses them when restarted
+type stubNrf struct {
+	mu            sync.Mutex
+	profiles      map[string]models.NrfNfManagementNfProfile
+	registrations int
+	heartbeats    int
+}
+
+func (n *stubNrf) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	const prefix = "/nnrf-nfm/v1/nf-instances/"
+	nfInstanceId := r.URL.Path[len(prefix):]
+	switch r.Method {
+	case http.MethodPut:
+		var profile models.NrfNfManagementNfProfile
+		if err := json.NewDecoder(r.Body).Decode(&profile); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		profile.HeartBeatTimer = 1
+		n.profiles[nfInstanceId] = profile
+		n.registrations++
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", "http://"+r.Host+prefix+nfInstanceId)
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(profile)
+	case http.MethodPatch:
+		if _, ok := n.profiles[nfInstanceId]; !ok {
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(models.ProblemDetails{Status: http.StatusNotFound})
+			return
+		}
+		n.heartbeats++
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (n *stubNrf) restart() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.profiles = make(map[string]models.NrfNfManagementNfProfile)
+}
+
+func (n *stubNrf) counts() (registrations, heartbeats int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.registrations, n.heartbeats
+}
+
+func TestRunNfHeartbeat(t *testing.T) {
+	defer func(unit time.Duration) {
+		heartBeatTimerUnit = unit
+	}(heartBeatTimerUnit)
+	heartBeatTimerUnit = 10 * time.Millisecond
+
+	nrf := &stubNrf{profiles: make(map[string]models.NrfNfManagementNfProfile)}
+	server := httptest.NewServer(h2c.NewHandler(nrf, &http2.Server{}))
+	defer server.Close()
+
+	udmContext := &udm_context.UDMContext{NfId: "1"}
+	udmContext.SetNrf(server.URL, "")
+	a := &UdmApp{udmCtx: udmContext}
+	var err error
+	a.consumer, err = consumer.NewConsumer(a)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	_, _, err = a.Consumer().RegisterNFInstance(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int32(1), udmContext.GetHeartBeatTimer())
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		a.runNfHeartbeat(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	require.Eventually(t, func() bool {
+		_, heartbeats := nrf.counts()
+		return heartbeats >= 2
+	}, time.Second, 10*time.Millisecond)
+
+	// the NRF lost the profile, the UDM registers again and goes on with the heartbeats
+	nrf.restart()
+	_, heartbeats := nrf.counts()
+	require.Eventually(t, func() bool {
+		registrations, newHeartbeats := nrf.counts()
+		return registrations == 2 && newHeartbeats >= heartbeats+2
+	}, time.Second, 10*time.Millisecond)
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"runtime/debug"
 	"sync"
+	"time"
 
 	"github.com/sirupsen/logrus"
 
@@ -22,6 +23,9 @@ import (
 
 var _ app.App = &UdmApp{}
 
+// How often the suciProfileFile is checked for new SUCI keys
+const suciProfileFileCheckInterval = 5 * time.Second
+
 type UdmApp struct {
 	udmCtx *udm_context.UDMContext
 	cfg    *factory.Config
@@ -30,6 +34,8 @@ type UdmApp struct {
 	cancel context.CancelFunc
 	wg     sync.WaitGroup
 
+	reloadMu sync.Mutex // serializes ReloadConfig
+
 	sbiServer *sbi.Server
 	consumer  *consumer.Consumer
 	processor *processor.Processor
@@ -43,12 +49,18 @@ func NewApp(ctx context.Context, cfg *factory.Config, tlsKeyLogPath string) (*Ud
 	udm.SetLogEnable(cfg.GetLogEnable())
 	udm.SetLogLevel(cfg.GetLogLevel())
 	udm.SetReportCaller(cfg.GetLogReportCaller())
-	udm_context.Init()
+	if err := udm_context.Init(); err != nil {
+		return udm, err
+	}
+	udm.udmCtx = udm_context.GetSelf()
 
 	consumer, err := consumer.NewConsumer(udm)
 	if err != nil {
 		return udm, err
 	}
+	if err = consumer.InitMutualTLS(cfg); err != nil {
+		return udm, err
+	}
 	udm.consumer = consumer
 
 	processor, err_p := processor.NewProcessor(udm)
@@ -58,7 +70,6 @@ func NewApp(ctx context.Context, cfg *factory.Config, tlsKeyLogPath string) (*Ud
 	udm.processor = processor
 
 	udm.ctx, udm.cancel = context.WithCancel(ctx)
-	udm.udmCtx = udm_context.GetSelf()
 
 	if udm.sbiServer, err = sbi.NewServer(udm, tlsKeyLogPath); err != nil {
 		return nil, err
@@ -114,10 +125,24 @@ func (a *UdmApp) Start() {
 	a.wg.Add(1)
 	go a.listenShutdownEvent()
 
+	if path := a.cfg.Configuration.SuciProfileFile; path != "" {
+		a.wg.Add(1)
+		go func() {
+			defer a.wg.Done()
+			a.Context().WatchSuciProfileFile(a.ctx, path, suciProfileFileCheckInterval)
+		}()
+	}
+
 	if err := a.sbiServer.Run(context.Background(), &a.wg); err != nil {
 		logger.MainLog.Fatalf("Run SBI server failed: %+v", err)
 	}
 
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		a.runNfHeartbeat(a.ctx)
+	}()
+
 	a.WaitRoutineStopped()
 }
 
@@ -167,6 +192,12 @@ func (a *UdmApp) terminateProcedure() {
 		logger.InitLog.Infof("Deregister from NRF successfully")
 	}
 
+	if provider := a.Context().KeyProvider; provider != nil {
+		if err = provider.Close(); err != nil {
+			logger.InitLog.Errorf("Close key provider Error[%+v]", err)
+		}
+	}
+
 	logger.MainLog.Infof("UDM SBI Server terminated")
 }
 
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"reflect"
+	"slices"
+
+	"github.com/free5gc/udm/internal/logger"
+	"github.com/free5gc/udm/pkg/factory"
+)
+
+// liveConfigurationFields are the configuration fields ReloadConfig applies to the running UDM;
+// the logger settings are applied as well, and the sbi one when only its nfInstanceIdCheck changes
+var liveConfigurationFields = []string{"SuciProfile", "nrfUri", "nrfCertPem", "routingIndicators", "udmInfo"}
+
+// ReloadConfig re-reads the configuration file cfgPath and applies the logger settings, the SUCI
+// profiles, the NRF and OAuth2 settings and the UdmInfo to the running UDM. The other changes need a
+// restart and are reported as ignored. A file failing validation is rejected and the running
+// configuration kept.
+func (a *UdmApp) ReloadConfig(cfgPath string) error {
+	a.reloadMu.Lock()
+	defer a.reloadMu.Unlock()
+
+	newCfg, err := factory.ReadConfig(cfgPath)
+	if err != nil {
+		logger.CfgLog.Errorf("Configuration reload rejected, keeping the running configuration: %+v", err)
+		return err
+	}
+	newConfiguration := newCfg.Configuration
+
+	a.cfg.RLock()
+	oldConfiguration := *a.cfg.Configuration
+	a.cfg.RUnlock()
+
+	// The SUCI keys of a suciProfileFile are validated before anything is applied
+	suciProfileFile := oldConfiguration.SuciProfileFile
+	if suciProfileFile != "" && suciProfileFile == newConfiguration.SuciProfileFile {
+		if _, err = factory.ReadSuciProfileFile(suciProfileFile); err != nil {
+			logger.CfgLog.Errorf("Configuration reload rejected, keeping the running configuration: %+v", err)
+			return err
+		}
+	}
+
+	var applied, ignored []string
+	for _, name := range oldConfiguration.ChangedFields(newConfiguration) {
+		if slices.Contains(liveConfigurationFields, name) ||
+			name == "sbi" && onlyNfInstanceIdCheckChanged(oldConfiguration.Sbi, newConfiguration.Sbi) {
+			applied = append(applied, name)
+		} else {
+			ignored = append(ignored, name)
+		}
+	}
+
+	// The SUCI profiles cannot move between the configuration and a suciProfileFile live
+	if i := slices.Index(applied, "SuciProfile"); i >= 0 && slices.Contains(ignored, "suciProfileFile") {
+		applied = slices.Delete(applied, i, i+1)
+		ignored = append(ignored, "SuciProfile")
+	}
+
+	a.SetLogEnable(newCfg.GetLogEnable())
+	a.SetLogLevel(newCfg.GetLogLevel())
+	a.SetReportCaller(newCfg.GetLogReportCaller())
+
+	udmContext := a.Context()
+	switch {
+	case suciProfileFile != "" && suciProfileFile == newConfiguration.SuciProfileFile:
+		// Re-read now rather than at the next check of the watcher
+		if err = udmContext.ReloadSuciProfileFile(suciProfileFile); err != nil {
+			return err
+		}
+	case slices.Contains(applied, "SuciProfile"):
+		udmContext.SetSuciProfiles(newConfiguration.SuciProfiles, cfgPath)
+	}
+
+	a.cfg.Lock()
+	if slices.Contains(applied, "SuciProfile") {
+		a.cfg.Configuration.SuciProfiles = newConfiguration.SuciProfiles
+	}
+	a.cfg.Configuration.NrfUri = newConfiguration.NrfUri
+	a.cfg.Configuration.NrfCertPem = newConfiguration.NrfCertPem
+	a.cfg.Configuration.RoutingIndicators = newConfiguration.RoutingIndicators
+	a.cfg.Configuration.UdmInfo = newConfiguration.UdmInfo
+	if slices.Contains(applied, "sbi") {
+		a.cfg.Configuration.Sbi = newConfiguration.Sbi
+	}
+	a.cfg.Unlock()
+
+	if slices.Contains(applied, "sbi") {
+		udmContext.SetNfInstanceIdCheck(newConfiguration.Sbi.Tls.NfInstanceIdCheck)
+	}
+
+	udmInfoChanged := slices.Contains(applied, "routingIndicators") || slices.Contains(applied, "udmInfo")
+	if udmInfoChanged {
+		udmContext.SetUdmInfo(newConfiguration.UdmInfo, newConfiguration.RoutingIndicators)
+	}
+
+	if newConfiguration.NrfUri != oldConfiguration.NrfUri {
+		a.changeNrf(newConfiguration.NrfUri, newConfiguration.NrfCertPem)
+	} else {
+		if newConfiguration.NrfCertPem != oldConfiguration.NrfCertPem {
+			// The access tokens signed with the key of the previous certificate are requested again
+			udmContext.SetNrf(newConfiguration.NrfUri, newConfiguration.NrfCertPem)
+		}
+		if udmInfoChanged {
+			a.updateUdmInfo()
+		}
+	}
+
+	logger.CfgLog.Infof("Configuration reloaded from [%s], applied changes of %v", cfgPath, applied)
+	if len(ignored) > 0 {
+		logger.CfgLog.Warnf("Configuration changes of %v ignored, they need a restart", ignored)
+	}
+	return nil
+}
+
+// onlyNfInstanceIdCheckChanged reports whether the sbi configurations differ by the
+// nfInstanceIdCheck of their TLS settings only, which applies live unlike the SBI server settings
+func onlyNfInstanceIdCheckChanged(oldSbi, newSbi *factory.Sbi) bool {
+	if oldSbi == nil || newSbi == nil || oldSbi.Tls == nil || newSbi.Tls == nil {
+		return false
+	}
+	sbi, tls := *newSbi, *newSbi.Tls
+	tls.NfInstanceIdCheck = oldSbi.Tls.NfInstanceIdCheck
+	sbi.Tls = &tls
+	return reflect.DeepEqual(*oldSbi, sbi)
+}
+
+// changeNrf moves the registration of the UDM from its NRF to the one of nrfUri
+func (a *UdmApp) changeNrf(nrfUri, nrfCertPem string) {
+	if err := a.Consumer().SendDeregisterNFInstance(); err != nil {
+		logger.CfgLog.Warnf("Deregister from NRF [%s] failed: %+v", a.Context().GetNrfUri(), err)
+	}
+	a.Context().SetNrf(nrfUri, nrfCertPem)
+
+	// The OAuth2 setting of the new NRF is learned on registration
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		if _, _, err := a.Consumer().RegisterNFInstance(a.ctx); err != nil {
+			logger.CfgLog.Errorf("Register to NRF [%s] failed: %+v", nrfUri, err)
+		}
+	}()
+}
+
+// updateUdmInfo updates the UdmInfo of the NF profile of the UDM at its NRF. An NRF which does not
+// know the UDM gets the whole profile when the heartbeat registers it again.
+func (a *UdmApp) updateUdmInfo() {
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		if err := a.Consumer().SendUpdateUdmInfo(); err != nil {
+			logger.CfgLog.Errorf("Update UdmInfo at NRF [%s] failed: %+v", a.Context().GetNrfUri(), err)
+		}
+	}()
+}
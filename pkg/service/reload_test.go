@@ -0,0 +1,272 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+
+	"github.com/free5gc/openapi/models"
+	udm_context "github.com/free5gc/udm/internal/context"
+	"github.com/free5gc/udm/internal/logger"
+	"github.com/free5gc/udm/internal/sbi/consumer"
+	"github.com/free5gc/udm/pkg/factory"
+)
+
+const (
+	profileAPrivateKey = "c53c22208b61860b06c62e5406a7b330c2b577aa5558981510d128247d38bd1d"
+	profileAPublicKey  = "5a8d38864820197c3394b92613b20b91633cbd897119273bf8e4a6f4eec0a650"
+)
+
+func writeConfig(t *testing.T, path string, port int, level, nrfCertPem, publicKeyId string) {
+	t.Helper()
+	content := fmt.Sprintf(`info:
+  version: 1.0.3
+configuration:
+  sbi:
+    scheme: http
+    registerIPv4: 127.0.0.3
+    bindingIPv4: 127.0.0.3
+    port: %d
+  serviceNameList:
+    - nudm-ueau
+  nrfUri: http://127.0.0.10:8000
+  nrfCertPem: %s
+  SuciProfile:
+    - ProtectionScheme: 1
+      PublicKeyId: %s
+      PrivateKey: %s
+      PublicKey: %s
+logger:
+  enable: true
+  level: %s
+  reportCaller: false
+`, port, nrfCertPem, publicKeyId, profileAPrivateKey, profileAPublicKey, level)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+}
+
+func TestReloadConfig(t *testing.T) {
+	defer logger.Log.SetLevel(logger.Log.GetLevel())
+
+	path := filepath.Join(t.TempDir(), "udmcfg.yaml")
+	writeConfig(t, path, 8000, "info", "cert/nrf.pem", "1")
+	cfg, err := factory.ReadConfig(path)
+	require.NoError(t, err)
+
+	udmContext := &udm_context.UDMContext{}
+	udmContext.SetNrf(cfg.Configuration.NrfUri, cfg.Configuration.NrfCertPem)
+	udmContext.SetSuciProfiles(cfg.Configuration.SuciProfiles, "configuration")
+	a := &UdmApp{cfg: cfg, udmCtx: udmContext}
+	a.SetLogLevel(cfg.GetLogLevel())
+
+	// the logger, the SUCI profiles and the NRF certificate apply live, the port does not
+	writeConfig(t, path, 8001, "debug", "cert/nrf2.pem", "7")
+	require.NoError(t, a.ReloadConfig(path))
+	require.Equal(t, logrus.DebugLevel, logger.Log.GetLevel())
+	require.Equal(t, 7, udmContext.GetSuciProfiles()[0].PublicKeyId)
+	require.Equal(t, "cert/nrf2.pem", udmContext.GetNrfCertPem())
+	require.Equal(t, 8000, a.Config().GetSbiPort())
+
+	// a file failing validation changes nothing
+	writeConfig(t, path, 8000, "warn", "cert/nrf3.pem", "256")
+	require.Error(t, a.ReloadConfig(path))
+	require.Equal(t, logrus.DebugLevel, logger.Log.GetLevel())
+	require.Equal(t, 7, udmContext.GetSuciProfiles()[0].PublicKeyId)
+	require.Equal(t, "cert/nrf2.pem", udmContext.GetNrfCertPem())
+	require.Equal(t, "cert/nrf2.pem", a.Config().Configuration.NrfCertPem)
+
+	// so does a file failing a validation outside govalidator, instead of crashing the UDM
+	writeConfig(t, path, 8000, "warn", "cert/nrf3.pem", "8")
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	content = []byte(strings.Replace(string(content), "  nrfCertPem:", "  sqn:\n    indLength: 40\n  nrfCertPem:", 1))
+	require.NoError(t, os.WriteFile(path, content, 0o600))
+	require.Error(t, a.ReloadConfig(path))
+	require.Equal(t, logrus.DebugLevel, logger.Log.GetLevel())
+	require.Equal(t, 7, udmContext.GetSuciProfiles()[0].PublicKeyId)
+	require.Equal(t, "cert/nrf2.pem", udmContext.GetNrfCertPem())
+	require.Equal(t, "cert/nrf2.pem", a.Config().Configuration.NrfCertPem)
+	require.Nil(t, a.Config().Configuration.Sqn)
+}
+
+func TestReloadConfigUdmInfo(t *testing.T) {
+	patches := make(chan []models.PatchItem, 1)
+	nrf := httptest.NewServer(h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var patchItems []models.PatchItem
+		if r.Method != http.MethodPatch || r.URL.Path != "/nnrf-nfm/v1/nf-instances/1" ||
+			json.NewDecoder(r.Body).Decode(&patchItems) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		patches <- patchItems
+		w.WriteHeader(http.StatusNoContent)
+	}), &http2.Server{}))
+	defer nrf.Close()
+
+	writeUdmInfoConfig := func(path, udmInfo string) {
+		content := fmt.Sprintf(`info:
+  version: 1.0.3
+configuration:
+  sbi:
+    scheme: http
+    registerIPv4: 127.0.0.3
+    bindingIPv4: 127.0.0.3
+    port: 8000
+  serviceNameList:
+    - nudm-ueau
+  nrfUri: %s
+  routingIndicators:
+    - "0"
+%s
+logger:
+  enable: true
+  level: info
+  reportCaller: false
+`, nrf.URL, udmInfo)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	}
+
+	path := filepath.Join(t.TempDir(), "udmcfg.yaml")
+	writeUdmInfoConfig(path, "")
+	cfg, err := factory.ReadConfig(path)
+	require.NoError(t, err)
+
+	udmContext := &udm_context.UDMContext{NfId: "1"}
+	udmContext.SetNrf(cfg.Configuration.NrfUri, cfg.Configuration.NrfCertPem)
+	udmContext.SetUdmInfo(cfg.Configuration.UdmInfo, cfg.Configuration.RoutingIndicators)
+	a := &UdmApp{cfg: cfg, udmCtx: udmContext, ctx: context.Background()}
+	a.consumer, err = consumer.NewConsumer(a)
+	require.NoError(t, err)
+
+	// an invalid range is rejected
+	writeUdmInfoConfig(path, `  udmInfo:
+    supiRanges:
+      - start: "208930000000009"
+        end: "208930000000000"`)
+	require.Error(t, a.ReloadConfig(path))
+	a.wg.Wait()
+	require.Empty(t, patches)
+
+	writeUdmInfoConfig(path, `  udmInfo:
+    groupId: udm-group-1
+    supiRanges:
+      - start: "208930000000000"
+        end: "208930000000099"
+    gpsiRanges:
+      - pattern: "^msisdn-0900[0-9]{8}$"`)
+	require.NoError(t, a.ReloadConfig(path))
+	a.wg.Wait()
+	require.Len(t, patches, 1)
+	patchItems := <-patches
+	require.Len(t, patchItems, 1)
+	require.Equal(t, models.PatchOperation_REPLACE, patchItems[0].Op)
+	require.Equal(t, "/udmInfo", patchItems[0].Path)
+	value, err := json.Marshal(patchItems[0].Value)
+	require.NoError(t, err)
+	var udmInfo models.UdmInfo
+	require.NoError(t, json.Unmarshal(value, &udmInfo))
+	require.Equal(t, "udm-group-1", udmInfo.GroupId)
+	require.Equal(t, []models.SupiRange{{Start: "208930000000000", End: "208930000000099"}}, udmInfo.SupiRanges)
+	require.Equal(t, []models.IdentityRange{{Pattern: "^msisdn-0900[0-9]{8}$"}}, udmInfo.GpsiRanges)
+	require.Equal(t, []string{"0"}, udmInfo.RoutingIndicators)
+	require.Equal(t, "udm-group-1", a.Config().Configuration.UdmInfo.GroupId)
+
+	// The GroupId is kept when udmInfo configures none
+	writeUdmInfoConfig(path, `  udmInfo:
+    supiRanges:
+      - start: "208930000000000"
+        end: "208930000000199"`)
+	require.NoError(t, a.ReloadConfig(path))
+	a.wg.Wait()
+	require.Len(t, patches, 1)
+	require.Equal(t, "udm-group-1", udmContext.GetUdmInfo().GroupId)
+	require.Equal(t, []models.SupiRange{{Start: "208930000000000", End: "208930000000199"}},
+		udmContext.GetUdmInfo().SupiRanges)
+}
+
+func TestReloadConfigOAuth2(t *testing.T) {
+	var tokenRequests atomic.Int32
+	nrf := httptest.NewServer(h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokenRequests.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		require.NoError(t, json.NewEncoder(w).Encode(models.NrfAccessTokenAccessTokenRsp{
+			AccessToken: "token",
+			TokenType:   "Bearer",
+			ExpiresIn:   60,
+		}))
+	}), &http2.Server{}))
+	defer nrf.Close()
+
+	writeOAuth2Config := func(path string, port int, nrfCertPem string, nfInstanceIdCheck bool) {
+		content := fmt.Sprintf(`info:
+  version: 1.0.3
+configuration:
+  sbi:
+    scheme: https
+    registerIPv4: 127.0.0.3
+    bindingIPv4: 127.0.0.3
+    port: %d
+    tls:
+      pem: cert/udm.pem
+      key: cert/udm.key
+      caPem: cert/ca.pem
+      nfInstanceIdCheck: %t
+  serviceNameList:
+    - nudm-ueau
+  nrfUri: %s
+  nrfCertPem: %s
+logger:
+  enable: true
+  level: info
+  reportCaller: false
+`, port, nfInstanceIdCheck, nrf.URL, nrfCertPem)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	}
+	getToken := func(udmContext *udm_context.UDMContext) {
+		_, _, err := udmContext.GetTokenCtx(models.ServiceName_NUDR_DR, models.NrfNfManagementNfType_UDR)
+		require.NoError(t, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "udmcfg.yaml")
+	writeOAuth2Config(path, 8000, "cert/nrf.pem", false)
+	cfg, err := factory.ReadConfig(path)
+	require.NoError(t, err)
+	udmContext := &udm_context.UDMContext{NfId: "udm-1"}
+	udmContext.SetNrf(cfg.Configuration.NrfUri, cfg.Configuration.NrfCertPem)
+	udmContext.SetOAuth2Required(true)
+	a := &UdmApp{cfg: cfg, udmCtx: udmContext}
+	getToken(udmContext)
+	getToken(udmContext)
+	require.Equal(t, int32(1), tokenRequests.Load())
+
+	// nfInstanceIdCheck applies live, the access tokens are kept
+	writeOAuth2Config(path, 8000, "cert/nrf.pem", true)
+	require.NoError(t, a.ReloadConfig(path))
+	require.True(t, udmContext.NfInstanceIdCheck)
+	require.True(t, a.Config().Configuration.Sbi.Tls.NfInstanceIdCheck)
+	getToken(udmContext)
+	require.Equal(t, int32(1), tokenRequests.Load())
+
+	// The access tokens are requested again after a change of the NRF certificate
+	writeOAuth2Config(path, 8000, "cert/nrf2.pem", true)
+	require.NoError(t, a.ReloadConfig(path))
+	getToken(udmContext)
+	require.Equal(t, int32(2), tokenRequests.Load())
+
+	// nfInstanceIdCheck does not apply along with SBI server settings needing a restart
+	writeOAuth2Config(path, 8001, "cert/nrf2.pem", false)
+	require.NoError(t, a.ReloadConfig(path))
+	require.True(t, udmContext.NfInstanceIdCheck)
+	require.Equal(t, 8000, a.Config().GetSbiPort())
+}
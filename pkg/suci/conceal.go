@@ -0,0 +1,224 @@
+package suci
+
+import (
+	"crypto/ecdh"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	imsiSupiRegex             = regexp.MustCompile(`^imsi-(\d{3})(\d{7,12})$`)
+	naiSupiRegex              = regexp.MustCompile(`^(nai|gci|gli)-([^@]+)@(.+)$`)
+	routingIndicatorOnlyRegex = regexp.MustCompile(`^\d{1,4}$`)
+)
+
+// ConcealOption tunes how FromSupi conceals a SUPI
+type ConcealOption func(*concealOptions)
+
+type concealOptions struct {
+	mncLength             int
+	uncompressedPublicKey bool
+}
+
+// WithMncLength sets the number of digits, 2 or 3, of the MNC of an IMSI; 2 by default
+func WithMncLength(mncLength int) ConcealOption {
+	return func(o *concealOptions) {
+		o.mncLength = mncLength
+	}
+}
+
+// WithUncompressedPublicKey sends the profile B ephemeral public key uncompressed instead of
+// compressed (TS 33.501 C.3.4.2)
+func WithUncompressedPublicKey() ConcealOption {
+	return func(o *concealOptions) {
+		o.uncompressedPublicKey = true
+	}
+}
+
+// FromSupi conceals supi into a SUCI (TS 23.003 2.2B) as a UE does (TS 33.501 6.12.2), with a
+// fresh ephemeral key for the home network public key hnPublicKey of ID keyID. The null scheme
+// takes no key and key ID 0. IMSIs and NAIs may be concealed, GCIs and GLIs only with the null
+// scheme.
+func FromSupi(supi, scheme, hnPublicKey string, keyID int, routingIndicator string,
+	opts ...ConcealOption,
+) (string, error) {
+	options := concealOptions{mncLength: 2}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	scheme = strings.ToUpper(scheme)
+	if !routingIndicatorOnlyRegex.MatchString(routingIndicator) {
+		return "", fmt.Errorf("routing indicator [%s] should be 1-4 digits", routingIndicator)
+	}
+	if scheme == NullScheme {
+		if keyID != 0 {
+			return "", fmt.Errorf("keyID (%d) should be 0 for the null scheme", keyID)
+		}
+	} else if keyID < 1 || keyID > 255 {
+		return "", fmt.Errorf("keyID (%d) out of range (1-255)", keyID)
+	}
+
+	var supiType, homeNetworkId, msinOrUsername string
+	var plainText []byte
+	if matches := imsiSupiRegex.FindStringSubmatch(supi); matches != nil {
+		if options.mncLength != 2 && options.mncLength != 3 {
+			return "", fmt.Errorf("MNC length (%d) should be 2 or 3", options.mncLength)
+		}
+		mnc, msin := matches[2][:options.mncLength], matches[2][options.mncLength:]
+		supiType, homeNetworkId, msinOrUsername = SupiTypeIMSI, matches[1]+"-"+mnc, msin
+		if len(msin)%2 == 1 {
+			msin += "f"
+		}
+		bcd, err := hex.DecodeString(msin)
+		if err != nil {
+			return "", fmt.Errorf("invalid MSIN [%s]: %w", msinOrUsername, err)
+		}
+		plainText = swapNibbles(bcd)
+	} else if matches := naiSupiRegex.FindStringSubmatch(supi); matches != nil {
+		switch matches[1] {
+		case "gci":
+			supiType = SupiTypeGCI
+		case "gli":
+			supiType = SupiTypeGLI
+		default:
+			supiType = SupiTypeNAI
+		}
+		if supiType != SupiTypeNAI && scheme != NullScheme {
+			return "", fmt.Errorf("SUPI [%s] may only be concealed with the null scheme", supi)
+		}
+		homeNetworkId, msinOrUsername = matches[3], matches[2]
+		plainText = []byte(msinOrUsername)
+	} else {
+		return "", fmt.Errorf("unsupported supi [%s]", supi)
+	}
+
+	var schemeOutput []byte
+	var err error
+	switch scheme {
+	case NullScheme:
+		prefix := fmt.Sprintf("suci-%s-%s-%s-%s-0-", supiType, homeNetworkId, routingIndicator, scheme)
+		return prefix + msinOrUsername, nil
+	case ProfileAScheme:
+		schemeOutput, err = concealProfileA(plainText, hnPublicKey)
+	case ProfileBScheme:
+		schemeOutput, err = concealProfileB(plainText, hnPublicKey, !options.uncompressedPublicKey)
+	case ProfileMlKemScheme:
+		schemeOutput, err = concealProfileMlKem(plainText, hnPublicKey)
+	default:
+		return "", fmt.Errorf("protect Scheme (%s) is not supported", scheme)
+	}
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("suci-%s-%s-%s-%s-%d-%x", supiType, homeNetworkId, routingIndicator, scheme, keyID,
+		schemeOutput), nil
+}
+
+func encryptWithKdf(sharedKey, kdfPubKey, plainText []byte,
+	encKeyLen, macKeyLen, hashLen, icbLen, macLen int,
+) ([]byte, error) {
+	kdfKey := AnsiX963KDF(sharedKey, kdfPubKey, encKeyLen, macKeyLen, hashLen)
+	encKey := kdfKey[:encKeyLen]
+	icb := kdfKey[encKeyLen : encKeyLen+icbLen]
+	macKey := kdfKey[len(kdfKey)-macKeyLen:]
+
+	cipherText, err := Aes128ctr(plainText, encKey, icb)
+	if err != nil {
+		return nil, err
+	}
+	mac, err := HmacSha256(cipherText, macKey, macLen)
+	if err != nil {
+		return nil, err
+	}
+	return append(cipherText, mac...), nil
+}
+
+func concealProfileA(plainText []byte, hnPublicKey string) ([]byte, error) {
+	hnPubBytes, err := hex.DecodeString(hnPublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode public key: %w", err)
+	}
+	hnPub, err := ecdh.X25519().NewPublicKey(hnPubBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse X25519 public key: %w", err)
+	}
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate ephemeral key: %w", err)
+	}
+	sharedKey, err := ephemeral.ECDH(hnPub)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute ECDH: %w", err)
+	}
+
+	ephemeralPub := ephemeral.PublicKey().Bytes()
+	encrypted, err := encryptWithKdf(sharedKey, ephemeralPub, plainText,
+		ProfileAEncKeyLen, ProfileAMacKeyLen, ProfileAHashLen, ProfileAIcbLen, ProfileAMacLen)
+	if err != nil {
+		return nil, err
+	}
+	return append(ephemeralPub, encrypted...), nil
+}
+
+func concealProfileB(plainText []byte, hnPublicKey string, compressed bool) ([]byte, error) {
+	hnPubBytes, err := hex.DecodeString(hnPublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode public key: %w", err)
+	}
+	if len(hnPubBytes) > 0 && (hnPubBytes[0] == 0x02 || hnPubBytes[0] == 0x03) {
+		x, y := elliptic.UnmarshalCompressed(elliptic.P256(), hnPubBytes)
+		if x == nil || y == nil {
+			return nil, fmt.Errorf("failed to uncompress public key")
+		}
+		hnPubBytes = elliptic.Marshal(elliptic.P256(), x, y)
+	}
+	hnPub, err := ecdh.P256().NewPublicKey(hnPubBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create P-256 public key: %w", err)
+	}
+	ephemeral, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate ephemeral key: %w", err)
+	}
+	sharedKey, err := ephemeral.ECDH(hnPub)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute ECDH: %w", err)
+	}
+
+	// The KDF always takes the compressed ephemeral public key
+	ephemeralPub := ephemeral.PublicKey().Bytes()
+	x, y := elliptic.Unmarshal(elliptic.P256(), ephemeralPub)
+	kdfPubKey := elliptic.MarshalCompressed(elliptic.P256(), x, y)
+	if compressed {
+		ephemeralPub = kdfPubKey
+	}
+	encrypted, err := encryptWithKdf(sharedKey, kdfPubKey, plainText,
+		ProfileBEncKeyLen, ProfileBMacKeyLen, ProfileBHashLen, ProfileBIcbLen, ProfileBMacLen)
+	if err != nil {
+		return nil, err
+	}
+	return append(ephemeralPub, encrypted...), nil
+}
+
+func concealProfileMlKem(plainText []byte, hnPublicKey string) ([]byte, error) {
+	hnPubBytes, err := hex.DecodeString(hnPublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode public key: %w", err)
+	}
+	sharedKey, kemCipherText, err := mlKemEncapsulate(hnPubBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted, err := encryptWithKdf(sharedKey, kemCipherText, plainText,
+		ProfileMlKemEncKeyLen, ProfileMlKemMacKeyLen, ProfileMlKemHashLen, ProfileMlKemIcbLen, ProfileMlKemMacLen)
+	if err != nil {
+		return nil, err
+	}
+	return append(kemCipherText, encrypted...), nil
+}
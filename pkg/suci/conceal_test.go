@@ -0,0 +1,223 @@
+package suci
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/quick"
+)
+
+// concealInput is a random SUPI with the routing indicator and options of its SUCI
+type concealInput struct {
+	supi             string
+	routingIndicator string
+	opts             []ConcealOption
+}
+
+func randomDigits(r *rand.Rand, n int) string {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		b.WriteByte(byte('0' + r.Intn(10)))
+	}
+	return b.String()
+}
+
+func (concealInput) Generate(r *rand.Rand, _ int) reflect.Value {
+	in := concealInput{routingIndicator: randomDigits(r, 1+r.Intn(4))}
+	if r.Intn(2) == 0 {
+		mncLength := 2 + r.Intn(2)
+		// IMSIs are at most 15 digits long
+		in.supi = PrefixIMSI + randomDigits(r, 3+mncLength+5+r.Intn(8-mncLength))
+		in.opts = append(in.opts, WithMncLength(mncLength))
+	} else {
+		// any UTF-8 username but the realm separator
+		username := []rune{}
+		for i := 0; i < 1+r.Intn(32); i++ {
+			if c := rune(0x21 + r.Intn(0x250)); c != '@' {
+				username = append(username, c)
+			}
+		}
+		if len(username) == 0 {
+			username = append(username, 'u')
+		}
+		in.supi = PrefixNAI + string(username) + "@" + fmt.Sprintf("realm%d.example.com", r.Intn(100))
+	}
+	if r.Intn(2) == 0 {
+		in.opts = append(in.opts, WithUncompressedPublicKey())
+	}
+	return reflect.ValueOf(in)
+}
+
+func TestFromSupiRoundTrip(t *testing.T) {
+	suciProfiles := []SuciProfile{
+		{
+			ProtectionScheme: ProfileAScheme,
+			PublicKeyId:      1,
+			PrivateKey:       "c53c22208b61860b06c62e5406a7b330c2b577aa5558981510d128247d38bd1d",
+			PublicKey:        "5a8d38864820197c3394b92613b20b91633cbd897119273bf8e4a6f4eec0a650",
+		},
+		{
+			ProtectionScheme: ProfileBScheme,
+			PublicKeyId:      2,
+			PrivateKey:       "F1AB1074477EBCC7F554EA1C5FC368B1616730155E0041AC447D6301975FECDA",
+			PublicKey:        "0272DA71976234CE833A6907425867B82E074D44EF907DFB4B3E21C1C2256EBCD1",
+		},
+		{
+			ProtectionScheme: ProfileBScheme,
+			PublicKeyId:      3,
+			PrivateKey:       "F1AB1074477EBCC7F554EA1C5FC368B1616730155E0041AC447D6301975FECDA",
+			PublicKey: "0472DA71976234CE833A6907425867B82E074D44EF907DFB4B3E21C1C2256EBCD" +
+				"15A7DED52FCBB097A4ED250E036C7B9C8C7004C4EEDC4F068CD7BF8D3F900E3B4",
+		},
+	}
+	// the ML-KEM scheme is only supported by builds with Go 1.24 or later
+	seed := make([]byte, 64)
+	for i := range seed {
+		seed[i] = byte(0xff - i)
+	}
+	encapsulationKey, err := mlKemEncapsulationKey(seed)
+	if err == nil {
+		suciProfiles = append(suciProfiles, SuciProfile{
+			ProtectionScheme: ProfileMlKemScheme,
+			PublicKeyId:      4,
+			PrivateKey:       hex.EncodeToString(seed),
+			PublicKey:        hex.EncodeToString(encapsulationKey),
+		})
+	} else if !errors.Is(err, ErrUnsupportedProtectionScheme) {
+		t.Fatalf("mlKemEncapsulationKey error: %+v", err)
+	}
+
+	t.Run("null scheme", func(t *testing.T) {
+		roundTrip := func(in concealInput) bool {
+			suci, err := FromSupi(in.supi, NullScheme, "", 0, in.routingIndicator, in.opts...)
+			if err != nil {
+				t.Logf("FromSupi(%s) error: %+v", in.supi, err)
+				return false
+			}
+			supi, err := ToSupi(suci, suciProfiles)
+			return err == nil && supi == in.supi
+		}
+		if err := quick.Check(roundTrip, nil); err != nil {
+			t.Error(err)
+		}
+	})
+	for _, profile := range suciProfiles {
+		t.Run(fmt.Sprintf("scheme %s key %d", profile.ProtectionScheme, profile.PublicKeyId), func(t *testing.T) {
+			roundTrip := func(in concealInput) bool {
+				suci, err := FromSupi(in.supi, profile.ProtectionScheme, profile.PublicKey, profile.PublicKeyId,
+					in.routingIndicator, in.opts...)
+				if err != nil {
+					t.Logf("FromSupi(%s) error: %+v", in.supi, err)
+					return false
+				}
+				supi, err := ToSupi(suci, suciProfiles)
+				return err == nil && supi == in.supi
+			}
+			if err := quick.Check(roundTrip, &quick.Config{MaxCount: 50}); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestFromSupi(t *testing.T) {
+	profileBPublicKey := "0272DA71976234CE833A6907425867B82E074D44EF907DFB4B3E21C1C2256EBCD1"
+	testCases := []struct {
+		name         string
+		supi         string
+		scheme       string
+		keyID        int
+		opts         []ConcealOption
+		expectPrefix string
+		// length of the hex scheme output
+		expectOutputLen int
+		expectErr       bool
+	}{
+		{
+			name:         "null scheme IMSI with a 3-digit MNC",
+			supi:         "imsi-310410123456789",
+			scheme:       NullScheme,
+			opts:         []ConcealOption{WithMncLength(3)},
+			expectPrefix: "suci-0-310-410-0-0-0-123456789",
+		},
+		{
+			name:         "null scheme GLI",
+			supi:         "gli-line-0001@wireline.example.com",
+			scheme:       NullScheme,
+			expectPrefix: "suci-3-wireline.example.com-0-0-0-line-0001",
+		},
+		{
+			name:         "profile B compressed",
+			supi:         "imsi-208930123456789",
+			scheme:       ProfileBScheme,
+			keyID:        2,
+			expectPrefix: "suci-0-208-93-0-2-2-0",
+			// 33 octets of public key, 5 of MSIN and 8 of MAC
+			expectOutputLen: 2 * (33 + 5 + 8),
+		},
+		{
+			name:         "profile B uncompressed",
+			supi:         "imsi-208930123456789",
+			scheme:       ProfileBScheme,
+			keyID:        2,
+			opts:         []ConcealOption{WithUncompressedPublicKey()},
+			expectPrefix: "suci-0-208-93-0-2-2-04",
+			// 65 octets of public key, 5 of MSIN and 8 of MAC
+			expectOutputLen: 2 * (65 + 5 + 8),
+		},
+		{
+			name:      "GCI with a profile",
+			supi:      "gci-00a0bc123456@cable.example.com",
+			scheme:    ProfileBScheme,
+			keyID:     2,
+			expectErr: true,
+		},
+		{
+			name:      "null scheme with a key ID",
+			supi:      "imsi-208930123456789",
+			scheme:    NullScheme,
+			keyID:     1,
+			expectErr: true,
+		},
+		{
+			name:      "key ID out of range",
+			supi:      "imsi-208930123456789",
+			scheme:    ProfileBScheme,
+			keyID:     256,
+			expectErr: true,
+		},
+		{
+			name:      "unsupported scheme",
+			supi:      "imsi-208930123456789",
+			scheme:    "5",
+			keyID:     1,
+			expectErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		suci, err := FromSupi(tc.supi, tc.scheme, profileBPublicKey, tc.keyID, "0", tc.opts...)
+		if tc.expectErr {
+			if err == nil {
+				t.Errorf("%s fail: expected error", tc.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s fail: err[%v]", tc.name, err)
+			continue
+		}
+		if !strings.HasPrefix(suci, tc.expectPrefix) {
+			t.Errorf("%s fail: suci[%s], expected prefix[%s]", tc.name, suci, tc.expectPrefix)
+		}
+		if tc.expectOutputLen != 0 {
+			output := suci[strings.LastIndex(suci, "-")+1:]
+			if len(output) != tc.expectOutputLen {
+				t.Errorf("%s fail: scheme output length[%d], expected[%d]", tc.name, len(output), tc.expectOutputLen)
+			}
+		}
+	}
+}
@@ -0,0 +1,47 @@
+//go:build go1.24
+
+package suci
+
+import (
+	"crypto/mlkem"
+	"fmt"
+)
+
+// mlKemDecapsulate recovers the shared secret of the ML-KEM-768 ciphertext with the decapsulation
+// key of seed
+func mlKemDecapsulate(seed, cipherText []byte) ([]byte, error) {
+	dk, err := mlkem.NewDecapsulationKey768(seed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ML-KEM-768 private key: %w", err)
+	}
+	return dk.Decapsulate(cipherText)
+}
+
+// mlKemEncapsulate returns a shared secret and its ML-KEM-768 ciphertext for the encapsulation key
+func mlKemEncapsulate(encapsulationKey []byte) (sharedKey, cipherText []byte, err error) {
+	ek, err := mlkem.NewEncapsulationKey768(encapsulationKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse ML-KEM-768 public key: %w", err)
+	}
+	sharedKey, cipherText = ek.Encapsulate()
+	return sharedKey, cipherText, nil
+}
+
+// mlKemEncapsulationKey returns the encapsulation key of the ML-KEM-768 decapsulation key of seed
+func mlKemEncapsulationKey(seed []byte) ([]byte, error) {
+	dk, err := mlkem.NewDecapsulationKey768(seed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ML-KEM-768 private key: %w", err)
+	}
+	return dk.EncapsulationKey().Bytes(), nil
+}
+
+// GenerateMlKemKey generates an ML-KEM-768 key pair: the seed of the decapsulation key, which is
+// the private key of the SUCI profile, and the encapsulation key, its public key
+func GenerateMlKemKey() (seed, encapsulationKey []byte, err error) {
+	dk, err := mlkem.GenerateKey768()
+	if err != nil {
+		return nil, nil, err
+	}
+	return dk.Bytes(), dk.EncapsulationKey().Bytes(), nil
+}
@@ -0,0 +1,122 @@
+//go:build go1.24
+
+package suci
+
+import (
+	"crypto/mlkem"
+	"encoding/hex"
+	"testing"
+)
+
+// concealMlKem conceals plainText for the ML-KEM-768 encapsulation key ek
+func concealMlKem(t *testing.T, ek *mlkem.EncapsulationKey768, plainText []byte) string {
+	t.Helper()
+	sharedKey, kemCipherText := ek.Encapsulate()
+	kdfKey := AnsiX963KDF(sharedKey, kemCipherText, ProfileMlKemEncKeyLen, ProfileMlKemMacKeyLen, ProfileMlKemHashLen)
+	encKey := kdfKey[:ProfileMlKemEncKeyLen]
+	icb := kdfKey[ProfileMlKemEncKeyLen : ProfileMlKemEncKeyLen+ProfileMlKemIcbLen]
+	macKey := kdfKey[len(kdfKey)-ProfileMlKemMacKeyLen:]
+
+	cipherText, err := Aes128ctr(plainText, encKey, icb)
+	if err != nil {
+		t.Fatalf("Aes128ctr error: %+v", err)
+	}
+	mac, err := HmacSha256(cipherText, macKey, ProfileMlKemMacLen)
+	if err != nil {
+		t.Fatalf("HmacSha256 error: %+v", err)
+	}
+	return hex.EncodeToString(append(append(kemCipherText, cipherText...), mac...))
+}
+
+func TestToSupiMlKem(t *testing.T) {
+	seed := make([]byte, mlkem.SeedSize)
+	for i := range seed {
+		seed[i] = byte(i)
+	}
+	dk, err := mlkem.NewDecapsulationKey768(seed)
+	if err != nil {
+		t.Fatalf("NewDecapsulationKey768 error: %+v", err)
+	}
+	otherSeed := make([]byte, mlkem.SeedSize)
+	otherDk, err := mlkem.NewDecapsulationKey768(otherSeed)
+	if err != nil {
+		t.Fatalf("NewDecapsulationKey768 error: %+v", err)
+	}
+	suciProfiles := []SuciProfile{
+		{
+			ProtectionScheme: ProfileMlKemScheme,
+			PublicKeyId:      7,
+			PrivateKey:       hex.EncodeToString(seed),
+			PublicKey:        hex.EncodeToString(dk.EncapsulationKey().Bytes()),
+		},
+	}
+	// MSIN 0123456789 and 001002086 in BCD with swapped nibbles, padded with F
+	evenMsin := mustDecodeHexString(t, "1032547698")
+	oddMsin := mustDecodeHexString(t, "00012080f6")
+	tampered := mustDecodeHexString(t, concealMlKem(t, dk.EncapsulationKey(), evenMsin))
+	tampered[len(tampered)-1] ^= 0x01
+
+	testCases := []struct {
+		name         string
+		suci         string
+		expectedSupi string
+		expectErr    bool
+	}{
+		{
+			name:         "IMSI",
+			suci:         "suci-0-208-93-0-C-7-" + concealMlKem(t, dk.EncapsulationKey(), evenMsin),
+			expectedSupi: "imsi-208930123456789",
+		},
+		{
+			name:         "IMSI with odd MSIN length, lowercase scheme",
+			suci:         "suci-0-208-93-0-c-7-" + concealMlKem(t, dk.EncapsulationKey(), oddMsin),
+			expectedSupi: "imsi-20893001002086",
+		},
+		{
+			name:         "NAI",
+			suci:         "suci-1-iot.example.com-0-C-7-" + concealMlKem(t, dk.EncapsulationKey(), []byte("sensor-17")),
+			expectedSupi: "nai-sensor-17@iot.example.com",
+		},
+		{
+			name:      "tampered MAC",
+			suci:      "suci-0-208-93-0-C-7-" + hex.EncodeToString(tampered),
+			expectErr: true,
+		},
+		{
+			name:      "concealed for another key",
+			suci:      "suci-0-208-93-0-C-7-" + concealMlKem(t, otherDk.EncapsulationKey(), evenMsin),
+			expectErr: true,
+		},
+		{
+			name:      "unknown public key ID",
+			suci:      "suci-0-208-93-0-C-8-" + concealMlKem(t, dk.EncapsulationKey(), evenMsin),
+			expectErr: true,
+		},
+		{
+			name:      "truncated KEM ciphertext",
+			suci:      "suci-0-208-93-0-C-7-" + concealMlKem(t, dk.EncapsulationKey(), evenMsin)[:2*mlkem.CiphertextSize768],
+			expectErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		supi, err := ToSupi(tc.suci, suciProfiles)
+		if tc.expectErr {
+			if err == nil {
+				t.Errorf("%s fail: expected error", tc.name)
+			}
+		} else if err != nil {
+			t.Errorf("%s fail: err[%v]", tc.name, err)
+		} else if supi != tc.expectedSupi {
+			t.Errorf("%s fail: supi[%s], expected[%s]", tc.name, supi, tc.expectedSupi)
+		}
+	}
+}
+
+func mustDecodeHexString(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatalf("hex decode error: %+v", err)
+	}
+	return b
+}
@@ -0,0 +1,25 @@
+//go:build !go1.24
+
+package suci
+
+import "fmt"
+
+// crypto/mlkem comes with Go 1.24, the ML-KEM scheme is not supported by builds with older versions
+var errMlKemUnsupported = fmt.Errorf("ML-KEM requires a build with Go 1.24 or later: %w",
+	ErrUnsupportedProtectionScheme)
+
+func mlKemDecapsulate(seed, cipherText []byte) ([]byte, error) {
+	return nil, errMlKemUnsupported
+}
+
+func mlKemEncapsulate(encapsulationKey []byte) (sharedKey, cipherText []byte, err error) {
+	return nil, nil, errMlKemUnsupported
+}
+
+func mlKemEncapsulationKey(seed []byte) ([]byte, error) {
+	return nil, errMlKemUnsupported
+}
+
+func GenerateMlKemKey() (seed, encapsulationKey []byte, err error) {
+	return nil, nil, errMlKemUnsupported
+}
@@ -13,22 +13,33 @@ import (
 	"math"
 	"math/bits"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/free5gc/udm/internal/logger"
+	"github.com/free5gc/udm/pkg/keyprovider"
 )
 
 // suci-0(SUPI type: IMSI)-mcc-mnc-routingIndicator-protectionScheme-homeNetworkPublicKeyID-schemeOutput.
-// TODO: suci-1(SUPI type: NAI)-homeNetworkID-routingIndicator-protectionScheme-homeNetworkPublicKeyID-schemeOutput.
+// suci-1(SUPI type: NAI)-homeNetworkID-routingIndicator-protectionScheme-homeNetworkPublicKeyID-schemeOutput.
 
 const (
 	PrefixIMSI     = "imsi-"
+	PrefixNAI      = "nai-"
+	PrefixGCI      = "gci-"
+	PrefixGLI      = "gli-"
 	PrefixSUCI     = "suci"
 	SupiTypeIMSI   = "0"
+	SupiTypeNAI    = "1"
+	SupiTypeGCI    = "2"
+	SupiTypeGLI    = "3"
 	NullScheme     = "0"
 	ProfileAScheme = "1"
 	ProfileBScheme = "2"
+	// Operator-specific scheme concealing the SUPI with ML-KEM-768 (TS 33.501 Annex C.1)
+	ProfileMlKemScheme = "C"
 )
 
 var (
@@ -44,7 +55,7 @@ var (
 	// The Home Network Identifier consists of a string of
 	// characters with a variable length representing a domain name
 	// as specified in Section 2.2 of RFC 7542
-	naiTypeRegex = "(?P<naiType>1-.*)"
+	naiTypeRegex = "(?P<naiType>1-(?P<home_network_id>.+?))"
 
 	// SUPI type; 0 = IMSI, 1 = NAI (for n3gpp)
 	supiTypeRegex = fmt.Sprintf("(?P<supi_type>%s|%s)",
@@ -53,8 +64,9 @@ var (
 
 	// Routing Indicator, used by the AUSF to find the appropriate UDM when SUCI is encrypted 1-4 digits
 	routingIndicatorRegex = `(?P<routing_indicator>\d{1,4})`
-	// Protection Scheme ID; 0 = NULL Scheme (unencrypted), 1 = Profile A, 2 = Profile B
-	protectionSchemeRegex = `(?P<protection_scheme_id>(?:[0-2]))`
+	// Protection Scheme ID; 0 = NULL Scheme (unencrypted), 1 = Profile A, 2 = Profile B, C = ML-KEM profile,
+	// others are reserved or operator-specific
+	protectionSchemeRegex = `(?P<protection_scheme_id>(?:[0-9a-fA-F]))`
 	// Public Key ID; 1-255
 	publicKeyIDRegex = `(?P<public_key_id>(?:\d{1,2}|1\d{2}|2[0-4]\d|25[0-5]))`
 	// Scheme Output; unbounded hex string (safe from ReDoS due to bounded length of SUCI)
@@ -67,8 +79,29 @@ var (
 		publicKeyIDRegex,
 		schemeOutputRegex,
 	))
+	// SUCI of a NAI, GCI or GLI with the null scheme (TS 23.003 2.2B, 28.15 and 28.16); the scheme
+	// output is the username of the NAI of the SUPI and the home network identifier its realm
+	nullSchemeNaiSuciRegex = regexp.MustCompile(
+		`^suci-(?P<supi_type>[123])-(?P<home_network_id>.+?)-` + routingIndicatorRegex + `-0-0-(?P<username>.+)$`)
 )
 
+// naiSupiPrefixes are the SUPI prefixes of the SUPI types in the NAI format
+var naiSupiPrefixes = map[string]string{
+	SupiTypeNAI: PrefixNAI,
+	SupiTypeGCI: PrefixGCI,
+	SupiTypeGLI: PrefixGLI,
+}
+
+// nullSchemeNaiToSupi returns the NAI, GCI or GLI SUPI of a null scheme SUCI, or "" when suci
+// is not one
+func nullSchemeNaiToSupi(suci string) string {
+	matches := nullSchemeNaiSuciRegex.FindStringSubmatch(suci)
+	if matches == nil {
+		return ""
+	}
+	return naiSupiPrefixes[matches[1]] + matches[4] + "@" + matches[2]
+}
+
 type Suci struct {
 	SupiType         string // 0 for IMSI, 1 for NAI
 	Mcc              string // 3 digits
@@ -82,27 +115,125 @@ type Suci struct {
 
 func parseSuci(input string) *Suci {
 	matches := suciRegex.FindStringSubmatch(input)
-	if matches == nil || len(matches) != 10 {
+	if matches == nil || len(matches) != 11 {
 		return nil
 	}
 
 	// The indices correspond to the order of the regex groups in the pattern
 	return &Suci{
-		SupiType:         matches[1], // First capture group
-		Mcc:              matches[3], // Third capture group
-		Mnc:              matches[4], // Fourth capture group
-		HomeNetworkId:    matches[5], // Fifth capture group
-		RoutingIndicator: matches[6], // Sixth capture group
-		ProtectionScheme: matches[7], // Seventh capture group
-		PublicKeyID:      matches[8], // Eighth capture group
-		SchemeOutput:     matches[9], // Ninth capture group
+		SupiType:         matches[1],  // First capture group
+		Mcc:              matches[3],  // Third capture group
+		Mnc:              matches[4],  // Fourth capture group
+		HomeNetworkId:    matches[6],  // Sixth capture group
+		RoutingIndicator: matches[7],  // Seventh capture group
+		ProtectionScheme: matches[8],  // Eighth capture group
+		PublicKeyID:      matches[9],  // Ninth capture group
+		SchemeOutput:     matches[10], // Tenth capture group
 	}
 }
 
+// supi builds the SUPI of the SUCI from the de-concealed MSIN or username
+func (s *Suci) supi(supiType, msinOrUsername string) (string, error) {
+	if supiType != SupiTypeNAI {
+		return PrefixIMSI + s.Mcc + s.Mnc + msinOrUsername, nil
+	}
+	if msinOrUsername == "" || !utf8.ValidString(msinOrUsername) || strings.Contains(msinOrUsername, "@") {
+		return "", fmt.Errorf("invalid NAI username %q", msinOrUsername)
+	}
+	return PrefixNAI + msinOrUsername + "@" + s.HomeNetworkId, nil
+}
+
 type SuciProfile struct {
 	ProtectionScheme string `yaml:"ProtectionScheme,omitempty"`
-	PrivateKey       string `yaml:"PrivateKey,omitempty"`
-	PublicKey        string `yaml:"PublicKey,omitempty"`
+	// Home network public key ID of the key, 1-255; the 1-based position of the profile in the list
+	// when omitted
+	PublicKeyId int    `yaml:"PublicKeyId,omitempty"`
+	PrivateKey  string `yaml:"PrivateKey,omitempty"`
+	// Reference of the private key in the KeyProvider, instead of PrivateKey
+	PrivateKeyRef string `yaml:"PrivateKeyRef,omitempty"`
+	PublicKey     string `yaml:"PublicKey,omitempty"`
+
+	KeyProvider keyprovider.KeyProvider `yaml:"-"`
+}
+
+// GetPublicKeyId returns the home network public key ID of the profile at index of the profile list
+func (p *SuciProfile) GetPublicKeyId(index int) int {
+	if p.PublicKeyId != 0 {
+		return p.PublicKeyId
+	}
+	return index + 1
+}
+
+// CheckServed checks that suci is for one of the served PLMNs, as MCC followed by MNC, and one
+// of the served routing indicators. The home network of NAI-based SUCIs, being a realm, is not
+// checked. Empty lists serve any PLMN or routing indicator, and SUPIs are left to ToSupi.
+func CheckServed(suci string, plmnIds, routingIndicators []string) error {
+	var supiType, plmnId, routingIndicator string
+	if parsedSuci := parseSuci(suci); parsedSuci != nil {
+		supiType = parsedSuci.SupiType[:1]
+		plmnId = parsedSuci.Mcc + parsedSuci.Mnc
+		routingIndicator = parsedSuci.RoutingIndicator
+	} else if matches := nullSchemeNaiSuciRegex.FindStringSubmatch(suci); matches != nil {
+		supiType = matches[1]
+		routingIndicator = matches[3]
+	} else {
+		return nil
+	}
+
+	if supiType == SupiTypeIMSI && len(plmnIds) > 0 && !slices.Contains(plmnIds, plmnId) {
+		return fmt.Errorf("PLMN [%s] of suci [%s]: %w", plmnId, suci, ErrHomeNetworkNotServed)
+	}
+	if len(routingIndicators) > 0 && !slices.Contains(routingIndicators, routingIndicator) {
+		return fmt.Errorf("routing indicator [%s] of suci [%s]: %w", routingIndicator, suci,
+			ErrRoutingIndicatorNotServed)
+	}
+	return nil
+}
+
+// FindProfile returns the profile of the home network key with publicKeyId for scheme. Several
+// generations of keys of a scheme are told apart by their ID.
+func FindProfile(suciProfiles []SuciProfile, scheme string, publicKeyId int) (*SuciProfile, error) {
+	for i := range suciProfiles {
+		profile := &suciProfiles[i]
+		if profile.GetPublicKeyId(i) == publicKeyId && profile.ProtectionScheme == scheme {
+			return profile, nil
+		}
+	}
+	return nil, fmt.Errorf("no home network key with ID (%d) for protect Scheme (%s)", publicKeyId, scheme)
+}
+
+// ecdh computes the shared secret of the home network private key of the profile and peer
+func (p *SuciProfile) ecdh(peer *ecdh.PublicKey) ([]byte, error) {
+	if p.PrivateKeyRef != "" {
+		if p.KeyProvider == nil {
+			return nil, fmt.Errorf("no key provider for private key [%s]", p.PrivateKeyRef)
+		}
+		return p.KeyProvider.ECDH(p.PrivateKeyRef, peer)
+	}
+
+	privBytes, err := hex.DecodeString(p.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode private key: %w", err)
+	}
+	priv, err := peer.Curve().NewPrivateKey(privBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+	return priv.ECDH(peer)
+}
+
+// decapsulate recovers the shared secret of the ML-KEM-768 ciphertext with the home network
+// private key of the profile
+func (p *SuciProfile) decapsulate(cipherText []byte) ([]byte, error) {
+	if p.PrivateKeyRef != "" {
+		return nil, fmt.Errorf("private key [%s]: key providers do not support ML-KEM", p.PrivateKeyRef)
+	}
+
+	seed, err := hex.DecodeString(p.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode private key: %w", err)
+	}
+	return mlKemDecapsulate(seed, cipherText)
 }
 
 // profile A.
@@ -123,6 +254,18 @@ const (
 	ProfileBHashLen   = 32 // octets
 )
 
+// ML-KEM profile; the KEM ciphertext takes the place of the ephemeral public key of the ECIES
+// profiles, the KDF, encryption and MAC are those of profile A.
+const (
+	ProfileMlKemMacKeyLen = 32 // octets
+	ProfileMlKemEncKeyLen = 16 // octets
+	ProfileMlKemIcbLen    = 16 // octets
+	ProfileMlKemMacLen    = 8  // octets
+	ProfileMlKemHashLen   = 32 // octets
+	// ML-KEM-768 ciphertext
+	ProfileMlKemCipherTextLen = 1088 // octets
+)
+
 func HmacSha256(input, macKey []byte, macLen int) ([]byte, error) {
 	h := hmac.New(sha256.New, macKey)
 	if _, err := h.Write(input); err != nil {
@@ -167,12 +310,16 @@ func swapNibbles(input []byte) []byte {
 
 func calcSchemeResult(decryptPlainText []byte, supiType string) string {
 	var result string
-	if supiType == SupiTypeIMSI {
+	switch supiType {
+	case SupiTypeIMSI:
 		result = hex.EncodeToString(swapNibbles(decryptPlainText))
 		if len(result) > 0 && result[len(result)-1] == 'f' {
 			result = result[:len(result)-1]
 		}
-	} else {
+	case SupiTypeNAI:
+		// The username of a NAI is encrypted as is, without BCD encoding (TS 33.501 C.3.2)
+		result = string(decryptPlainText)
+	default:
 		result = hex.EncodeToString(decryptPlainText)
 	}
 	return result
@@ -198,36 +345,26 @@ func decryptWithKdf(sharedKey, kdfPubKey, cipherText, providedMac []byte,
 	return Aes128ctr(cipherText, encKey, icb)
 }
 
-func ecdhX25519(privateKeyHex string, peerPubKey []byte) ([]byte, error) {
-	aHNPrivBytes, err := hex.DecodeString(privateKeyHex)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decode X25519 private key: %w", err)
-	}
-	x25519Curve := ecdh.X25519()
-	priv, err := x25519Curve.NewPrivateKey(aHNPrivBytes)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse X25519 private key: %w", err)
-	}
-	pub, err := x25519Curve.NewPublicKey(peerPubKey)
+func ecdhX25519(profile *SuciProfile, peerPubKey []byte) ([]byte, error) {
+	pub, err := ecdh.X25519().NewPublicKey(peerPubKey)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse X25519 public key: %w", err)
 	}
-	return priv.ECDH(pub)
+	return profile.ecdh(pub)
 }
 
-var ErrorPublicKeyUnmarshalling = fmt.Errorf("failed to unmarshal uncompressed public key")
-
-func ecdhP256(privateKeyHex string, transmittedPubKey []byte) (sharedKey, kdfPubKey []byte, err error) {
-	bHNPrivBytes, err := hex.DecodeString(privateKeyHex)
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to decode P-256 private key: %w", err)
-	}
-	p256Curve := ecdh.P256()
-	priv, err := p256Curve.NewPrivateKey(bHNPrivBytes)
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to parse P-256 private key: %w", err)
-	}
+var (
+	ErrorPublicKeyUnmarshalling = fmt.Errorf("failed to unmarshal uncompressed public key")
+	// ErrUnsupportedProtectionScheme is returned for SUCIs of a protection scheme the UDM does not
+	// implement
+	ErrUnsupportedProtectionScheme = fmt.Errorf("unsupported protection scheme")
+	// ErrHomeNetworkNotServed and ErrRoutingIndicatorNotServed are returned by CheckServed for
+	// SUCIs the UDM is not configured to serve
+	ErrHomeNetworkNotServed      = fmt.Errorf("home network not served")
+	ErrRoutingIndicatorNotServed = fmt.Errorf("routing indicator not served")
+)
 
+func ecdhP256(profile *SuciProfile, transmittedPubKey []byte) (sharedKey, kdfPubKey []byte, err error) {
 	var pubKeyForECDH []byte
 	switch transmittedPubKey[0] {
 	case 0x02, 0x03:
@@ -253,12 +390,12 @@ func ecdhP256(privateKeyHex string, transmittedPubKey []byte) (sharedKey, kdfPub
 		return nil, nil, fmt.Errorf("unknown public key format")
 	}
 
-	pub, err := p256Curve.NewPublicKey(pubKeyForECDH)
+	pub, err := ecdh.P256().NewPublicKey(pubKeyForECDH)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to create P-256 public key: %w", err)
 	}
 
-	sharedKey, err = priv.ECDH(pub)
+	sharedKey, err = profile.ecdh(pub)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to compute ECDH: %w", err)
 	}
@@ -266,7 +403,7 @@ func ecdhP256(privateKeyHex string, transmittedPubKey []byte) (sharedKey, kdfPub
 	return sharedKey, kdfPubKey, nil
 }
 
-func profileA(input, supiType, privateKey string) (string, error) {
+func profileA(input, supiType string, profile *SuciProfile) (string, error) {
 	logger.SuciLog.Infoln("SuciToSupi Profile A")
 
 	s, err := hex.DecodeString(input)
@@ -284,7 +421,7 @@ func profileA(input, supiType, privateKey string) (string, error) {
 	cipherText := s[ProfileAPubKeyLen : len(s)-ProfileAMacLen]
 	providedMac := s[len(s)-ProfileAMacLen:]
 
-	sharedKey, err := ecdhX25519(privateKey, peerPubKey)
+	sharedKey, err := ecdhX25519(profile, peerPubKey)
 	if err != nil {
 		return "", err
 	}
@@ -297,7 +434,7 @@ func profileA(input, supiType, privateKey string) (string, error) {
 	return calcSchemeResult(plainText, supiType), nil
 }
 
-func profileB(input, supiType, privateKey string) (string, error) {
+func profileB(input, supiType string, profile *SuciProfile) (string, error) {
 	logger.SuciLog.Infoln("SuciToSupi Profile B")
 
 	s, err := hex.DecodeString(input)
@@ -323,7 +460,7 @@ func profileB(input, supiType, privateKey string) (string, error) {
 	cipherText := s[ProfileBPubKeyLen : len(s)-ProfileBMacLen]
 	providedMac := s[len(s)-ProfileBMacLen:]
 
-	sharedKey, kdfPubKey, err := ecdhP256(privateKey, transmittedPubKey)
+	sharedKey, kdfPubKey, err := ecdhP256(profile, transmittedPubKey)
 	if err != nil {
 		return "", err
 	}
@@ -336,10 +473,45 @@ func profileB(input, supiType, privateKey string) (string, error) {
 	return calcSchemeResult(plainText, supiType), nil
 }
 
+func profileMlKem(input, supiType string, profile *SuciProfile) (string, error) {
+	logger.SuciLog.Infoln("SuciToSupi ML-KEM profile")
+
+	s, err := hex.DecodeString(input)
+	if err != nil {
+		logger.SuciLog.Errorln("hex DecodeString error:", err)
+		return "", err
+	}
+
+	if len(s) < ProfileMlKemCipherTextLen+ProfileMlKemMacLen {
+		return "", fmt.Errorf("suci input too short")
+	}
+
+	kemCipherText := s[:ProfileMlKemCipherTextLen]
+	cipherText := s[ProfileMlKemCipherTextLen : len(s)-ProfileMlKemMacLen]
+	providedMac := s[len(s)-ProfileMlKemMacLen:]
+
+	sharedKey, err := profile.decapsulate(kemCipherText)
+	if err != nil {
+		return "", err
+	}
+
+	plainText, err := decryptWithKdf(sharedKey, kemCipherText, cipherText, providedMac,
+		ProfileMlKemEncKeyLen, ProfileMlKemMacKeyLen, ProfileMlKemHashLen, ProfileMlKemIcbLen, ProfileMlKemMacLen)
+	if err != nil {
+		return "", err
+	}
+	return calcSchemeResult(plainText, supiType), nil
+}
+
 func ToSupi(suci string, suciProfiles []SuciProfile) (string, error) {
+	if supi := nullSchemeNaiToSupi(suci); supi != "" {
+		logger.SuciLog.Infof("SUPI type is NAI, GCI or GLI")
+		return supi, nil
+	}
 	parsedSuci := parseSuci(suci)
 	if parsedSuci == nil {
-		if strings.HasPrefix(suci, "imsi-") || strings.HasPrefix(suci, "nai-") {
+		if strings.HasPrefix(suci, PrefixIMSI) || strings.HasPrefix(suci, PrefixNAI) ||
+			strings.HasPrefix(suci, PrefixGCI) || strings.HasPrefix(suci, PrefixGLI) {
 			logger.SuciLog.Infof("Got supi\n")
 			return suci, nil
 		}
@@ -347,47 +519,51 @@ func ToSupi(suci string, suciProfiles []SuciProfile) (string, error) {
 	}
 
 	logger.SuciLog.Infof("scheme %s", parsedSuci.ProtectionScheme)
-	scheme := parsedSuci.ProtectionScheme
-	mccMnc := parsedSuci.Mcc + parsedSuci.Mnc
-	supiPrefix := PrefixIMSI
-
-	if !strings.HasPrefix(parsedSuci.SupiType, SupiTypeIMSI) {
+	scheme := strings.ToUpper(parsedSuci.ProtectionScheme)
+	supiType := SupiTypeIMSI
+	if strings.HasPrefix(parsedSuci.SupiType, SupiTypeNAI) {
+		supiType = SupiTypeNAI
 		logger.SuciLog.Infof("SUPI type is NAI")
-		return "", fmt.Errorf("unsupported suciType NAI")
+	} else {
+		logger.SuciLog.Infof("SUPI type is IMSI")
 	}
-	logger.SuciLog.Infof("SUPI type is IMSI")
 
 	if scheme == NullScheme {
-		return supiPrefix + mccMnc + parsedSuci.SchemeOutput, nil
+		return parsedSuci.supi(supiType, parsedSuci.SchemeOutput)
 	}
 
-	keyIndex, err := strconv.Atoi(parsedSuci.PublicKeyID)
+	if scheme != ProfileAScheme && scheme != ProfileBScheme && scheme != ProfileMlKemScheme {
+		return "", fmt.Errorf("protect Scheme (%s): %w", scheme, ErrUnsupportedProtectionScheme)
+	}
+	publicKeyId, err := strconv.Atoi(parsedSuci.PublicKeyID)
 	if err != nil {
 		return "", fmt.Errorf("parse HNPublicKeyID error: %w", err)
 	}
-	if keyIndex < 1 || keyIndex > len(suciProfiles) {
-		return "", fmt.Errorf("keyIndex (%d) out of range (%d)", keyIndex, len(suciProfiles))
-	}
-
-	profile := suciProfiles[keyIndex-1]
-	if scheme != profile.ProtectionScheme {
-		return "", fmt.Errorf("protect Scheme mismatch [%s:%s]", scheme, profile.ProtectionScheme)
+	profile, err := FindProfile(suciProfiles, scheme, publicKeyId)
+	if err != nil {
+		return "", err
 	}
 
 	switch scheme {
 	case ProfileAScheme:
-		result, err := profileA(parsedSuci.SchemeOutput, SupiTypeIMSI, profile.PrivateKey)
+		result, err := profileA(parsedSuci.SchemeOutput, supiType, profile)
 		if err != nil {
 			return "", err
 		}
-		return supiPrefix + mccMnc + result, nil
+		return parsedSuci.supi(supiType, result)
 	case ProfileBScheme:
-		result, err := profileB(parsedSuci.SchemeOutput, SupiTypeIMSI, profile.PrivateKey)
+		result, err := profileB(parsedSuci.SchemeOutput, supiType, profile)
+		if err != nil {
+			return "", err
+		}
+		return parsedSuci.supi(supiType, result)
+	case ProfileMlKemScheme:
+		result, err := profileMlKem(parsedSuci.SchemeOutput, supiType, profile)
 		if err != nil {
 			return "", err
 		}
-		return supiPrefix + mccMnc + result, nil
+		return parsedSuci.supi(supiType, result)
 	default:
-		return "", fmt.Errorf("protect Scheme (%s) is not supported", scheme)
+		return "", fmt.Errorf("protect Scheme (%s): %w", scheme, ErrUnsupportedProtectionScheme)
 	}
 }
@@ -1,8 +1,16 @@
 package suci
 
 import (
+	"crypto/ecdh"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
 	"errors"
+	"os"
+	"path/filepath"
 	"testing"
+
+	"github.com/free5gc/udm/pkg/keyprovider"
 )
 
 func TestToSupi(t *testing.T) {
@@ -92,6 +100,58 @@ func TestToSupi(t *testing.T) {
 			expectedSupi: "imsi-00101001002086",
 			expectedErr:  nil,
 		},
+		{
+			suci:         "suci-3-wireline-operator.example.com-12-0-0-line-0001",
+			expectedSupi: "gli-line-0001@wireline-operator.example.com",
+			expectedErr:  nil,
+		},
+		{
+			suci:         "suci-2-cable.example.com-0-0-0-00a0bc123456",
+			expectedSupi: "gci-00a0bc123456@cable.example.com",
+			expectedErr:  nil,
+		},
+		{
+			suci:         "suci-1-iot.operator-example.com-0-0-0-iot-device-0042",
+			expectedSupi: "nai-iot-device-0042@iot.operator-example.com",
+			expectedErr:  nil,
+		},
+		{
+			// Profile A, username "iot-device-0042"
+			suci: "suci-1-iot.operator-example.com-0-1-1-03efcedec80bfd3019bc8ebc5d7bd0db9e962735e56c225a2cce5bb33e" +
+				"b47b37afc93e78271d5015c6445140dc5fb851572279e1f3c69f",
+			expectedSupi: "nai-iot-device-0042@iot.operator-example.com",
+			expectedErr:  nil,
+		},
+		{
+			// Profile B, compressed ephemeral public key
+			suci: "suci-1-iot.operator-example.com-12-2-2-0261733d14382c9bdbf42b340d4f970c8babb0c102431c4c8104c6" +
+				"a53462c4877c0571b2cb14f454c9f32f7f3d0a78f1423e55cbfe6f6a10",
+			expectedSupi: "nai-iot-device-0042@iot.operator-example.com",
+			expectedErr:  nil,
+		},
+		{
+			// Profile B, uncompressed ephemeral public key
+			suci: "suci-1-iot.operator-example.com-12-2-3-0461733d14382c9bdbf42b340d4f970c8babb0c102431c4c8104c6" +
+				"a53462c4877c94ef9d72d59f99dc4122c196d44d97f751c133ad5d59ccf4571137f371859d7c0571b2cb14f454c9f32" +
+				"f7f3d0a78f1423e55cbfe6f6a10",
+			expectedSupi: "nai-iot-device-0042@iot.operator-example.com",
+			expectedErr:  nil,
+		},
+		{
+			suci:         "nai-iot-device-0042@iot.operator-example.com",
+			expectedSupi: "nai-iot-device-0042@iot.operator-example.com",
+			expectedErr:  nil,
+		},
+		{
+			suci:         "suci-0-208-93-0-5-1-0123456789abcdef",
+			expectedSupi: "",
+			expectedErr:  ErrUnsupportedProtectionScheme,
+		},
+		{
+			suci:         "gci-00a0bc123456@cable.example.com",
+			expectedSupi: "gci-00a0bc123456@cable.example.com",
+			expectedErr:  nil,
+		},
 	}
 	for i, tc := range testCases {
 		supi, err := ToSupi(tc.suci, suciProfiles)
@@ -104,3 +164,199 @@ func TestToSupi(t *testing.T) {
 		}
 	}
 }
+
+func TestToSupiByPublicKeyId(t *testing.T) {
+	suciProfiles := []SuciProfile{
+		{
+			ProtectionScheme: "2", // Protect Scheme: Profile B, next generation
+			PublicKeyId:      9,
+			PrivateKey:       "1111111111111111111111111111111111111111111111111111111111111111",
+		},
+		{
+			ProtectionScheme: "2", // Protect Scheme: Profile B
+			PublicKeyId:      5,
+			PrivateKey:       "F1AB1074477EBCC7F554EA1C5FC368B1616730155E0041AC447D6301975FECDA",
+		},
+		{
+			ProtectionScheme: "1", // Protect Scheme: Profile A, sharing the ID of the profile B key
+			PublicKeyId:      5,
+			PrivateKey:       "c53c22208b61860b06c62e5406a7b330c2b577aa5558981510d128247d38bd1d",
+		},
+	}
+	testCases := []struct {
+		suci         string
+		expectedSupi string
+		expectErr    bool
+	}{
+		{
+			suci: "suci-0-208-93-0-1-5-b2e92f836055a255837debf850b528997ce0201cb82a" +
+				"dfe4be1f587d07d8457dcb02352410cddd9e730ef3fa87",
+			expectedSupi: "imsi-20893001002086",
+		},
+		{
+			suci: "suci-0-208-93-0-2-5-039aab8376597021e855679a9778ea0b67396e68c66d" +
+				"f32c0f41e9acca2da9b9d146a33fc2716ac7dae96aa30a4d",
+			expectedSupi: "imsi-20893001002086",
+		},
+		{
+			// concealed with the key of ID 5
+			suci: "suci-0-208-93-0-2-9-039aab8376597021e855679a9778ea0b67396e68c66d" +
+				"f32c0f41e9acca2da9b9d146a33fc2716ac7dae96aa30a4d",
+			expectErr: true,
+		},
+		{
+			// the list position is not the ID of keys with an explicit one
+			suci: "suci-0-208-93-0-2-2-039aab8376597021e855679a9778ea0b67396e68c66d" +
+				"f32c0f41e9acca2da9b9d146a33fc2716ac7dae96aa30a4d",
+			expectErr: true,
+		},
+	}
+	for i, tc := range testCases {
+		supi, err := ToSupi(tc.suci, suciProfiles)
+		if tc.expectErr {
+			if err == nil {
+				t.Errorf("TC%d fail: expected error", i)
+			}
+		} else if err != nil {
+			t.Errorf("TC%d fail: err[%v]", i, err)
+		} else if supi != tc.expectedSupi {
+			t.Errorf("TC%d fail: supi[%s], expected[%s]", i, supi, tc.expectedSupi)
+		}
+	}
+}
+
+func TestCheckServed(t *testing.T) {
+	plmnIds := []string{"20893", "310410"}
+	routingIndicators := []string{"0", "12"}
+	testCases := []struct {
+		name      string
+		suci      string
+		plmnIds   []string
+		expectErr error
+	}{
+		{
+			name:    "served",
+			suci:    "suci-0-208-93-12-1-1-b2e92f836055a255837debf850b528997ce0201cb82a",
+			plmnIds: plmnIds,
+		},
+		{
+			name:    "served 3-digit MNC",
+			suci:    "suci-0-310-410-0-0-0-123456789",
+			plmnIds: plmnIds,
+		},
+		{
+			name:      "foreign PLMN",
+			suci:      "suci-0-208-95-0-1-1-b2e92f836055a255837debf850b528997ce0201cb82a",
+			plmnIds:   plmnIds,
+			expectErr: ErrHomeNetworkNotServed,
+		},
+		{
+			name: "any PLMN",
+			suci: "suci-0-208-95-0-1-1-b2e92f836055a255837debf850b528997ce0201cb82a",
+		},
+		{
+			name:      "unserved routing indicator",
+			suci:      "suci-0-208-93-0012-1-1-b2e92f836055a255837debf850b528997ce0201cb82a",
+			plmnIds:   plmnIds,
+			expectErr: ErrRoutingIndicatorNotServed,
+		},
+		{
+			name:    "NAI realm",
+			suci:    "suci-1-iot.operator-example.com-0-1-1-b2e92f836055a255837debf850b528997ce0201cb82a",
+			plmnIds: plmnIds,
+		},
+		{
+			name:      "null scheme GLI with an unserved routing indicator",
+			suci:      "suci-3-wireline.example.com-7-0-0-line-0001",
+			plmnIds:   plmnIds,
+			expectErr: ErrRoutingIndicatorNotServed,
+		},
+		{
+			name:    "SUPI",
+			suci:    "imsi-208950000000001",
+			plmnIds: plmnIds,
+		},
+	}
+	for _, tc := range testCases {
+		err := CheckServed(tc.suci, tc.plmnIds, routingIndicators)
+		if !errors.Is(err, tc.expectErr) {
+			t.Errorf("%s fail: err[%v], expected[%v]", tc.name, err, tc.expectErr)
+		}
+	}
+}
+
+func writePrivateKeyPem(t *testing.T, curve ecdh.Curve, privateKeyHex string) string {
+	t.Helper()
+	privBytes, err := hex.DecodeString(privateKeyHex)
+	if err != nil {
+		t.Fatalf("hex decode error: %+v", err)
+	}
+	priv, err := curve.NewPrivateKey(privBytes)
+	if err != nil {
+		t.Fatalf("NewPrivateKey error: %+v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatalf("MarshalPKCS8PrivateKey error: %+v", err)
+	}
+	path := filepath.Join(t.TempDir(), "hn.pem")
+	if err = os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("write private key error: %+v", err)
+	}
+	return path
+}
+
+func TestToSupiWithKeyProvider(t *testing.T) {
+	provider := keyprovider.NewFileProvider()
+	suciProfiles := []SuciProfile{
+		{
+			ProtectionScheme: "1", // Protect Scheme: Profile A
+			PrivateKeyRef: writePrivateKeyPem(t, ecdh.X25519(),
+				"c53c22208b61860b06c62e5406a7b330c2b577aa5558981510d128247d38bd1d"),
+			KeyProvider: provider,
+		},
+		{
+			ProtectionScheme: "2", // Protect Scheme: Profile B
+			PrivateKeyRef: writePrivateKeyPem(t, ecdh.P256(),
+				"F1AB1074477EBCC7F554EA1C5FC368B1616730155E0041AC447D6301975FECDA"),
+			KeyProvider: provider,
+		},
+		{
+			ProtectionScheme: "2", // Protect Scheme: Profile B, without a key provider
+			PrivateKeyRef:    "hn.pem",
+		},
+	}
+	testCases := []struct {
+		suci         string
+		expectedSupi string
+		expectErr    bool
+	}{
+		{
+			suci: "suci-0-208-93-0-1-1-b2e92f836055a255837debf850b528997ce0201cb82a" +
+				"dfe4be1f587d07d8457dcb02352410cddd9e730ef3fa87",
+			expectedSupi: "imsi-20893001002086",
+		},
+		{
+			suci: "suci-0-208-93-0-2-2-039aab8376597021e855679a9778ea0b67396e68c66d" +
+				"f32c0f41e9acca2da9b9d146a33fc2716ac7dae96aa30a4d",
+			expectedSupi: "imsi-20893001002086",
+		},
+		{
+			suci: "suci-0-208-93-0-2-3-039aab8376597021e855679a9778ea0b67396e68c66d" +
+				"f32c0f41e9acca2da9b9d146a33fc2716ac7dae96aa30a4d",
+			expectErr: true,
+		},
+	}
+	for i, tc := range testCases {
+		supi, err := ToSupi(tc.suci, suciProfiles)
+		if tc.expectErr {
+			if err == nil {
+				t.Errorf("TC%d fail: expected error", i)
+			}
+		} else if err != nil {
+			t.Errorf("TC%d fail: err[%v]", i, err)
+		} else if supi != tc.expectedSupi {
+			t.Errorf("TC%d fail: supi[%s], expected[%s]", i, supi, tc.expectedSupi)
+		}
+	}
+}
@@ -0,0 +1,322 @@
+// Package tuak implements the TUAK algorithm set for the 3GPP authentication and
+// key generation functions f1, f1*, f2, f3, f4, f5 and f5* (3GPP TS 35.231).
+package tuak
+
+import (
+	"fmt"
+	"math/bits"
+)
+
+const (
+	TopLen           = 32 // octets
+	RandLen          = 16 // octets
+	SqnLen           = 6  // octets
+	AmfLen           = 2  // octets
+	AkLen            = 6  // octets
+	DefaultKeccakItr = 1
+)
+
+// ALGONAME of TS 35.231 6.1
+var algoName = []byte("TUAK1.0")
+
+// Layout of the 1600-bit Keccak state, TS 35.231 6.2 - 6.6
+const (
+	stateLen    = 200
+	topcOffset  = 0
+	instOffset  = 32
+	nameOffset  = 33
+	randOffset  = 40
+	amfOffset   = 56
+	sqnOffset   = 58
+	keyOffset   = 64
+	padOffset   = 96
+	rateEnd     = 135
+	ckOffset    = 32
+	ikOffset    = 64
+	akOffset    = 96
+	instF1Star  = 0x80
+	instF2345   = 0x40
+	instF5Star  = 0xc0
+	instKey256  = 0x01
+	instIk256   = 0x02
+	instCk256   = 0x04
+	instLen64   = 0x08
+	instLen128  = 0x10
+	instLen256  = 0x20
+	keyLen128   = 16
+	keyLen256   = 32
+	paddingHead = 0x1f
+	paddingTail = 0x80
+)
+
+var keccakRoundConstants = [24]uint64{
+	0x0000000000000001, 0x0000000000008082, 0x800000000000808A, 0x8000000080008000,
+	0x000000000000808B, 0x0000000080000001, 0x8000000080008081, 0x8000000000008009,
+	0x000000000000008A, 0x0000000000000088, 0x0000000080008009, 0x000000008000000A,
+	0x000000008000808B, 0x800000000000008B, 0x8000000000008089, 0x8000000000008003,
+	0x8000000000008002, 0x8000000000000080, 0x000000000000800A, 0x800000008000000A,
+	0x8000000080008081, 0x8000000000008080, 0x0000000080000001, 0x8000000080008008,
+}
+
+var keccakRotations = [25]int{
+	0, 1, 62, 28, 27,
+	36, 44, 6, 55, 20,
+	3, 10, 43, 25, 39,
+	41, 45, 15, 21, 8,
+	18, 2, 61, 56, 14,
+}
+
+// keccakF1600 applies the Keccak-f[1600] permutation on a state of 25 little-endian lanes.
+func keccakF1600(a *[25]uint64) {
+	var c [5]uint64
+	var b [25]uint64
+	for round := 0; round < len(keccakRoundConstants); round++ {
+		// theta
+		for x := 0; x < 5; x++ {
+			c[x] = a[x] ^ a[x+5] ^ a[x+10] ^ a[x+15] ^ a[x+20]
+		}
+		for x := 0; x < 5; x++ {
+			d := c[(x+4)%5] ^ bits.RotateLeft64(c[(x+1)%5], 1)
+			for y := 0; y < 25; y += 5 {
+				a[y+x] ^= d
+			}
+		}
+		// rho and pi
+		for x := 0; x < 5; x++ {
+			for y := 0; y < 5; y++ {
+				b[y+5*((2*x+3*y)%5)] = bits.RotateLeft64(a[x+5*y], keccakRotations[x+5*y])
+			}
+		}
+		// chi
+		for y := 0; y < 25; y += 5 {
+			for x := 0; x < 5; x++ {
+				a[y+x] = b[y+x] ^ (^b[y+(x+1)%5] & b[y+(x+2)%5])
+			}
+		}
+		// iota
+		a[0] ^= keccakRoundConstants[round]
+	}
+}
+
+func permute(state []byte) {
+	var lanes [25]uint64
+	for i := range lanes {
+		for j := 0; j < 8; j++ {
+			lanes[i] |= uint64(state[8*i+j]) << (8 * j)
+		}
+	}
+	keccakF1600(&lanes)
+	for i := range lanes {
+		for j := 0; j < 8; j++ {
+			state[8*i+j] = byte(lanes[i] >> (8 * j))
+		}
+	}
+}
+
+// pushData loads data into the state with the octet order reversed, as the most
+// significant octet of each TS 35.231 input string occupies the highest state position.
+func pushData(state, data []byte, offset int) {
+	for i := range data {
+		state[offset+i] = data[len(data)-1-i]
+	}
+}
+
+func pullData(out, state []byte, offset int) {
+	for i := range out {
+		out[i] = state[offset+len(out)-1-i]
+	}
+}
+
+func keyInstance(k []byte) (byte, error) {
+	switch len(k) {
+	case keyLen128:
+		return 0, nil
+	case keyLen256:
+		return instKey256, nil
+	default:
+		return 0, fmt.Errorf("invalid K length %d, should be 16 or 32 octets", len(k))
+	}
+}
+
+func lengthInstance(n int, allowed ...int) (byte, error) {
+	for _, a := range allowed {
+		if n != a {
+			continue
+		}
+		switch n {
+		case 8:
+			return instLen64, nil
+		case 16:
+			return instLen128, nil
+		case 32:
+			return instLen256, nil
+		default:
+			return 0, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid output length %d octets", n)
+}
+
+func tuakMain(instance byte, topc, k, rand, amf, sqn []byte, keccakIterations int) ([]byte, error) {
+	if len(topc) != TopLen {
+		return nil, fmt.Errorf("invalid TOPc length %d, should be %d octets", len(topc), TopLen)
+	}
+	if rand != nil && len(rand) != RandLen {
+		return nil, fmt.Errorf("invalid RAND length %d, should be %d octets", len(rand), RandLen)
+	}
+	if amf != nil && len(amf) != AmfLen {
+		return nil, fmt.Errorf("invalid AMF length %d, should be %d octets", len(amf), AmfLen)
+	}
+	if sqn != nil && len(sqn) != SqnLen {
+		return nil, fmt.Errorf("invalid SQN length %d, should be %d octets", len(sqn), SqnLen)
+	}
+	if keccakIterations < 1 {
+		return nil, fmt.Errorf("invalid number of Keccak iterations %d", keccakIterations)
+	}
+
+	state := make([]byte, stateLen)
+	pushData(state, topc, topcOffset)
+	state[instOffset] = instance
+	pushData(state, algoName, nameOffset)
+	if rand != nil {
+		pushData(state, rand, randOffset)
+	}
+	if amf != nil {
+		pushData(state, amf, amfOffset)
+	}
+	if sqn != nil {
+		pushData(state, sqn, sqnOffset)
+	}
+	pushData(state, k, keyOffset)
+	state[padOffset] = paddingHead
+	state[rateEnd] = paddingTail
+
+	for i := 0; i < keccakIterations; i++ {
+		permute(state)
+	}
+	return state, nil
+}
+
+// ComputeTopc derives TOPc from the operator variant TOP and the subscriber key K (TS 35.231 6.2).
+func ComputeTopc(top, k []byte, keccakIterations int) ([]byte, error) {
+	instance, err := keyInstance(k)
+	if err != nil {
+		return nil, err
+	}
+	state, err := tuakMain(instance, top, k, nil, nil, nil, keccakIterations)
+	if err != nil {
+		return nil, err
+	}
+	topc := make([]byte, TopLen)
+	pullData(topc, state, topcOffset)
+	return topc, nil
+}
+
+// F1 computes the network authentication code MAC-A and the resynchronisation
+// authentication code MAC-S (TS 35.231 6.3 and 6.4). The MAC length (8, 16 or 32 octets)
+// is taken from the output slices, either of which may be nil.
+func F1(topc, k, rand, sqn, amf, macA, macS []byte, keccakIterations int) error {
+	keyInst, err := keyInstance(k)
+	if err != nil {
+		return err
+	}
+	if amf == nil || sqn == nil {
+		return fmt.Errorf("f1 requires SQN and AMF")
+	}
+
+	if macA != nil {
+		macInst, err := lengthInstance(len(macA), 8, 16, 32)
+		if err != nil {
+			return fmt.Errorf("MAC-A: %w", err)
+		}
+		state, err := tuakMain(macInst|keyInst, topc, k, rand, amf, sqn, keccakIterations)
+		if err != nil {
+			return err
+		}
+		pullData(macA, state, 0)
+	}
+
+	if macS != nil {
+		macInst, err := lengthInstance(len(macS), 8, 16, 32)
+		if err != nil {
+			return fmt.Errorf("MAC-S: %w", err)
+		}
+		state, err := tuakMain(instF1Star|macInst|keyInst, topc, k, rand, amf, sqn, keccakIterations)
+		if err != nil {
+			return err
+		}
+		pullData(macS, state, 0)
+	}
+	return nil
+}
+
+// F2345 computes RES, CK, IK and AK (TS 35.231 6.5) and the resynchronisation anonymity
+// key AK* (TS 35.231 6.6). RES may be 4, 8, 16 or 32 octets, CK and IK 16 or 32 octets and
+// AK and AK* 6 octets; the lengths are taken from the output slices, any of which may be nil.
+func F2345(topc, k, rand, res, ck, ik, ak, akstar []byte, keccakIterations int) error {
+	keyInst, err := keyInstance(k)
+	if err != nil {
+		return err
+	}
+
+	if res != nil || ck != nil || ik != nil || ak != nil {
+		instance := instF2345 | keyInst
+		if res != nil {
+			resInst, err := lengthInstance(len(res), 4, 8, 16, 32)
+			if err != nil {
+				return fmt.Errorf("RES: %w", err)
+			}
+			instance |= resInst
+		}
+		if ck != nil {
+			ckInst, err := lengthInstance(len(ck), 16, 32)
+			if err != nil {
+				return fmt.Errorf("CK: %w", err)
+			}
+			if ckInst == instLen256 {
+				instance |= instCk256
+			}
+		}
+		if ik != nil {
+			ikInst, err := lengthInstance(len(ik), 16, 32)
+			if err != nil {
+				return fmt.Errorf("IK: %w", err)
+			}
+			if ikInst == instLen256 {
+				instance |= instIk256
+			}
+		}
+		if ak != nil && len(ak) != AkLen {
+			return fmt.Errorf("invalid AK length %d, should be %d octets", len(ak), AkLen)
+		}
+
+		state, err := tuakMain(instance, topc, k, rand, nil, nil, keccakIterations)
+		if err != nil {
+			return err
+		}
+		if res != nil {
+			pullData(res, state, 0)
+		}
+		if ck != nil {
+			pullData(ck, state, ckOffset)
+		}
+		if ik != nil {
+			pullData(ik, state, ikOffset)
+		}
+		if ak != nil {
+			pullData(ak, state, akOffset)
+		}
+	}
+
+	if akstar != nil {
+		if len(akstar) != AkLen {
+			return fmt.Errorf("invalid AK* length %d, should be %d octets", len(akstar), AkLen)
+		}
+		state, err := tuakMain(instF5Star|keyInst, topc, k, rand, nil, nil, keccakIterations)
+		if err != nil {
+			return err
+		}
+		pullData(akstar, state, akOffset)
+	}
+	return nil
+}
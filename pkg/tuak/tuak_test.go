@@ -0,0 +1,109 @@
+package tuak
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func decodeHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatalf("hex decode [%s] error: %+v", s, err)
+	}
+	return b
+}
+
+// 3GPP TS 35.232 Test Set 1
+func TestTuakTestSet1(t *testing.T) {
+	top := decodeHex(t, "5555555555555555555555555555555555555555555555555555555555555555")
+	k := decodeHex(t, "abababababababababababababababab")
+	rand := decodeHex(t, "42424242424242424242424242424242")
+	sqn := decodeHex(t, "111111111111")
+	amf := decodeHex(t, "ffff")
+
+	topc, err := ComputeTopc(top, k, DefaultKeccakItr)
+	if err != nil {
+		t.Fatalf("ComputeTopc error: %+v", err)
+	}
+	expectTopc := decodeHex(t, "bd04d9530e87513c5d837ac2ad954623a8e2330c115305a73eb45d1f40cccbff")
+	if !bytes.Equal(topc, expectTopc) {
+		t.Errorf("TOPc[%x], expected[%x]", topc, expectTopc)
+	}
+
+	macA, macS := make([]byte, 8), make([]byte, 8)
+	if err = F1(topc, k, rand, sqn, amf, macA, macS, DefaultKeccakItr); err != nil {
+		t.Fatalf("F1 error: %+v", err)
+	}
+	if expect := decodeHex(t, "f9a54e6aeaa8618d"); !bytes.Equal(macA, expect) {
+		t.Errorf("MAC-A[%x], expected[%x]", macA, expect)
+	}
+	if expect := decodeHex(t, "e94b4dc6c7297df3"); !bytes.Equal(macS, expect) {
+		t.Errorf("MAC-S[%x], expected[%x]", macS, expect)
+	}
+
+	res, ck, ik, ak, akstar := make([]byte, 4), make([]byte, 16), make([]byte, 16), make([]byte, 6), make([]byte, 6)
+	if err = F2345(topc, k, rand, res, ck, ik, ak, akstar, DefaultKeccakItr); err != nil {
+		t.Fatalf("F2345 error: %+v", err)
+	}
+	if expect := decodeHex(t, "657acd64"); !bytes.Equal(res, expect) {
+		t.Errorf("RES[%x], expected[%x]", res, expect)
+	}
+	if expect := decodeHex(t, "d71a1e5c6caffe986a26f783e5c78be1"); !bytes.Equal(ck, expect) {
+		t.Errorf("CK[%x], expected[%x]", ck, expect)
+	}
+	if expect := decodeHex(t, "be849fa2564f869aecee6f62d4337e72"); !bytes.Equal(ik, expect) {
+		t.Errorf("IK[%x], expected[%x]", ik, expect)
+	}
+	if expect := decodeHex(t, "719f1e9b9054"); !bytes.Equal(ak, expect) {
+		t.Errorf("AK[%x], expected[%x]", ak, expect)
+	}
+	if expect := decodeHex(t, "e7af6b3d0e38"); !bytes.Equal(akstar, expect) {
+		t.Errorf("AK*[%x], expected[%x]", akstar, expect)
+	}
+}
+
+func TestTuakOutputLengths(t *testing.T) {
+	topc := decodeHex(t, "bd04d9530e87513c5d837ac2ad954623a8e2330c115305a73eb45d1f40cccbff")
+	k256 := bytes.Repeat([]byte{0xab}, 32)
+	rand := bytes.Repeat([]byte{0x42}, 16)
+	sqn := decodeHex(t, "111111111111")
+	amf := decodeHex(t, "ffff")
+
+	testCases := []struct {
+		name    string
+		macLen  int
+		resLen  int
+		wantErr bool
+	}{
+		{name: "MAC 64, RES 32", macLen: 8, resLen: 4},
+		{name: "MAC 128, RES 64", macLen: 16, resLen: 8},
+		{name: "MAC 256, RES 128", macLen: 32, resLen: 16},
+		{name: "MAC 256, RES 256", macLen: 32, resLen: 32},
+		{name: "invalid MAC", macLen: 4, resLen: 8, wantErr: true},
+		{name: "invalid RES", macLen: 8, resLen: 6, wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			macA := make([]byte, tc.macLen)
+			res, ak, akstar := make([]byte, tc.resLen), make([]byte, AkLen), make([]byte, AkLen)
+			err := F1(topc, k256, rand, sqn, amf, macA, nil, DefaultKeccakItr)
+			if err == nil {
+				err = F2345(topc, k256, rand, res, nil, nil, ak, akstar, DefaultKeccakItr)
+			}
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %+v", err)
+			}
+			if bytes.Equal(ak, akstar) {
+				t.Errorf("AK and AK* should differ")
+			}
+		})
+	}
+}